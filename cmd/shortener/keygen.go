@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"example.com/shortener/internal/app/keyring"
+	"example.com/shortener/internal/config"
+)
+
+// keygenCommand - имя подкоманды для создания ключа подписи
+const keygenCommand = "keygen"
+
+// runKeygen создает новый ключ подписи и печатает его:
+//
+//	shortener keygen - только новый ключ в формате kid:secret
+//	shortener keygen [флаги] cookie|jwt - новое значение COOKIE_KEYS или JWT_KEYS:
+//	новый ключ первым, за ним ключи из текущей конфигурации
+//
+// Старые ключи остаются в списке, чтобы выданные ими куки и токены
+// продолжали проверяться. Когда они истекут, старые ключи можно убрать
+func runKeygen() error {
+	// убираем имя подкоманды, чтобы флаги разбирались как при запуске сервера
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	key, err := keyring.Generate()
+	if err != nil {
+		return err
+	}
+	if len(os.Args) < 2 {
+		fmt.Println(key)
+		return nil
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	var name, keys string
+	switch target := flag.Arg(0); target {
+	case "cookie":
+		name, keys = "COOKIE_KEYS", cfg.CookieKeys
+	case "jwt":
+		name, keys = "JWT_KEYS", cfg.JWTKeys
+	default:
+		return fmt.Errorf("unknown key type: %s", target)
+	}
+	if keys != "" {
+		key += "," + keys
+	}
+	fmt.Printf("%s=%s\n", name, key)
+	return nil
+}
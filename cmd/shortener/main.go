@@ -12,8 +12,12 @@ import (
 
 	pb "example.com/shortener/internal/app/gRPC"
 	"example.com/shortener/internal/app/handlers"
+	"example.com/shortener/internal/app/metrics"
+	"example.com/shortener/internal/app/ratelimit"
 	"example.com/shortener/internal/app/service"
 	"example.com/shortener/internal/app/storage"
+	"example.com/shortener/internal/app/storage/cache"
+	"example.com/shortener/internal/app/tracing"
 	"example.com/shortener/internal/config"
 	"example.com/shortener/internal/config/utils"
 	"example.com/shortener/internal/logger"
@@ -21,9 +25,13 @@ import (
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
+// healthInterval - период обновления состояния в grpc.health.v1
+const healthInterval = 5 * time.Second
+
 var (
 	localAddr    = "localhost:8080"
 	buildVersion string
@@ -39,16 +47,50 @@ func main() {
 
 	log := logger.InitLog()
 
+	// подкоманда migrate применяет миграции бд без запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		if err := runMigrate(log); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// подкоманда keygen создает ключ подписи для COOKIE_KEYS и JWT_KEYS
+	if len(os.Args) > 1 && os.Args[1] == keygenCommand {
+		if err := runKeygen(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// получаем структуру с конфигурацией приложения
 	cfg, err := config.GetConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.WithFields(logrus.Fields{"cfg": cfg}).Debug("Конфигурация приложения")
+	if cfg.CookieKeys == "" || cfg.JWTKeys == "" {
+		log.Warn("Ключи подписи COOKIE_KEYS или JWT_KEYS не заданы, используются встроенные ключи")
+	}
+
+	// трассировка настраивается до создания хранилища и серверов
+	shutdownTracing, err := tracing.Init(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// создаем объект хранилища
-	storer = storage.New(cfg, log)
+	// создаем объект хранилища, операции с ним попадают в метрики и трассировку
+	// при недоступном хранилище и STORAGE_FAIL_FAST сервис не запускается
+	storer, err = storage.New(cfg, log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.WithFields(logrus.Fields{"backend": storer.Backend()}).Info("Хранилище")
+	appMetrics := metrics.New()
+	// переходы читаются через кэш, попадания в него тоже считаются в метриках
+	storer = tracing.NewStorer(metrics.NewStorer(cache.NewStorer(storer, cfg), appMetrics))
 	service := service.New(cfg, storer, log)
+	appMetrics.RegisterDeleteQueue(service.OutCh)
 
 	// канал для перенаправления прерываний
 	// поскольку нужно отловить всего одно прерывание,
@@ -61,7 +103,22 @@ func main() {
 
 	srv := http.Server{
 		Addr:    cfg.Server,
-		Handler: router}
+		Handler: appMetrics.HTTPMiddleware(tracing.HTTPMiddleware(router))}
+
+	// служебный сервер с метриками слушает отдельный адрес,
+	// чтобы /metrics не был доступен снаружи вместе с API
+	var adminSrv *http.Server
+	if cfg.AdminAddress != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/metrics", appMetrics.Handler())
+		adminSrv = &http.Server{Addr: cfg.AdminAddress, Handler: adminMux}
+		go func() {
+			log.WithFields(logrus.Fields{"admin": cfg.AdminAddress}).Info("Admin server started")
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("admin listen: %v\n", err)
+			}
+		}()
+	}
 
 	go func() {
 		log.WithFields(logrus.Fields{"server": cfg.Server})
@@ -85,15 +142,34 @@ func main() {
 
 	// создаем gRPC сервер
 	creds := insecure.NewCredentials()
+	trustedProxies, err := ratelimit.ParseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authFunc := pb.NewAuthFunc(service)
 	server := grpc.NewServer(
 		grpc.Creds(creds),
-		grpc.UnaryInterceptor(auth.UnaryServerInterceptor(pb.AuthInterceptor)),
+		// лимит проверяется после авторизации, чтобы учитывать вызовы по пользователю
+		grpc.ChainUnaryInterceptor(
+			tracing.UnaryServerInterceptor(),
+			appMetrics.UnaryServerInterceptor(),
+			auth.UnaryServerInterceptor(authFunc),
+			pb.RateLimitInterceptor(ratelimit.NewLimits(cfg), trustedProxies),
+		),
+		grpc.ChainStreamInterceptor(
+			tracing.StreamServerInterceptor(),
+			appMetrics.StreamServerInterceptor(),
+			auth.StreamServerInterceptor(authFunc),
+		),
 	)
 	// рефлексия
 	reflection.Register(server)
 	// регистрируем сервис
 
 	pb.RegisterHandlersServer(server, pb.NewGrpcHandlers(service))
+	// стандартный сервис проверки состояния grpc.health.v1
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	healthpb.RegisterHealthServer(server, pb.NewHealthServer(healthCtx, service, healthInterval))
 	log.Info("gRPC server started")
 
 	go func() {
@@ -108,6 +184,9 @@ func main() {
 	sig := <-sigint
 	log.Printf("Received signal: %v\n", sig)
 
+	// клиенты проверки состояния узнают об остановке первыми
+	stopHealth()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 
@@ -115,6 +194,11 @@ func main() {
 		// ошибки закрытия Listener
 		log.Printf("HTTP server Shutdown: %v", err)
 	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(ctx); err != nil {
+			log.Printf("Admin server Shutdown: %v", err)
+		}
+	}
 
 	//завершения процедуры graceful shutdown
 	log.Println("Server shutdown gracefully")
@@ -123,6 +207,10 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// отправляем в коллектор оставшиеся спаны
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Tracing shutdown: %v", err)
+	}
 }
 
 // showBuildData выводит версию, время и последний коммит текущей сборки
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"example.com/shortener/internal/app/storage/database"
+	"example.com/shortener/internal/config"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// migrateCommand - имя подкоманды для управления миграциями схемы бд
+const migrateCommand = "migrate"
+
+// runMigrate применяет или откатывает миграции схемы бд:
+//
+//	shortener migrate [флаги] up - применить все новые миграции
+//	shortener migrate [флаги] down [N] - откатить N последних миграций (по умолчанию одну)
+//
+// Флаги и переменные окружения те же, что и для запуска сервера
+func runMigrate(log *logrus.Logger) error {
+	// убираем имя подкоманды, чтобы флаги разбирались как при запуске сервера
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Database == "" {
+		return errors.New("database dsn is not set")
+	}
+
+	direction, steps := "up", 1
+	args := flag.Args()
+	if len(args) > 0 {
+		direction = args[0]
+	}
+	if len(args) > 1 {
+		steps, err = strconv.Atoi(args[1])
+		if err != nil || steps < 1 {
+			return fmt.Errorf("invalid number of steps: %s", args[1])
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	pgxPool, err := pgxpool.Connect(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer pgxPool.Close()
+
+	switch direction {
+	case "up":
+		return database.Migrate(ctx, pgxPool, log)
+	case "down":
+		return database.Rollback(ctx, pgxPool, steps, log)
+	}
+	return fmt.Errorf("unknown migrate direction: %s", direction)
+}
@@ -0,0 +1,177 @@
+// Модуль analytics собирает статистику переходов по сокращенным ссылкам.
+// Переходы складываются в очередь и сохраняются в хранилище пачками
+// в отдельной горутине, чтобы не замедлять обработку редиректов.
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// Store - интерфейс хранилища переходов
+type Store interface {
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+	GetLinkStats(ctx context.Context, shortURL string, since time.Time, top int) (models.LinkStats, error)
+	TotalClicks(ctx context.Context) (int64, error)
+	Close() error
+}
+
+// Параметры записи переходов
+var (
+	queueSize     = 1024
+	flushSize     = 100
+	flushInterval = time.Second
+	storeTimeout  = 5 * time.Second
+)
+
+// Recorder принимает переходы и асинхронно сохраняет их в Store
+type Recorder struct {
+	store   Store
+	geo     *GeoTable
+	salt    []byte
+	clicks  chan models.Click
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped int64
+	log     *logrus.Logger
+}
+
+// New возвращает хранилище переходов. Если у хранилища ссылок есть пул
+// соединений с бд pool, переходы пишутся в бд через него. Иначе счетчики
+// переходов за последние retention дней хранятся в памяти процесса:
+// они не переживают перезапуск и у каждой реплики свои
+func New(pool *pgxpool.Pool, retention int) Store {
+	if pool != nil {
+		return NewDBStore(pool)
+	}
+	return NewMemoryStore(retention)
+}
+
+// NewRecorder - конструктор для Recorder, запускает горутину сохранения переходов
+func NewRecorder(store Store, geo *GeoTable, salt string, log *logrus.Logger) *Recorder {
+	r := &Recorder{
+		store:  store,
+		geo:    geo,
+		salt:   []byte(salt),
+		clicks: make(chan models.Click, queueSize),
+		done:   make(chan struct{}),
+		log:    log,
+	}
+	go r.run()
+	return r
+}
+
+// Record ставит переход в очередь на сохранение.
+// Если очередь заполнена, переход отбрасывается, чтобы не задерживать редирект
+func (r *Recorder) Record(shortURL, referer, userAgent string, ip net.IP) {
+	click := models.Click{
+		Time:      time.Now().UTC(),
+		ShortURL:  shortURL,
+		Referer:   referer,
+		UserAgent: userAgent,
+		Country:   r.geo.Lookup(ip),
+		IPHash:    r.hashIP(ip),
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.clicks <- click:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Dropped возвращает число переходов, отброшенных из-за переполнения очереди
+func (r *Recorder) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// GetLinkStats возвращает статистику переходов по ссылке за последние days дней
+func (r *Recorder) GetLinkStats(ctx context.Context, shortURL string, days int, top int) (models.LinkStats, error) {
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	return r.store.GetLinkStats(ctx, shortURL, since, top)
+}
+
+// TotalClicks возвращает общее число сохраненных переходов по всем ссылкам
+func (r *Recorder) TotalClicks(ctx context.Context) (int64, error) {
+	return r.store.TotalClicks(ctx)
+}
+
+// Close сохраняет оставшиеся в очереди переходы и закрывает хранилище
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.clicks)
+	r.mu.Unlock()
+
+	<-r.done
+	return r.store.Close()
+}
+
+// run накапливает переходы из очереди и сохраняет их пачками
+// при заполнении пачки или по таймеру
+func (r *Recorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Click, 0, flushSize)
+	for {
+		select {
+		case click, ok := <-r.clicks:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= flushSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush сохраняет пачку переходов в хранилище
+func (r *Recorder) flush(batch []models.Click) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := r.store.SaveClicks(ctx, batch); err != nil {
+		r.log.WithFields(logrus.Fields{"clicks": len(batch)}).Error(err.Error())
+	}
+}
+
+// hashIP возвращает хэш IP адреса клиента с солью
+func (r *Recorder) hashIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(r.salt)
+	h.Write(ip.To16())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/logger"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	log := logger.InitLog()
+	geo, err := NewGeoTable(map[string]string{
+		"203.0.113.0/24":  "ru",
+		"203.0.113.0/28":  "kz",
+		"198.51.100.0/24": "de",
+	})
+	require.NoError(t, err)
+
+	store := NewMemoryStore(30)
+	recorder := NewRecorder(store, geo, "salt", log)
+
+	shortURL := "http://localhost:8080/abcdefghij"
+	recorder.Record(shortURL, "https://ya.ru/", "curl", net.ParseIP("203.0.113.5"))
+	recorder.Record(shortURL, "https://ya.ru/", "curl", net.ParseIP("203.0.113.100"))
+	recorder.Record(shortURL, "", "curl", net.ParseIP("192.0.2.1"))
+	recorder.Record("http://localhost:8080/other", "", "curl", nil)
+
+	// Close дожидается сохранения всех переходов из очереди
+	require.NoError(t, recorder.Close())
+	// после закрытия переходы больше не принимаются
+	recorder.Record(shortURL, "", "curl", nil)
+
+	stats, err := recorder.GetLinkStats(context.Background(), shortURL, 7, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Total)
+	require.Len(t, stats.Days, 1)
+	assert.Equal(t, time.Now().UTC().Format(dateLayout), stats.Days[0].Date)
+	assert.Equal(t, 3, stats.Days[0].Clicks)
+	require.Len(t, stats.TopReferrers, 2)
+	assert.Equal(t, "https://ya.ru/", stats.TopReferrers[0].Referer)
+	assert.Equal(t, 2, stats.TopReferrers[0].Clicks)
+	assert.Equal(t, directReferer, stats.TopReferrers[1].Referer)
+
+	stats, err = recorder.GetLinkStats(context.Background(), shortURL, 7, 1)
+	require.NoError(t, err)
+	assert.Len(t, stats.TopReferrers, 1)
+}
+
+func TestNew(t *testing.T) {
+	// без пула соединений переходы хранятся в памяти
+	assert.IsType(t, &MemoryStore{}, New(nil, 30))
+
+	// с пулом хранилища ссылок - в бд через тот же пул
+	poolConfig, err := pgxpool.ParseConfig("postgres://user@127.0.0.1:1/db")
+	require.NoError(t, err)
+	poolConfig.LazyConnect = true
+	pool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	require.NoError(t, err)
+	defer pool.Close()
+	assert.IsType(t, &DBStore{}, New(pool, 30))
+}
+
+func TestMemoryStoreRetention(t *testing.T) {
+	store := NewMemoryStore(7)
+	now := time.Now().UTC()
+	clicks := []models.Click{
+		{ShortURL: "a", Time: now.AddDate(0, 0, -30)},
+		{ShortURL: "a", Time: now.AddDate(0, 0, -1)},
+		{ShortURL: "a", Time: now},
+	}
+	require.NoError(t, store.SaveClicks(context.Background(), clicks))
+
+	// счетчики по дням за пределами срока хранения удаляются, общее число остается
+	stats, err := store.GetLinkStats(context.Background(), "a", time.Time{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Total)
+	assert.Len(t, stats.Days, 2)
+}
+
+func TestGeoTable(t *testing.T) {
+	geo, err := NewGeoTable(map[string]string{
+		"203.0.113.0/24": "ru",
+		"203.0.113.0/28": "kz",
+		"2001:db8::/32":  "de",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		ip      net.IP
+		country string
+	}{
+		{name: "Longest prefix wins", ip: net.ParseIP("203.0.113.5"), country: "KZ"},
+		{name: "Shorter prefix", ip: net.ParseIP("203.0.113.100"), country: "RU"},
+		{name: "IPv6", ip: net.ParseIP("2001:db8::1"), country: "DE"},
+		{name: "Unknown address", ip: net.ParseIP("192.0.2.1"), country: UnknownCountry},
+		{name: "No address", ip: nil, country: UnknownCountry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.country, geo.Lookup(tt.ip))
+		})
+	}
+}
+
+func TestHashIP(t *testing.T) {
+	r := &Recorder{salt: []byte("salt")}
+	hash := r.hashIP(net.ParseIP("203.0.113.5"))
+	assert.Len(t, hash, 64)
+	assert.NotContains(t, hash, "203.0.113.5")
+	// один и тот же адрес в разных представлениях дает одинаковый хэш
+	assert.Equal(t, hash, r.hashIP(net.ParseIP("203.0.113.5").To4()))
+	assert.NotEqual(t, hash, (&Recorder{salt: []byte("other")}).hashIP(net.ParseIP("203.0.113.5")))
+}
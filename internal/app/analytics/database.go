@@ -0,0 +1,106 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// запросы в бд
+var (
+	clicksTotal    = `SELECT COUNT(*) FROM clicks WHERE short_url = $1`
+	allClicksTotal = `SELECT COUNT(*) FROM clicks`
+	clicksByDay    = `SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(*)
+					FROM clicks WHERE short_url = $1 AND clicked_at >= $2
+					GROUP BY day ORDER BY day`
+	topReferers = `SELECT CASE WHEN referer = '' THEN $3 ELSE referer END AS source, COUNT(*) AS clicks
+					FROM clicks WHERE short_url = $1
+					GROUP BY source ORDER BY clicks DESC, source LIMIT $2`
+	clicksColumns = []string{"short_url", "clicked_at", "referer", "user_agent", "country", "ip_hash"}
+)
+
+// DBStore хранит переходы в бд
+type DBStore struct {
+	pgxPool *pgxpool.Pool
+}
+
+// проверка на имплементацию интерфейса
+var _ Store = (*DBStore)(nil)
+
+// NewDBStore - конструктор для DBStore. Пул соединений принадлежит хранилищу
+// ссылок, а таблицу переходов создают его миграции
+func NewDBStore(pgxPool *pgxpool.Pool) *DBStore {
+	return &DBStore{pgxPool: pgxPool}
+}
+
+// SaveClicks записывает пачку переходов в бд с помощью COPY
+func (d *DBStore) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	rows := make([][]interface{}, 0, len(clicks))
+	for _, click := range clicks {
+		rows = append(rows, []interface{}{click.ShortURL, click.Time, click.Referer,
+			click.UserAgent, click.Country, click.IPHash})
+	}
+	_, err := d.pgxPool.CopyFrom(ctx, pgx.Identifier{"clicks"}, clicksColumns, pgx.CopyFromRows(rows))
+	return err
+}
+
+// GetLinkStats возвращает число переходов по дням начиная с since
+// и top самых частых источников переходов
+func (d *DBStore) GetLinkStats(ctx context.Context, shortURL string, since time.Time,
+	top int) (models.LinkStats, error) {
+	stats := models.LinkStats{
+		ShortURL:     shortURL,
+		Days:         []models.DayClicks{},
+		TopReferrers: []models.RefererClicks{},
+	}
+
+	if err := d.pgxPool.QueryRow(ctx, clicksTotal, shortURL).Scan(&stats.Total); err != nil {
+		return stats, err
+	}
+
+	rows, err := d.pgxPool.Query(ctx, clicksByDay, shortURL, since)
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var day models.DayClicks
+		if err := rows.Scan(&day.Date, &day.Clicks); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.Days = append(stats.Days, day)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return stats, rows.Err()
+	}
+
+	rows, err = d.pgxPool.Query(ctx, topReferers, shortURL, top, directReferer)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var referer models.RefererClicks
+		if err := rows.Scan(&referer.Referer, &referer.Clicks); err != nil {
+			return stats, err
+		}
+		stats.TopReferrers = append(stats.TopReferrers, referer)
+	}
+	return stats, rows.Err()
+}
+
+// TotalClicks возвращает общее число переходов по всем ссылкам
+func (d *DBStore) TotalClicks(ctx context.Context) (int64, error) {
+	var total int64
+	err := d.pgxPool.QueryRow(ctx, allClicksTotal).Scan(&total)
+	return total, err
+}
+
+// Close - метод заглушка, пул соединений закрывает хранилище ссылок
+func (d *DBStore) Close() error {
+	return nil
+}
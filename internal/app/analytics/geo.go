@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// UnknownCountry - код страны для адресов, которых нет в таблице префиксов
+const UnknownCountry = "ZZ"
+
+// geoPrefix - подсеть и код страны, к которой она относится
+type geoPrefix struct {
+	network *net.IPNet
+	country string
+}
+
+// GeoTable определяет страну клиента по таблице префиксов IP адресов
+type GeoTable struct {
+	prefixes []geoPrefix
+}
+
+// NewGeoTable строит таблицу из соответствия "подсеть в формате CIDR - код страны"
+func NewGeoTable(prefixes map[string]string) (*GeoTable, error) {
+	table := &GeoTable{}
+	for cidr, country := range prefixes {
+		if err := table.add(cidr, country); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// LoadGeoTable читает таблицу префиксов из csv файла со строками вида
+// "203.0.113.0/24,RU". Пустой путь означает пустую таблицу
+func LoadGeoTable(filename string) (*GeoTable, error) {
+	table := &GeoTable{}
+	if filename == "" {
+		return table, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := table.add(record[0], record[1]); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// add добавляет подсеть в таблицу
+func (t *GeoTable) add(cidr, country string) error {
+	_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return err
+	}
+	t.prefixes = append(t.prefixes, geoPrefix{
+		network: network,
+		country: strings.ToUpper(strings.TrimSpace(country)),
+	})
+	return nil
+}
+
+// Lookup возвращает код страны для самого длинного подходящего префикса
+func (t *GeoTable) Lookup(ip net.IP) string {
+	country := UnknownCountry
+	if t == nil || ip == nil {
+		return country
+	}
+
+	best := -1
+	for _, prefix := range t.prefixes {
+		if !prefix.network.Contains(ip) {
+			continue
+		}
+		if ones, _ := prefix.network.Mask.Size(); ones > best {
+			best = ones
+			country = prefix.country
+		}
+	}
+	return country
+}
@@ -0,0 +1,150 @@
+package analytics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+)
+
+// dateLayout - формат даты для подсчета переходов по дням
+const dateLayout = "2006-01-02"
+
+// directReferer - источник для переходов без заголовка Referer
+const directReferer = "direct"
+
+// linkClicks - накопленные счетчики переходов по одной ссылке
+type linkClicks struct {
+	total    int
+	days     map[string]int
+	referers map[string]int
+}
+
+// MemoryStore хранит в памяти только агрегированные счетчики переходов.
+// Счетчики по дням хранятся за последние retention дней
+type MemoryStore struct {
+	mu        sync.Mutex
+	links     map[string]*linkClicks
+	total     int64
+	retention int
+	// prunedAt - день, в который последний раз удалялись старые счетчики
+	prunedAt string
+}
+
+// проверка на имплементацию интерфейса
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore - конструктор для MemoryStore
+func NewMemoryStore(retention int) *MemoryStore {
+	return &MemoryStore{
+		links:     make(map[string]*linkClicks),
+		retention: retention,
+	}
+}
+
+// SaveClicks увеличивает счетчики переходов
+func (m *MemoryStore) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, click := range clicks {
+		link, ok := m.links[click.ShortURL]
+		if !ok {
+			link = &linkClicks{
+				days:     make(map[string]int),
+				referers: make(map[string]int),
+			}
+			m.links[click.ShortURL] = link
+		}
+		link.total++
+		m.total++
+		link.days[click.Time.UTC().Format(dateLayout)]++
+		link.referers[refererName(click.Referer)]++
+	}
+	m.prune(time.Now().UTC())
+	return nil
+}
+
+// prune раз в день удаляет счетчики по дням старше retention дней.
+// Вызывается под мьютексом
+func (m *MemoryStore) prune(now time.Time) {
+	today := now.Format(dateLayout)
+	if m.retention <= 0 || m.prunedAt == today {
+		return
+	}
+	m.prunedAt = today
+	from := now.Truncate(24*time.Hour).AddDate(0, 0, 1-m.retention).Format(dateLayout)
+	for _, link := range m.links {
+		for date := range link.days {
+			if date < from {
+				delete(link.days, date)
+			}
+		}
+	}
+}
+
+// GetLinkStats возвращает число переходов по дням начиная с since
+// и top самых частых источников переходов
+func (m *MemoryStore) GetLinkStats(ctx context.Context, shortURL string, since time.Time,
+	top int) (models.LinkStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := models.LinkStats{
+		ShortURL:     shortURL,
+		Days:         []models.DayClicks{},
+		TopReferrers: []models.RefererClicks{},
+	}
+	link, ok := m.links[shortURL]
+	if !ok {
+		return stats, nil
+	}
+	stats.Total = link.total
+
+	from := since.UTC().Format(dateLayout)
+	for date, clicks := range link.days {
+		if date < from {
+			continue
+		}
+		stats.Days = append(stats.Days, models.DayClicks{Date: date, Clicks: clicks})
+	}
+	sort.Slice(stats.Days, func(i, j int) bool {
+		return stats.Days[i].Date < stats.Days[j].Date
+	})
+
+	for referer, clicks := range link.referers {
+		stats.TopReferrers = append(stats.TopReferrers, models.RefererClicks{Referer: referer, Clicks: clicks})
+	}
+	sort.Slice(stats.TopReferrers, func(i, j int) bool {
+		if stats.TopReferrers[i].Clicks != stats.TopReferrers[j].Clicks {
+			return stats.TopReferrers[i].Clicks > stats.TopReferrers[j].Clicks
+		}
+		return stats.TopReferrers[i].Referer < stats.TopReferrers[j].Referer
+	})
+	if len(stats.TopReferrers) > top {
+		stats.TopReferrers = stats.TopReferrers[:top]
+	}
+	return stats, nil
+}
+
+// TotalClicks возвращает общее число переходов по всем ссылкам
+func (m *MemoryStore) TotalClicks(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total, nil
+}
+
+// Close - метод заглушка
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// refererName возвращает имя источника перехода
+func refererName(referer string) string {
+	if referer == "" {
+		return directReferer
+	}
+	return referer
+}
@@ -19,11 +19,15 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Handlers_ShortenURL_FullMethodName   = "/grpc.Handlers/ShortenURL"
-	Handlers_GetFullURL_FullMethodName   = "/grpc.Handlers/GetFullURL"
-	Handlers_GetUserURLs_FullMethodName  = "/grpc.Handlers/GetUserURLs"
-	Handlers_DeleteURLs_FullMethodName   = "/grpc.Handlers/DeleteURLs"
-	Handlers_ShortenBatch_FullMethodName = "/grpc.Handlers/ShortenBatch"
+	Handlers_ShortenURL_FullMethodName     = "/grpc.Handlers/ShortenURL"
+	Handlers_GetFullURL_FullMethodName     = "/grpc.Handlers/GetFullURL"
+	Handlers_GetUserURLs_FullMethodName    = "/grpc.Handlers/GetUserURLs"
+	Handlers_DeleteURLs_FullMethodName     = "/grpc.Handlers/DeleteURLs"
+	Handlers_ShortenBatch_FullMethodName   = "/grpc.Handlers/ShortenBatch"
+	Handlers_StreamUserURLs_FullMethodName = "/grpc.Handlers/StreamUserURLs"
+	Handlers_WatchUserURLs_FullMethodName  = "/grpc.Handlers/WatchUserURLs"
+	Handlers_UpdateURL_FullMethodName      = "/grpc.Handlers/UpdateURL"
+	Handlers_RestoreURL_FullMethodName     = "/grpc.Handlers/RestoreURL"
 )
 
 // HandlersClient is the client API for Handlers service.
@@ -35,6 +39,10 @@ type HandlersClient interface {
 	GetUserURLs(ctx context.Context, in *GetUserURLsRequest, opts ...grpc.CallOption) (*GetUserURLsResponse, error)
 	DeleteURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*DeleteURLsResponse, error)
 	ShortenBatch(ctx context.Context, in *ShortenBatchRequest, opts ...grpc.CallOption) (*ShortenBatchResponse, error)
+	StreamUserURLs(ctx context.Context, in *StreamUserURLsRequest, opts ...grpc.CallOption) (Handlers_StreamUserURLsClient, error)
+	WatchUserURLs(ctx context.Context, in *WatchUserURLsRequest, opts ...grpc.CallOption) (Handlers_WatchUserURLsClient, error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error)
+	RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*RestoreURLResponse, error)
 }
 
 type handlersClient struct {
@@ -90,6 +98,88 @@ func (c *handlersClient) ShortenBatch(ctx context.Context, in *ShortenBatchReque
 	return out, nil
 }
 
+func (c *handlersClient) StreamUserURLs(ctx context.Context, in *StreamUserURLsRequest, opts ...grpc.CallOption) (Handlers_StreamUserURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Handlers_ServiceDesc.Streams[0], Handlers_StreamUserURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &handlersStreamUserURLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Handlers_StreamUserURLsClient interface {
+	Recv() (*UserURLs, error)
+	grpc.ClientStream
+}
+
+type handlersStreamUserURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *handlersStreamUserURLsClient) Recv() (*UserURLs, error) {
+	m := new(UserURLs)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *handlersClient) WatchUserURLs(ctx context.Context, in *WatchUserURLsRequest, opts ...grpc.CallOption) (Handlers_WatchUserURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Handlers_ServiceDesc.Streams[1], Handlers_WatchUserURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &handlersWatchUserURLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Handlers_WatchUserURLsClient interface {
+	Recv() (*URLEvent, error)
+	grpc.ClientStream
+}
+
+type handlersWatchUserURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *handlersWatchUserURLsClient) Recv() (*URLEvent, error) {
+	m := new(URLEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *handlersClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error) {
+	out := new(UpdateURLResponse)
+	err := c.cc.Invoke(ctx, Handlers_UpdateURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *handlersClient) RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*RestoreURLResponse, error) {
+	out := new(RestoreURLResponse)
+	err := c.cc.Invoke(ctx, Handlers_RestoreURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // HandlersServer is the server API for Handlers service.
 // All implementations must embed UnimplementedHandlersServer
 // for forward compatibility
@@ -99,6 +189,10 @@ type HandlersServer interface {
 	GetUserURLs(context.Context, *GetUserURLsRequest) (*GetUserURLsResponse, error)
 	DeleteURLs(context.Context, *DeleteURLsRequest) (*DeleteURLsResponse, error)
 	ShortenBatch(context.Context, *ShortenBatchRequest) (*ShortenBatchResponse, error)
+	StreamUserURLs(*StreamUserURLsRequest, Handlers_StreamUserURLsServer) error
+	WatchUserURLs(*WatchUserURLsRequest, Handlers_WatchUserURLsServer) error
+	UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error)
+	RestoreURL(context.Context, *RestoreURLRequest) (*RestoreURLResponse, error)
 	mustEmbedUnimplementedHandlersServer()
 }
 
@@ -121,6 +215,18 @@ func (UnimplementedHandlersServer) DeleteURLs(context.Context, *DeleteURLsReques
 func (UnimplementedHandlersServer) ShortenBatch(context.Context, *ShortenBatchRequest) (*ShortenBatchResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ShortenBatch not implemented")
 }
+func (UnimplementedHandlersServer) StreamUserURLs(*StreamUserURLsRequest, Handlers_StreamUserURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUserURLs not implemented")
+}
+func (UnimplementedHandlersServer) WatchUserURLs(*WatchUserURLsRequest, Handlers_WatchUserURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchUserURLs not implemented")
+}
+func (UnimplementedHandlersServer) UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
+func (UnimplementedHandlersServer) RestoreURL(context.Context, *RestoreURLRequest) (*RestoreURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreURL not implemented")
+}
 func (UnimplementedHandlersServer) mustEmbedUnimplementedHandlersServer() {}
 
 // UnsafeHandlersServer may be embedded to opt out of forward compatibility for this service.
@@ -224,6 +330,84 @@ func _Handlers_ShortenBatch_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Handlers_StreamUserURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamUserURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HandlersServer).StreamUserURLs(m, &handlersStreamUserURLsServer{stream})
+}
+
+type Handlers_StreamUserURLsServer interface {
+	Send(*UserURLs) error
+	grpc.ServerStream
+}
+
+type handlersStreamUserURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *handlersStreamUserURLsServer) Send(m *UserURLs) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Handlers_WatchUserURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchUserURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HandlersServer).WatchUserURLs(m, &handlersWatchUserURLsServer{stream})
+}
+
+type Handlers_WatchUserURLsServer interface {
+	Send(*URLEvent) error
+	grpc.ServerStream
+}
+
+type handlersWatchUserURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *handlersWatchUserURLsServer) Send(m *URLEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Handlers_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlersServer).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Handlers_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlersServer).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Handlers_RestoreURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlersServer).RestoreURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Handlers_RestoreURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlersServer).RestoreURL(ctx, req.(*RestoreURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Handlers_ServiceDesc is the grpc.ServiceDesc for Handlers service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -251,7 +435,26 @@ var Handlers_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ShortenBatch",
 			Handler:    _Handlers_ShortenBatch_Handler,
 		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _Handlers_UpdateURL_Handler,
+		},
+		{
+			MethodName: "RestoreURL",
+			Handler:    _Handlers_RestoreURL_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUserURLs",
+			Handler:       _Handlers_StreamUserURLs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchUserURLs",
+			Handler:       _Handlers_WatchUserURLs_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/grpc.proto",
 }
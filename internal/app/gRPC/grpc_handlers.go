@@ -2,8 +2,15 @@ package grpc
 
 import (
 	context "context"
+	"errors"
+	"net"
+	"sort"
+	"time"
 
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/ratelimit"
 	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/session"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
@@ -13,26 +20,25 @@ import (
 type GrpcHandlers struct {
 	UnimplementedHandlersServer
 
-	service *service.Service
+	service        *service.Service
+	trustedProxies []*net.IPNet
 }
 
 // NewGrpcHandlers - конструктор
 func NewGrpcHandlers(service *service.Service) *GrpcHandlers {
+	// список прокси уже проверен при создании RateLimitInterceptor,
+	// при ошибке метаданные с адресом клиента не учитываются
+	trustedProxies, _ := ratelimit.ParseNetworks(service.Config.TrustedProxies)
 	return &GrpcHandlers{
-		service: service,
+		service:        service,
+		trustedProxies: trustedProxies,
 	}
 }
 
-// GetUserFromContext возвращает значение токена пользователя из контекста
+// GetUserFromContext возвращает идентификатор пользователя,
+// которого интерсептор авторизации положил в контекст
 func GetUserFromContext(ctx context.Context) string {
-	var user string
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		values := md.Get("User")
-		if len(values) > 0 {
-			user = values[0]
-		}
-	}
-	return user
+	return session.UserFromContext(ctx)
 }
 
 // ShortenURL возвращает сокращенный токен
@@ -40,25 +46,47 @@ func (g *GrpcHandlers) ShortenURL(ctx context.Context, in *ShortenURLRequest) (
 	*ShortenURLResponse, error) {
 	var response ShortenURLResponse
 
-	token, err := g.service.AddLink(ctx, "", in.LongURL, GetUserFromContext(ctx))
+	expires, err := service.ParseExpiry(in.ExpiresAt, in.Ttl)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	token, err := g.service.AddLink(ctx, in.Alias, in.LongURL, GetUserFromContext(ctx), expires)
 	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidAlias), errors.Is(err, models.ErrReservedAlias):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, models.ErrShortURLAlreadyExist):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
 		return nil, status.Errorf(codes.Internal, "error in adding link to storage")
 	}
 	response.Token = token
 	return &response, nil
 }
 
-// DeleteURLs принимает строку с токенами и запускает горутину на удаление записей
+// DeleteURLs принимает строку с токенами и запускает горутину на удаление записей.
+// С флагом wait удаляет записи сразу и возвращает результат по каждому токену
 func (g *GrpcHandlers) DeleteURLs(ctx context.Context, in *DeleteURLsRequest) (
 	*DeleteURLsResponse, error) {
 	var response DeleteURLsResponse
 
-	go g.service.AddDeletedTokens(in.Token, GetUserFromContext(ctx))
-
+	if !in.Wait {
+		go g.service.AddDeletedTokens(in.Token, GetUserFromContext(ctx))
+		return &response, nil
+	}
+	results, err := g.service.DeleteURLs(ctx, in.Token, GetUserFromContext(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error in deleting links from storage")
+	}
+	for _, r := range results {
+		response.Results = append(response.Results, &DeleteResult{ShortURL: r.ShortURL, Status: r.Status})
+	}
 	return &response, nil
 }
 
-// GetFullURL возвращает исходный URL
+// GetFullURL возвращает исходный URL. Для удаленной или истекшей ссылки
+// возвращается codes.FailedPrecondition, как 410 Gone в HTTP
 func (g *GrpcHandlers) GetFullURL(ctx context.Context, in *GetFullURLRequest) (
 	*GetFullURLResponse, error) {
 	var response GetFullURLResponse
@@ -66,20 +94,201 @@ func (g *GrpcHandlers) GetFullURL(ctx context.Context, in *GetFullURLRequest) (
 	lToken := g.service.GetLongToken(in.Token)
 	longURL, err := g.service.GetLongURL(ctx, lToken)
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, models.ErrLinkNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, models.ErrLinkDeleted), errors.Is(err, models.ErrLinkExpired):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "error in getting link from storage")
 	}
+
+	// переход сохраняется в статистику асинхронно, как редирект в HTTP.
+	// Referer в gRPC не передается
+	md, _ := metadata.FromIncomingContext(ctx)
+	g.service.RecordClick(lToken, "", firstValue(md, "user-agent"), clientIP(ctx, g.trustedProxies))
 	response.LongURL = longURL
 	return &response, nil
 }
 
-// getUserURLs возвращает все URL, сокращенным пользвателем
+// GetUserURLs возвращает все URL, сокращенные пользователем
 func (g *GrpcHandlers) GetUserURLs(ctx context.Context, in *GetUserURLsRequest) (
 	*GetUserURLsResponse, error) {
 	var response GetUserURLsResponse
 
-	_, err := g.service.GetAllURLS(ctx, GetUserFromContext(ctx))
+	links, err := g.service.GetAllURLS(ctx, GetUserFromContext(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error in getting links from storage")
+	}
+	response.Urls = make([]*UserURLs, 0, len(links))
+	for short, long := range links {
+		response.Urls = append(response.Urls, &UserURLs{ShortURL: short, LongURL: long})
+	}
+	sort.Slice(response.Urls, func(i, j int) bool {
+		return response.Urls[i].ShortURL < response.Urls[j].ShortURL
+	})
+	return &response, nil
+}
+
+// StreamUserURLs передает ссылки пользователя потоком, выбирая их
+// из хранилища страницами по pageSize штук
+func (g *GrpcHandlers) StreamUserURLs(in *StreamUserURLsRequest, stream Handlers_StreamUserURLsServer) error {
+	ctx := stream.Context()
+	user := GetUserFromContext(ctx)
+
+	var after string
+	for {
+		links, err := g.service.GetUserURLsPage(ctx, user, after, int(in.PageSize))
+		if err != nil {
+			return status.Errorf(codes.Internal, "error in getting links from storage")
+		}
+		for _, link := range links {
+			if err := stream.Send(&UserURLs{ShortURL: link.ShortURL, LongURL: link.LongURL}); err != nil {
+				return err
+			}
+		}
+		if len(links) == 0 {
+			return nil
+		}
+		after = links[len(links)-1].ShortURL
+	}
+}
+
+// UpdateURL меняет исходный URL ссылки пользователя или, если задан revision,
+// возвращает URL из этой ревизии. В ответе - история ревизий ссылки
+func (g *GrpcHandlers) UpdateURL(ctx context.Context, in *UpdateURLRequest) (
+	*UpdateURLResponse, error) {
+	if (in.LongURL == "") == (in.Revision == 0) {
+		return nil, status.Error(codes.InvalidArgument, "either longURL or revision is required")
+	}
+
+	var link models.LinkRevisions
+	var err error
+	user := GetUserFromContext(ctx)
+	if in.Revision != 0 {
+		link, err = g.service.RollbackURL(ctx, in.Token, user, int(in.Revision))
+	} else {
+		link, err = g.service.UpdateURL(ctx, in.Token, user, in.LongURL)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidURL):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, models.ErrLinkNotFound), errors.Is(err, models.ErrRevisionNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, models.ErrLinkDeleted):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, models.ErrorAlreadyExist):
+			return nil, status.Errorf(codes.AlreadyExists, "%s: %s", err.Error(), link.ShortURL)
+		}
+		return nil, status.Errorf(codes.Internal, "error in updating link in storage")
+	}
+
+	response := UpdateURLResponse{
+		ShortURL:  link.ShortURL,
+		LongURL:   link.LongURL,
+		Revisions: make([]*Revision, 0, len(link.Revisions)),
+	}
+	for _, r := range link.Revisions {
+		response.Revisions = append(response.Revisions, &Revision{
+			Revision:   int32(r.Revision),
+			LongURL:    r.LongURL,
+			ReplacedAt: r.ReplacedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	return &response, nil
+}
+
+// RestoreURL восстанавливает удаленную ссылку пользователя
+func (g *GrpcHandlers) RestoreURL(ctx context.Context, in *RestoreURLRequest) (
+	*RestoreURLResponse, error) {
+	err := g.service.RestoreURL(ctx, in.Token, GetUserFromContext(ctx))
 	if err != nil {
-		return nil, err
+		if errors.Is(err, models.ErrLinkNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "error in restoring link in storage")
+	}
+	return &RestoreURLResponse{}, nil
+}
+
+// WatchUserURLs передает события о создании, изменении, удалении и восстановлении ссылок пользователя,
+// пока клиент не закроет поток
+func (g *GrpcHandlers) WatchUserURLs(in *WatchUserURLsRequest, stream Handlers_WatchUserURLsServer) error {
+	ctx := stream.Context()
+	events, cancel := g.service.WatchUserURLs(GetUserFromContext(ctx))
+	defer cancel()
+
+	// заголовки отправляются после подписки: получив их, клиент знает,
+	// что не пропустит следующие события
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// подписчик не успевал читать события или сервис остановлен
+				return status.Error(codes.Unavailable, "event stream closed")
+			}
+			if err := stream.Send(newURLEvent(event)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// newURLEvent переводит событие сервиса в сообщение gRPC
+func newURLEvent(event models.LinkEvent) *URLEvent {
+	eventType := EventType_UNKNOWN
+	switch event.Type {
+	case models.EventCreated:
+		eventType = EventType_CREATED
+	case models.EventDeleted:
+		eventType = EventType_DELETED
+	case models.EventUpdated:
+		eventType = EventType_UPDATED
+	case models.EventRestored:
+		eventType = EventType_RESTORED
+	}
+	return &URLEvent{
+		Type:     eventType,
+		ShortURL: event.ShortURL,
+		LongURL:  event.LongURL,
+		Time:     event.Time.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// ShortenBatch сокращает пакет URL. Поле id из запроса возвращается
+// в ответе без изменений, ошибки отдельных URL передаются в поле error
+func (g *GrpcHandlers) ShortenBatch(ctx context.Context, in *ShortenBatchRequest) (
+	*ShortenBatchResponse, error) {
+	var response ShortenBatchResponse
+
+	batchReq := make([]models.BatchReq, 0, len(in.Batch))
+	for _, item := range in.Batch {
+		batchReq = append(batchReq, models.BatchReq{
+			CorrID:    item.Id,
+			URL:       item.LongURL,
+			ExpiresAt: item.ExpiresAt,
+			TTL:       item.Ttl,
+		})
+	}
+
+	batchResp, err := g.service.ShortenBatch(ctx, batchReq, GetUserFromContext(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error in adding links to storage")
+	}
+	response.Batch = make([]*BatchResp, 0, len(batchResp))
+	for _, item := range batchResp {
+		response.Batch = append(response.Batch, &BatchResp{
+			Id:       item.CorrID,
+			ShortURL: item.ShortURL,
+			Error:    item.Error,
+		})
 	}
 	return &response, nil
 }
@@ -0,0 +1,681 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/ratelimit"
+	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/storage/memory"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/config/utils"
+	"example.com/shortener/internal/logger"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testBaseURL = "http://localhost:8080/"
+
+// newTestClient поднимает gRPC сервер поверх bufconn и возвращает клиента к нему
+func newTestClient(t *testing.T) HandlersClient {
+	t.Helper()
+	return newTestClientWithConfig(t, config.Config{BaseURL: testBaseURL})
+}
+
+// newTestClientWithConfig поднимает gRPC сервер с заданной конфигурацией
+func newTestClientWithConfig(t *testing.T, cfg config.Config) HandlersClient {
+	t.Helper()
+	client, _ := newTestServer(t, cfg)
+	return client
+}
+
+// newTestServer поднимает gRPC сервер и возвращает клиента и сервис, с которым он работает
+func newTestServer(t *testing.T, cfg config.Config) (HandlersClient, *service.Service) {
+	t.Helper()
+	conn, serv := newTestConn(t, cfg)
+	return NewHandlersClient(conn), serv
+}
+
+// newTestConn поднимает gRPC сервер и возвращает соединение с ним
+func newTestConn(t *testing.T, cfg config.Config) (*grpc.ClientConn, *service.Service) {
+	t.Helper()
+	log := logger.InitLog()
+	serv := service.New(cfg, memory.New(cfg, log), log)
+	t.Cleanup(func() { serv.Close() })
+
+	lis := bufconn.Listen(1024 * 1024)
+	authFunc := NewAuthFunc(serv)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			auth.UnaryServerInterceptor(authFunc),
+			RateLimitInterceptor(ratelimit.NewLimits(cfg), nil),
+		),
+		grpc.StreamInterceptor(auth.StreamServerInterceptor(authFunc)),
+	)
+	RegisterHandlersServer(server, NewGrpcHandlers(serv))
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	t.Cleanup(stopHealth)
+	healthpb.RegisterHealthServer(server, NewHealthServer(healthCtx, serv, time.Minute))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	tokens := &tokenStore{}
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(tokens.unary),
+		grpc.WithStreamInterceptor(tokens.stream))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, serv
+}
+
+// tokenStore ведет себя как клиент gRPC: запоминает токен из заголовка
+// ответа user и передает его в следующих вызовах, если вызов не передает
+// свой токен или API ключ
+type tokenStore struct {
+	mu    sync.Mutex
+	token string
+}
+
+func (ts *tokenStore) outgoing(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token == "" || len(md.Get(userHeader)) > 0 || len(md.Get("authorization")) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, userHeader, ts.token)
+}
+
+func (ts *tokenStore) save(header metadata.MD) {
+	if values := header.Get(userHeader); len(values) > 0 {
+		ts.mu.Lock()
+		ts.token = values[0]
+		ts.mu.Unlock()
+	}
+}
+
+func (ts *tokenStore) unary(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var header metadata.MD
+	err := invoker(ts.outgoing(ctx), method, req, reply, cc, append(opts, grpc.Header(&header))...)
+	ts.save(header)
+	return err
+}
+
+func (ts *tokenStore) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ts.outgoing(ctx), desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenStream{ClientStream: stream, tokens: ts}, nil
+}
+
+// tokenStream запоминает токен из заголовка, когда он получен: заголовок
+// стрима приходит вместе с первым сообщением или по запросу Header
+type tokenStream struct {
+	grpc.ClientStream
+	tokens *tokenStore
+}
+
+func (s *tokenStream) Header() (metadata.MD, error) {
+	header, err := s.ClientStream.Header()
+	s.tokens.save(header)
+	return header, err
+}
+
+func (s *tokenStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if header, hErr := s.ClientStream.Header(); hErr == nil {
+		s.tokens.save(header)
+	}
+	return err
+}
+
+func TestShortenURL(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	resp, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(resp.Token, testBaseURL))
+
+	resp, err = client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://ya.ru", Alias: "my-alias"})
+	require.NoError(t, err)
+	assert.Equal(t, testBaseURL+"my-alias", resp.Token)
+
+	tests := []struct {
+		name string
+		req  *ShortenURLRequest
+		code codes.Code
+	}{
+		{
+			name: "alias already exists",
+			req:  &ShortenURLRequest{LongURL: "https://ya.ru", Alias: "my-alias"},
+			code: codes.AlreadyExists,
+		},
+		{
+			name: "invalid alias",
+			req:  &ShortenURLRequest{LongURL: "https://ya.ru", Alias: "a/b"},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "reserved alias",
+			req:  &ShortenURLRequest{LongURL: "https://ya.ru", Alias: "api"},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "ttl with expiration date",
+			req:  &ShortenURLRequest{LongURL: "https://ya.ru", Ttl: 60, ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)},
+			code: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.ShortenURL(ctx, tt.req)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestGetFullURL(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru", Alias: "practicum"})
+	require.NoError(t, err)
+
+	resp, err := client.GetFullURL(ctx, &GetFullURLRequest{Token: "practicum"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://practicum.yandex.ru", resp.LongURL)
+
+	_, err = client.GetFullURL(ctx, &GetFullURLRequest{Token: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestGetUserURLs(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	short, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru"})
+	require.NoError(t, err)
+
+	resp, err := client.GetUserURLs(ctx, &GetUserURLsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Urls, 1)
+	assert.Equal(t, short.Token, resp.Urls[0].ShortURL)
+	assert.Equal(t, "https://practicum.yandex.ru", resp.Urls[0].LongURL)
+}
+
+func TestStreamUserURLs(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	var want []string
+	for i := 0; i < 7; i++ {
+		resp, err := client.ShortenURL(ctx, &ShortenURLRequest{
+			LongURL: "https://practicum.yandex.ru/" + utils.RandStringBytes(8),
+		})
+		require.NoError(t, err)
+		want = append(want, resp.Token)
+	}
+	sort.Strings(want)
+
+	// страницы меньше числа ссылок, поток собирается из нескольких выборок
+	stream, err := client.StreamUserURLs(ctx, &StreamUserURLsRequest{PageSize: 3})
+	require.NoError(t, err)
+	var got []string
+	for {
+		link, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, link.ShortURL)
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestWatchUserURLs(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchUserURLs(ctx, &WatchUserURLsRequest{})
+	require.NoError(t, err)
+	// заголовки приходят после подписки на события
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	short, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru", Alias: "watched"})
+	require.NoError(t, err)
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, EventType_CREATED, event.Type)
+	assert.Equal(t, short.Token, event.ShortURL)
+	assert.Equal(t, "https://practicum.yandex.ru", event.LongURL)
+
+	_, err = client.UpdateURL(ctx, &UpdateURLRequest{Token: "watched", LongURL: "https://go.dev"})
+	require.NoError(t, err)
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, EventType_UPDATED, event.Type)
+	assert.Equal(t, "https://go.dev", event.LongURL)
+
+	_, err = client.DeleteURLs(ctx, &DeleteURLsRequest{Token: []string{"watched"}})
+	require.NoError(t, err)
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, EventType_DELETED, event.Type)
+	assert.Equal(t, short.Token, event.ShortURL)
+}
+
+func TestDeleteURLs(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru", Alias: "to-delete"})
+	require.NoError(t, err)
+
+	_, err = client.DeleteURLs(ctx, &DeleteURLsRequest{Token: []string{"to-delete"}})
+	require.NoError(t, err)
+
+	// удаление выполняется асинхронно
+	assert.Eventually(t, func() bool {
+		_, err := client.GetFullURL(ctx, &GetFullURLRequest{Token: "to-delete"})
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestGetFullURLRecordsClick(t *testing.T) {
+	client, serv := newTestServer(t, config.Config{BaseURL: testBaseURL})
+	ctx := context.Background()
+
+	_, err := serv.AddLink(ctx, "clicked", "https://go.dev", "user", time.Time{})
+	require.NoError(t, err)
+	_, err = client.GetFullURL(ctx, &GetFullURLRequest{Token: "clicked"})
+	require.NoError(t, err)
+
+	// переходы сохраняются пачками в фоне
+	assert.Eventually(t, func() bool {
+		stats, err := serv.GetLinkStats(ctx, "clicked", "user", 1)
+		return err == nil && stats.Total == 1
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestGetFullURLErrors(t *testing.T) {
+	client, serv := newTestServer(t, config.Config{BaseURL: testBaseURL})
+	ctx := context.Background()
+
+	_, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru", Alias: "deleted"})
+	require.NoError(t, err)
+	_, err = client.DeleteURLs(ctx, &DeleteURLsRequest{Token: []string{"deleted"}, Wait: true})
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	_, err = serv.AddLink(ctx, "expired", "https://go.dev", "user", expiresAt)
+	require.NoError(t, err)
+	time.Sleep(time.Until(expiresAt))
+
+	tests := []struct {
+		token string
+		code  codes.Code
+	}{
+		{token: "unknown", code: codes.NotFound},
+		{token: "deleted", code: codes.FailedPrecondition},
+		{token: "expired", code: codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			_, err := client.GetFullURL(ctx, &GetFullURLRequest{Token: tt.token})
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestDeleteURLsWait(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru", Alias: "to-delete"})
+	require.NoError(t, err)
+
+	resp, err := client.DeleteURLs(ctx, &DeleteURLsRequest{Token: []string{"to-delete", "to-delete", "unknown"}, Wait: true})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, testBaseURL+"to-delete", resp.Results[0].ShortURL)
+	assert.Equal(t, models.DeleteDeleted, resp.Results[0].Status)
+	assert.Equal(t, models.DeleteAlreadyDeleted, resp.Results[1].Status)
+	assert.Equal(t, models.DeleteNotFound, resp.Results[2].Status)
+
+	// ссылка удалена к моменту ответа
+	_, err = client.GetFullURL(ctx, &GetFullURLRequest{Token: "to-delete"})
+	assert.Error(t, err)
+}
+
+func TestRestoreURL(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru", Alias: "to-restore"})
+	require.NoError(t, err)
+	_, err = client.DeleteURLs(ctx, &DeleteURLsRequest{Token: []string{"to-restore"}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := client.GetFullURL(ctx, &GetFullURLRequest{Token: "to-restore"})
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = client.RestoreURL(ctx, &RestoreURLRequest{Token: "to-restore"})
+	require.NoError(t, err)
+	resp, err := client.GetFullURL(ctx, &GetFullURLRequest{Token: "to-restore"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://practicum.yandex.ru", resp.LongURL)
+
+	_, err = client.RestoreURL(ctx, &RestoreURLRequest{Token: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUpdateURL(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	short, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru", Alias: "editable"})
+	require.NoError(t, err)
+	_, err = client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://ya.ru", Alias: "taken"})
+	require.NoError(t, err)
+
+	resp, err := client.UpdateURL(ctx, &UpdateURLRequest{Token: "editable", LongURL: "https://go.dev"})
+	require.NoError(t, err)
+	assert.Equal(t, short.Token, resp.ShortURL)
+	assert.Equal(t, "https://go.dev", resp.LongURL)
+	require.Len(t, resp.Revisions, 1)
+	assert.Equal(t, int32(1), resp.Revisions[0].Revision)
+	assert.Equal(t, "https://practicum.yandex.ru", resp.Revisions[0].LongURL)
+
+	full, err := client.GetFullURL(ctx, &GetFullURLRequest{Token: "editable"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.dev", full.LongURL)
+
+	// откат тоже попадает в историю
+	resp, err = client.UpdateURL(ctx, &UpdateURLRequest{Token: "editable", Revision: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "https://practicum.yandex.ru", resp.LongURL)
+	require.Len(t, resp.Revisions, 2)
+	assert.Equal(t, "https://go.dev", resp.Revisions[1].LongURL)
+
+	tests := []struct {
+		name string
+		req  *UpdateURLRequest
+		code codes.Code
+	}{
+		{
+			name: "neither url nor revision",
+			req:  &UpdateURLRequest{Token: "editable"},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "invalid url",
+			req:  &UpdateURLRequest{Token: "editable", LongURL: "not a url"},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "unknown link",
+			req:  &UpdateURLRequest{Token: "unknown", LongURL: "https://go.dev"},
+			code: codes.NotFound,
+		},
+		{
+			name: "unknown revision",
+			req:  &UpdateURLRequest{Token: "editable", Revision: 5},
+			code: codes.NotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.UpdateURL(ctx, tt.req)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestShortenBatch(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	resp, err := client.ShortenBatch(ctx, &ShortenBatchRequest{Batch: []*BatchReq{
+		{Id: "1", LongURL: "https://practicum.yandex.ru"},
+		{Id: "2", LongURL: "not a url"},
+		{Id: "3", LongURL: "https://practicum.yandex.ru"},
+		{Id: "4", LongURL: "ftp://files.example.com"},
+		{Id: "5", LongURL: "https://ya.ru", Ttl: -1},
+		{Id: "6", LongURL: "https://ya.ru", Ttl: 60},
+	}})
+	require.NoError(t, err)
+	require.Len(t, resp.Batch, 6)
+
+	// ответ приходит в порядке запроса с теми же id
+	for i, item := range resp.Batch {
+		assert.Equal(t, string(rune('1'+i)), item.Id)
+	}
+
+	assert.Empty(t, resp.Batch[0].Error)
+	assert.True(t, strings.HasPrefix(resp.Batch[0].ShortURL, testBaseURL))
+	assert.Equal(t, models.ErrInvalidURL.Error(), resp.Batch[1].Error)
+	assert.Equal(t, models.ErrDuplicateURL.Error(), resp.Batch[2].Error)
+	assert.Equal(t, models.ErrInvalidURL.Error(), resp.Batch[3].Error)
+	assert.Equal(t, models.ErrInvalidExpiry.Error(), resp.Batch[4].Error)
+	assert.Empty(t, resp.Batch[5].Error)
+	assert.NotEqual(t, resp.Batch[0].ShortURL, resp.Batch[5].ShortURL)
+
+	full, err := client.GetFullURL(ctx, &GetFullURLRequest{
+		Token: strings.TrimPrefix(resp.Batch[0].ShortURL, testBaseURL),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://practicum.yandex.ru", full.LongURL)
+}
+
+func TestRateLimit(t *testing.T) {
+	client := newTestClientWithConfig(t, config.Config{
+		BaseURL:                testBaseURL,
+		RateLimitCreate:        0.01,
+		RateLimitCreateBurst:   2,
+		RateLimitRedirect:      0.01,
+		RateLimitRedirectBurst: 1,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		var header metadata.MD
+		_, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru/" + utils.RandStringBytes(8)},
+			grpc.Header(&header))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2"}, header.Get("ratelimit-limit"))
+	}
+
+	var header metadata.MD
+	_, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://ya.ru"}, grpc.Header(&header))
+	st := status.Convert(err)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.NotEmpty(t, header.Get("retry-after"))
+	require.Len(t, st.Details(), 1)
+	retry, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Greater(t, retry.RetryDelay.AsDuration(), time.Duration(0))
+
+	// у переходов отдельный лимит
+	_, err = client.GetFullURL(ctx, &GetFullURLRequest{Token: "unknown"})
+	assert.NotEqual(t, codes.ResourceExhausted, status.Code(err))
+	_, err = client.GetFullURL(ctx, &GetFullURLRequest{Token: "unknown"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	client, serv := newTestServer(t, config.Config{BaseURL: testBaseURL})
+	apiKey, key, err := serv.IssueAPIKey(context.Background(), "owner", "backend")
+	require.NoError(t, err)
+	assert.Equal(t, "owner", apiKey.User)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+	short, err := client.ShortenURL(ctx, &ShortenURLRequest{LongURL: "https://practicum.yandex.ru"})
+	require.NoError(t, err)
+
+	// ссылка принадлежит владельцу ключа
+	urls, err := serv.GetAllURLS(context.Background(), "owner")
+	require.NoError(t, err)
+	assert.Contains(t, urls, short.Token)
+	resp, err := client.GetUserURLs(ctx, &GetUserURLsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Urls, 1)
+
+	require.NoError(t, serv.RevokeAPIKey(context.Background(), apiKey.ID))
+	_, err = client.GetUserURLs(ctx, &GetUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic "+key)
+	_, err = client.GetUserURLs(ctx, &GetUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	sign := func(kid string, secret string) string {
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+			Subject:   "user",
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Hour).Unix(),
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString([]byte(secret))
+		require.NoError(t, err)
+		return signed
+	}
+	call := func(client HandlersClient, token string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "User", token)
+		_, err := client.GetUserURLs(ctx, &GetUserURLsRequest{})
+		return err
+	}
+
+	client := newTestClientWithConfig(t, config.Config{BaseURL: testBaseURL, JWTKeys: "new:secret2,old:secret1"})
+	assert.NoError(t, call(client, sign("new", "secret2")))
+	// токены, подписанные старым ключом, принимаются, пока он в наборе
+	assert.NoError(t, call(client, sign("old", "secret1")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(client, sign("gone", "secret1"))))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(client, sign("new", "secret1"))))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(client, sign("", "secret"))))
+
+	// токены без kid проверяются прежним ключом, только если он задан явно
+	client = newTestClient(t)
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(client, sign("", "secret"))))
+	client = newTestClientWithConfig(t, config.Config{BaseURL: testBaseURL, JWTKeys: "new:secret2,default:secret"})
+	assert.NoError(t, call(client, sign("", "secret")))
+}
+
+func TestUserIdentity(t *testing.T) {
+	client := newTestClient(t)
+	var header metadata.MD
+	_, err := client.ShortenURL(context.Background(),
+		&ShortenURLRequest{LongURL: "https://practicum.yandex.ru"}, grpc.Header(&header))
+	require.NoError(t, err)
+	// новому пользователю выдается токен
+	require.Len(t, header.Get(userHeader), 1)
+	token := header.Get(userHeader)[0]
+
+	// по токену вызов выполняется от имени того же пользователя
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "User", token)
+	header = nil
+	resp, err := client.GetUserURLs(ctx, &GetUserURLsRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Len(t, resp.Urls, 1)
+	assert.Equal(t, "https://practicum.yandex.ru", resp.Urls[0].LongURL)
+	// свежий токен не перевыпускается
+	assert.Empty(t, header.Get(userHeader))
+
+	// другой пользователь ссылок не видит
+	other := newTestClient(t)
+	resp, err = other.GetUserURLs(context.Background(), &GetUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Urls)
+}
+
+func TestTokenClaims(t *testing.T) {
+	sign := func(claims jwt.StandardClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = "k"
+		signed, err := token.SignedString([]byte("secret1"))
+		require.NoError(t, err)
+		return signed
+	}
+	call := func(token string) (metadata.MD, error) {
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "User", token)
+		client := newTestClientWithConfig(t, config.Config{BaseURL: testBaseURL, JWTKeys: "k:secret1"})
+		_, err := client.GetUserURLs(ctx, &GetUserURLsRequest{}, grpc.Header(&header))
+		return header, err
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims jwt.StandardClaims
+	}{
+		{name: "no subject", claims: jwt.StandardClaims{IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}},
+		{name: "no iat", claims: jwt.StandardClaims{Subject: "user", ExpiresAt: now.Add(time.Hour).Unix()}},
+		{name: "no exp", claims: jwt.StandardClaims{Subject: "user", IssuedAt: now.Unix()}},
+		{name: "expired", claims: jwt.StandardClaims{Subject: "user",
+			IssuedAt: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()}},
+		{name: "issued in the future", claims: jwt.StandardClaims{Subject: "user",
+			IssuedAt: now.Add(time.Hour).Unix(), ExpiresAt: now.Add(2 * time.Hour).Unix()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := call(sign(tt.claims))
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+
+	// токен, у которого прошла половина срока, перевыпускается
+	header, err := call(sign(jwt.StandardClaims{Subject: "user",
+		IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}))
+	require.NoError(t, err)
+	assert.Len(t, header.Get(userHeader), 1)
+}
+
+func TestHealth(t *testing.T) {
+	conn, _ := newTestConn(t, config.Config{BaseURL: testBaseURL})
+	client := healthpb.NewHealthClient(conn)
+
+	for _, service := range []string{"", "grpc.Handlers"} {
+		var header metadata.MD
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service}, grpc.Header(&header))
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+		// проверки состояния не заводят пользователей
+		assert.Empty(t, header.Get(userHeader))
+	}
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
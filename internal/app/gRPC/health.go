@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/service"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// NewHealthServer возвращает стандартный сервис grpc.health.v1, состояние
+// которого раз в interval обновляется по Service.Health, пока не отменен ctx.
+// Сервис считается работающим (SERVING), пока доступно хранилище.
+// Состояние выставляется и для всего сервера (""), и для grpc.Handlers
+func NewHealthServer(ctx context.Context, serv *service.Service, interval time.Duration) *health.Server {
+	hs := health.NewServer()
+	update := func() {
+		status := healthpb.HealthCheckResponse_SERVING
+		if serv.Health(ctx).Status == models.HealthUnavailable {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		hs.SetServingStatus("", status)
+		hs.SetServingStatus(Handlers_ServiceDesc.ServiceName, status)
+	}
+	update()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				update()
+			case <-ctx.Done():
+				// при остановке клиенты Watch получают NOT_SERVING
+				hs.Shutdown()
+				return
+			}
+		}
+	}()
+	return hs
+}
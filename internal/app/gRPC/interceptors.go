@@ -2,52 +2,168 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/ratelimit"
+	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/session"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
-// jwtToken структура для парсинга jwt токенов
-type jwtToken struct {
-	Login string
-	jwt.StandardClaims
-}
+// healthMethodPrefix - префикс методов сервиса grpc.health.v1
+const healthMethodPrefix = "/grpc.health.v1.Health/"
 
-// AuthInterceptor функция для интерсептора, которая проверяет jwt токены
-func AuthInterceptor(ctx context.Context) (context.Context, error) {
-	var user string
-	var tk jwtToken
+// userHeader - метаданные, в которых клиент передает jwt токен пользователя,
+// а сервер возвращает новый
+const userHeader = "user"
 
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		values := md.Get("User")
-		if len(values) > 0 {
-			user = values[0]
+// AuthInterceptor возвращает функцию для интерсептора, которая определяет
+// пользователя вызова по jwt токену из метаданных User и кладет его
+// идентификатор в контекст. Если токена нет, заводится новый пользователь.
+// Токен нового пользователя, как и новый токен взамен того, у которого прошла
+// половина срока, возвращается в заголовке ответа User
+func AuthInterceptor(tokens *session.Tokens) func(ctx context.Context) (context.Context, error) {
+	return func(ctx context.Context) (context.Context, error) {
+		var user string
+		renew := true
+		if values := metadata.ValueFromIncomingContext(ctx, userHeader); len(values) > 0 {
+			var err error
+			user, renew, err = tokens.Verify(values[0])
+			if err != nil {
+				return ctx, status.Error(codes.Unauthenticated, err.Error())
+			}
 		} else {
-			token := jwt.NewWithClaims(jwt.GetSigningMethod("HS256"), tk)
-			tokenString, err := token.SignedString([]byte("secret"))
+			var err error
+			user, err = session.NewUser()
 			if err != nil {
-				return ctx, status.Errorf(codes.Unauthenticated, "error while creating token")
+				return ctx, status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		if renew {
+			token, err := tokens.Issue(user)
+			if err != nil {
+				return ctx, status.Errorf(codes.Internal, "error while creating token: %v", err)
+			}
+			if err := grpc.SetHeader(ctx, metadata.Pairs(userHeader, token)); err != nil {
+				return ctx, status.Error(codes.Internal, err.Error())
 			}
-			md := metadata.New(map[string]string{"User": tokenString})
-			ctx = metadata.NewOutgoingContext(context.Background(), md)
-			return ctx, nil
 		}
+		return session.WithUser(ctx, user), nil
+	}
+}
 
-		token, err := jwt.ParseWithClaims(user, tk, func(token *jwt.Token) (interface{}, error) {
-			return []byte("secret"), nil
-		})
+// NewAuthFunc возвращает функцию авторизации для интерсептора. Вызовы
+// с API ключом в метаданных authorization (Bearer) выполняются от имени
+// владельца ключа, остальные проверяются AuthInterceptor. Проверки
+// grpc.health.v1 выполняются без пользователя
+func NewAuthFunc(serv *service.Service) func(ctx context.Context) (context.Context, error) {
+	tokenAuth := AuthInterceptor(session.NewTokens(serv.JWTKeys(), serv.Config))
+	return func(ctx context.Context) (context.Context, error) {
+		if method, ok := grpc.Method(ctx); ok && strings.HasPrefix(method, healthMethodPrefix) {
+			return ctx, nil
+		}
+		if len(metadata.ValueFromIncomingContext(ctx, "authorization")) == 0 {
+			return tokenAuth(ctx)
+		}
+		key, err := auth.AuthFromMD(ctx, "bearer")
 		if err != nil {
-			return ctx, status.Errorf(codes.Unauthenticated, "not valid token")
+			return ctx, err
 		}
+		user, err := serv.UserByAPIKey(ctx, key)
+		if err != nil {
+			if errors.Is(err, models.ErrAPIKeyNotFound) {
+				return ctx, status.Error(codes.Unauthenticated, err.Error())
+			}
+			return ctx, status.Error(codes.Internal, err.Error())
+		}
+		return session.WithUser(ctx, user), nil
+	}
+}
 
-		if !token.Valid {
-			return ctx, status.Errorf(codes.Unauthenticated, "not valid token")
+// RateLimitInterceptor ограничивает частоту вызовов ShortenURL, ShortenBatch
+// и GetFullURL с IP адреса клиента и от пользователя вызова.
+// Метаданные x-real-ip и x-forwarded-for учитываются, только если соединение
+// пришло от доверенного прокси. При превышении лимита возвращается
+// codes.ResourceExhausted с RetryInfo и заголовком retry-after
+func RateLimitInterceptor(limits ratelimit.Limits, trustedProxies []*net.IPNet) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		var limiter *ratelimit.Limiter
+		switch info.FullMethod {
+		case Handlers_ShortenURL_FullMethodName, Handlers_ShortenBatch_FullMethodName:
+			limiter = limits.Create
+		case Handlers_GetFullURL_FullMethodName:
+			limiter = limits.Redirect
+		}
+		if limiter == nil {
+			return handler(ctx, req)
 		}
 
-	} else {
-		return ctx, status.Errorf(codes.Unauthenticated, "no metadata")
+		ip := clientIP(ctx, trustedProxies)
+		result := limiter.Allow(ratelimit.IPKey(ip))
+		if user := GetUserFromContext(ctx); user != "" {
+			result = result.Stricter(limiter.Allow(ratelimit.UserKey(user)))
+		}
+
+		header := metadata.Pairs(
+			"ratelimit-limit", strconv.Itoa(result.Limit),
+			"ratelimit-remaining", strconv.Itoa(result.Remaining),
+			"ratelimit-reset", strconv.Itoa(ceilSeconds(result.Reset)),
+		)
+		if !result.Allowed {
+			header.Set("retry-after", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		}
+		grpc.SetHeader(ctx, header)
+
+		if !result.Allowed {
+			st := status.New(codes.ResourceExhausted, "too many requests")
+			if detailed, err := st.WithDetails(&errdetails.RetryInfo{
+				RetryDelay: durationpb.New(result.RetryAfter),
+			}); err == nil {
+				st = detailed
+			}
+			return nil, st.Err()
+		}
+		return handler(ctx, req)
 	}
-	return ctx, nil
+}
+
+// clientIP возвращает IP адрес клиента. Метаданные x-real-ip и x-forwarded-for
+// учитываются, только если соединение пришло от доверенного прокси
+func clientIP(ctx context.Context, trustedProxies []*net.IPNet) net.IP {
+	var remoteIP net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, ok := p.Addr.(*net.TCPAddr); ok {
+			remoteIP = addr.IP
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return ratelimit.ClientIP(remoteIP, firstValue(md, "x-real-ip"),
+		strings.Join(md.Get("x-forwarded-for"), ","), trustedProxies)
+}
+
+// firstValue возвращает первое значение ключа из метаданных
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ceilSeconds округляет длительность вверх до целых секунд
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
 }
@@ -6,9 +6,9 @@ import (
 	"net/http/httptest"
 
 	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/session"
 	"example.com/shortener/internal/app/storage"
 	"example.com/shortener/internal/config"
-	"example.com/shortener/internal/config/utils"
 	"example.com/shortener/internal/logger"
 )
 
@@ -22,7 +22,13 @@ func ExampleServer_GetUserURLs() {
 		fmt.Println(err.Error())
 		return
 	}
-	storage := storage.New(cfg, log)
+	// без ключей подписи сервис предупреждает о случайном ключе
+	cfg.CookieKeys, cfg.JWTKeys = "example:cookie-secret", "example:jwt-secret"
+	storage, err := storage.New(cfg, log)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 	serv := &Server{
 		service: *service.New(cfg, storage, log),
 		log:     log,
@@ -30,9 +36,9 @@ func ExampleServer_GetUserURLs() {
 
 	url := endpoint + "/api/user/urls"
 	request := httptest.NewRequest(http.MethodGet, url, nil)
-	// доставать URLы будем на основании значения куки
-	cookie, _ := utils.WriteCookies()
-	request.AddCookie(&cookie)
+	// доставать URLы будем по пользователю, которого middleware кладет в контекст
+	user, _ := session.NewUser()
+	request = request.WithContext(session.WithUser(request.Context(), user))
 
 	// создаем новый Recorder
 	w := httptest.NewRecorder()
@@ -58,12 +64,17 @@ func ExampleServer_DeleteURLs() {
 		return
 	}*/
 	cfg := config.Config{
-		BaseURL:  "http://localhost:8080/",
-		File:     "link.log",
-		Database: "user=habruser password=habr host=localhost port=5432 dbname=habrdb sslmode=disable",
-		Server:   "localhost:8080",
+		BaseURL:    "http://localhost:8080/",
+		File:       "link.log",
+		Server:     "localhost:8080",
+		CookieKeys: "example:cookie-secret",
+		JWTKeys:    "example:jwt-secret",
+	}
+	storage, err := storage.New(cfg, log)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
-	storage := storage.New(cfg, log)
 	serv := &Server{
 		service: *service.New(cfg, storage, log),
 		log:     log,
@@ -12,9 +12,14 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/ratelimit"
+	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/session"
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 )
@@ -22,15 +27,32 @@ import (
 // Параметры запроса
 var (
 	paramID         = "id"
+	paramTTL        = "ttl"
+	paramExpiresAt  = "expires_at"
+	paramDays       = "days"
+	paramDeleted    = "deleted"
+	paramWait       = "wait"
 	headerLocation  = "Location"
 	contentTypeJSON = "application/json"
 	encodGzip       = "gzip"
 )
 
-// DeleteURLs принимает строку с токенами и запускает горутину на удаление записей
+// DeleteURLs принимает строку с токенами и запускает горутину на удаление записей.
+// С параметром wait=true удаляет записи сразу и возвращает результат по каждому токену
 func (s *Server) DeleteURLs(rw http.ResponseWriter, req *http.Request) {
 	var sTokens []string
 	s.log.Debug("delete URLs")
+	if value := req.URL.Query().Get(paramWait); value != "" {
+		wait, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(rw, "invalid wait", http.StatusBadRequest)
+			return
+		}
+		if wait {
+			s.deleteURLsNow(rw, req)
+			return
+		}
+	}
 	// читаем строку в формате [ "a", "b", "c", "d", ...]
 	b, err := io.ReadAll(req.Body)
 	defer req.Body.Close()
@@ -45,15 +67,46 @@ func (s *Server) DeleteURLs(rw http.ResponseWriter, req *http.Request) {
 	// Запрос успешно принят 202 Accepted
 	rw.WriteHeader(http.StatusAccepted)
 
-	var cookieValue string
-	cookie, err := req.Cookie("User")
-	if err == nil {
-		cookieValue = cookie.Value
-	}
+	user := session.UserFromContext(req.Context())
 
 	// отправляем токены в канал
-	go s.service.AddDeletedTokens(sTokens, cookieValue)
+	go s.service.AddDeletedTokens(sTokens, user)
+
+}
+
+// deleteURLsNow удаляет ссылки пользователя, не ставя их в очередь,
+// и возвращает результат по каждому токену в порядке запроса
+func (s *Server) deleteURLsNow(rw http.ResponseWriter, req *http.Request) {
+	user := session.UserFromContext(req.Context())
+	if user == "" {
+		http.Error(rw, "unknown user", http.StatusUnauthorized)
+		return
+	}
+	var sTokens []string
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&sTokens); err != nil {
+		http.Error(rw, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	results, err := s.service.DeleteURLs(req.Context(), sTokens, user)
+	if err != nil {
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	buf := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(results); err != nil {
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", contentTypeJSON)
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprint(rw, buf)
 }
 
 // ShortenURL - обработчик для запроса POST /
@@ -74,17 +127,17 @@ func (s *Server) ShortenURL(rw http.ResponseWriter, req *http.Request) {
 	url := strings.Replace(string(b), "url=", "", 1)
 	s.log.WithFields(logrus.Fields{"long url": url})
 
-	var cookieValue string
-	cookie, err := req.Cookie("User")
-	if err == nil {
-		cookieValue = cookie.Value
-	}
+	user := session.UserFromContext(req.Context())
 
-	s.log.WithFields(logrus.Fields{"returned cookie": cookie})
-	http.SetCookie(rw, cookie)
+	// срок действия ссылки передается в параметрах запроса
+	expires, err := expiryFromQuery(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// добавляем длинный url в хранилище, генерируем токен
-	gToken, errToken = s.service.AddLink(req.Context(), "", url, cookieValue)
+	gToken, errToken = s.service.AddLink(req.Context(), "", url, user, expires)
 
 	if errToken != nil {
 		if errors.Is(errToken, models.ErrorAlreadyExist) {
@@ -126,19 +179,14 @@ func (s *Server) shortenBatch(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	var cookieValue string
-	cookie, err := req.Cookie("User")
-	if err == nil {
-		cookieValue = cookie.Value
-	}
+	user := session.UserFromContext(req.Context())
 
 	rw.Header().Set("Content-Type", contentTypeJSON)
-	s.log.WithFields(logrus.Fields{"cookie": cookie})
-	http.SetCookie(rw, cookie)
 
-	response, err := s.service.ShortenBatch(req.Context(), buffer, cookieValue)
+	// ошибки отдельных URL возвращаются в ответе, ошибка здесь - сбой хранилища
+	response, err := s.service.ShortenBatch(req.Context(), buffer, user)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	} else {
 		// возвращаем ответ с кодом 201
@@ -170,7 +218,7 @@ func (s *Server) GetFullURL(rw http.ResponseWriter, req *http.Request) {
 	longURL, err := s.service.GetLongURL(req.Context(), lToken)
 	if err != nil {
 		s.log.Error(err.Error())
-		if errors.Is(err, models.ErrLinkDeleted) {
+		if errors.Is(err, models.ErrLinkDeleted) || errors.Is(err, models.ErrLinkExpired) {
 			http.Error(rw, err.Error(), http.StatusGone)
 			return
 		}
@@ -178,6 +226,9 @@ func (s *Server) GetFullURL(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// переход сохраняется в статистику асинхронно
+	s.service.RecordClick(lToken, req.Referer(), req.UserAgent(), s.clientIP(req))
+
 	// возвращаем длинный url в поле Location
 	rw.Header().Set(headerLocation, longURL)
 	s.log.WithFields(logrus.Fields{"header": rw.Header()}).Info("Заголовок возврата")
@@ -187,9 +238,14 @@ func (s *Server) GetFullURL(rw http.ResponseWriter, req *http.Request) {
 
 }
 
-// Request - структура данных для запроса в формате JSON
+// Request - структура данных для запроса в формате JSON.
+// Alias - необязательный псевдоним, который используется вместо случайного токена,
+// ExpiresAt (RFC3339) или TTL (в секундах) - необязательный срок действия ссылки
 type Request struct {
-	LongURL string `json:"url"`
+	LongURL   string `json:"url"`
+	Alias     string `json:"alias,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	TTL       int64  `json:"ttl,omitempty"`
 }
 
 // Response - структура для ответа в формате JSON
@@ -215,27 +271,34 @@ func (s *Server) shortenJSON(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	log.Printf("request json %s\n", requestJSON)
+	log.Printf("request json %v\n", requestJSON)
 	// добавляем длинный url в хранилище, генерируем токен
-	var cookieValue string
-	cookie, err := req.Cookie("User")
-	if err == nil {
-		cookieValue = cookie.Value
-	}
-
-	s.log.WithFields(logrus.Fields{"cookie": cookie})
-	//req.AddCookie(cookie)
-	http.SetCookie(rw, cookie)
+	user := session.UserFromContext(req.Context())
 
 	rw.Header().Set("Content-Type", contentTypeJSON)
 
-	gToken, errToken = s.service.AddLink(req.Context(), "", requestJSON.LongURL, cookieValue)
+	expires, err := service.ParseExpiry(requestJSON.ExpiresAt, requestJSON.TTL)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gToken, errToken = s.service.AddLink(req.Context(), requestJSON.Alias, requestJSON.LongURL, user, expires)
 	if errToken != nil {
-		if errors.Is(errToken, models.ErrorAlreadyExist) {
+		switch {
+		case errors.Is(errToken, models.ErrInvalidAlias), errors.Is(errToken, models.ErrReservedAlias):
+			// псевдоним не прошел проверку
+			http.Error(rw, errToken.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(errToken, models.ErrShortURLAlreadyExist):
+			// псевдоним уже занят другой ссылкой
+			http.Error(rw, errToken.Error(), http.StatusConflict)
+			return
+		case errors.Is(errToken, models.ErrorAlreadyExist):
 			// попытка сократить уже имеющийся в базе URL
 			// возвращаем ответ с кодом 409
 			rw.WriteHeader(http.StatusConflict)
-		} else {
+		default:
 			s.log.Error(errToken.Error())
 			http.Error(rw, errToken.Error(), http.StatusInternalServerError)
 			return
@@ -258,6 +321,20 @@ func (s *Server) shortenJSON(rw http.ResponseWriter, req *http.Request) {
 
 }
 
+// expiryFromQuery возвращает срок действия ссылки из параметров запроса ttl и expires_at
+func expiryFromQuery(req *http.Request) (time.Time, error) {
+	var ttl int64
+	query := req.URL.Query()
+	if value := query.Get(paramTTL); value != "" {
+		var err error
+		ttl, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, models.ErrInvalidExpiry
+		}
+	}
+	return service.ParseExpiry(query.Get(paramExpiresAt), ttl)
+}
+
 // ToJSON записывает результат JSON-сериализации в хранилище байт
 func (r *Response) ToJSON() *bytes.Buffer {
 	buf := bytes.NewBuffer([]byte{})
@@ -273,17 +350,27 @@ type CookiesURL struct {
 	OrigURL  string `json:"original_url"`
 }
 
-// getUserURLs возвращает все URL, сокращенным пользвателем
+// getUserURLs возвращает все URL, сокращенным пользвателем.
+// С параметром deleted=true возвращает удаленные URL со временем удаления
 func (s *Server) GetUserURLs(rw http.ResponseWriter, req *http.Request) {
 	s.log.Debug("Get all urls for user")
-	user, err := req.Cookie("User")
-	if err != nil {
-		s.log.Error(err.Error())
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	user := session.UserFromContext(req.Context())
+	if user == "" {
+		http.Error(rw, "unknown user", http.StatusUnauthorized)
 		return
 	}
-	s.log.WithFields(logrus.Fields{"cookie value": user.Value})
-	links, err := s.service.GetAllURLS(req.Context(), user.Value)
+	if value := req.URL.Query().Get(paramDeleted); value != "" {
+		deleted, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(rw, "invalid deleted", http.StatusBadRequest)
+			return
+		}
+		if deleted {
+			s.getDeletedURLs(rw, req, user)
+			return
+		}
+	}
+	links, err := s.service.GetAllURLS(req.Context(), user)
 	if err != nil {
 		s.log.Error(err.Error())
 	}
@@ -316,6 +403,194 @@ func (s *Server) GetUserURLs(rw http.ResponseWriter, req *http.Request) {
 	fmt.Fprint(rw, buf)
 }
 
+// getDeletedURLs возвращает удаленные URL пользователя, которые еще можно восстановить
+func (s *Server) getDeletedURLs(rw http.ResponseWriter, req *http.Request, user string) {
+	links, err := s.service.GetDeletedURLs(req.Context(), user)
+	if err != nil {
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(links) == 0 {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(links); err != nil {
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", contentTypeJSON)
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprint(rw, buf)
+}
+
+// restoreURL - обработчик запроса POST /api/user/urls/{id}/restore
+// восстанавливает удаленную ссылку пользователя
+func (s *Server) restoreURL(rw http.ResponseWriter, req *http.Request) {
+	s.log.Info("Restore URL")
+	user := session.UserFromContext(req.Context())
+
+	err := s.service.RestoreURL(req.Context(), chi.URLParam(req, paramID), user)
+	if err != nil {
+		if errors.Is(err, models.ErrLinkNotFound) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// GetLinkStats - обработчик запроса GET /api/user/urls/{id}/stats
+// возвращает число переходов по дням и самые частые источники переходов
+// для ссылки, сокращенной пользователем
+func (s *Server) GetLinkStats(rw http.ResponseWriter, req *http.Request) {
+	s.log.Debug("Get link stats")
+
+	days := service.DefaultStatsDays
+	if value := req.URL.Query().Get(paramDays); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > service.MaxStatsDays {
+			http.Error(rw, "invalid days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user := session.UserFromContext(req.Context())
+
+	stats, err := s.service.GetLinkStats(req.Context(), chi.URLParam(req, paramID), user, days)
+	if err != nil {
+		if errors.Is(err, models.ErrLinkNotFound) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(stats); err != nil {
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", contentTypeJSON)
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprint(rw, buf)
+}
+
+// UpdateURLRequest - запрос на изменение ссылки: новый исходный URL
+// или номер ревизии, к которой нужно вернуться. Задается ровно одно из полей
+type UpdateURLRequest struct {
+	LongURL  string `json:"original_url,omitempty"`
+	Revision int    `json:"revision,omitempty"`
+}
+
+// updateURL - обработчик запроса PATCH /api/user/urls/{id}
+// меняет исходный URL ссылки пользователя или откатывает его к ревизии
+// и возвращает историю ревизий
+func (s *Server) updateURL(rw http.ResponseWriter, req *http.Request) {
+	s.log.Info("Update URL")
+
+	var request UpdateURLRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer req.Body.Close()
+	if (request.LongURL == "") == (request.Revision == 0) {
+		http.Error(rw, "either original_url or revision is required", http.StatusBadRequest)
+		return
+	}
+
+	user := session.UserFromContext(req.Context())
+	token := chi.URLParam(req, paramID)
+
+	var link models.LinkRevisions
+	var err error
+	if request.Revision != 0 {
+		link, err = s.service.RollbackURL(req.Context(), token, user, request.Revision)
+	} else {
+		link, err = s.service.UpdateURL(req.Context(), token, user, request.LongURL)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidURL):
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, models.ErrLinkNotFound), errors.Is(err, models.ErrRevisionNotFound):
+			http.Error(rw, err.Error(), http.StatusNotFound)
+		case errors.Is(err, models.ErrLinkDeleted):
+			http.Error(rw, err.Error(), http.StatusGone)
+		case errors.Is(err, models.ErrorAlreadyExist):
+			// новый URL уже сокращен, возвращаем его сокращенный URL
+			rw.Header().Set("Content-Type", contentTypeJSON)
+			rw.WriteHeader(http.StatusConflict)
+			fmt.Fprint(rw, (&Response{ShortURL: link.ShortURL}).ToJSON())
+		default:
+			s.log.Error(err.Error())
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	s.writeRevisions(rw, link)
+}
+
+// GetRevisions - обработчик запроса GET /api/user/urls/{id}/revisions
+// возвращает текущий и прежние исходные URL ссылки пользователя
+func (s *Server) GetRevisions(rw http.ResponseWriter, req *http.Request) {
+	s.log.Debug("Get link revisions")
+	user := session.UserFromContext(req.Context())
+
+	link, err := s.service.GetRevisions(req.Context(), chi.URLParam(req, paramID), user)
+	if err != nil {
+		if errors.Is(err, models.ErrLinkNotFound) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeRevisions(rw, link)
+}
+
+// writeRevisions пишет в ответ историю ревизий ссылки в формате JSON
+func (s *Server) writeRevisions(rw http.ResponseWriter, link models.LinkRevisions) {
+	buf := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(link); err != nil {
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", contentTypeJSON)
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprint(rw, buf)
+}
+
+// clientIP возвращает IP адрес клиента. Заголовки X-Real-IP и X-Forwarded-For
+// учитываются, только если запрос пришел от доверенного прокси
+func (s *Server) clientIP(req *http.Request) net.IP {
+	var remoteIP net.IP
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		remoteIP = net.ParseIP(host)
+	}
+	return ratelimit.ClientIP(remoteIP, req.Header.Get("X-Real-IP"),
+		req.Header.Get("X-Forwarded-For"), s.trustedProxies)
+}
+
 // PingConnection проверяет соединение с БД
 func (s *Server) PingConnection(rw http.ResponseWriter, req *http.Request) {
 	s.log.Info("Ping")
@@ -326,34 +601,153 @@ func (s *Server) PingConnection(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// Healthz отвечает, что процесс жив. Хранилище не проверяется, чтобы
+// оркестратор не перезапускал сервис из-за недоступной бд
+func (s *Server) Healthz(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", contentTypeJSON)
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprint(rw, `{"status":"ok"}`)
+}
+
+// Readyz проверяет готовность сервиса принимать запросы и возвращает
+// результаты проверок в формате JSON. Если хранилище недоступно,
+// возвращается 503, при остальных сбоях (degraded) - 200
+func (s *Server) Readyz(rw http.ResponseWriter, req *http.Request) {
+	health := s.service.Health(req.Context())
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := json.NewEncoder(buf).Encode(health); err != nil {
+		s.log.Error(err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", contentTypeJSON)
+	if health.Status == models.HealthUnavailable {
+		s.log.WithFields(logrus.Fields{"health": health}).Warn("Сервис не готов")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		rw.WriteHeader(http.StatusOK)
+	}
+	fmt.Fprint(rw, buf)
+}
+
 // GetStats проверяет что ip клиента входит в доверенную подсеть
 // и возвращает статистику в формате JSON
 func (s *Server) GetStats(rw http.ResponseWriter, req *http.Request) {
 	s.log.Info("Get stats")
 
-	ipstr := req.Header.Get("X-Real-IP")
-
-	ip := net.ParseIP(ipstr)
+	// заголовку X-Real-IP верим, только если запрос пришел от доверенного прокси
+	ip := s.clientIP(req)
 	if ip == nil {
 		rw.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
 	stats, err := s.service.CheckIPMask(req.Context(), ip)
 	if err != nil {
-		if errors.Is(err, models.ErrNotTrustedSubnet) {
+		switch {
+		case errors.Is(err, models.ErrNotTrustedSubnet), errors.Is(err, models.ErrEmptySubnet):
 			s.log.Info("IP не входит в доверенную подсеть")
 			rw.WriteHeader(http.StatusForbidden)
+		default:
+			s.log.Error(err.Error())
+			rw.WriteHeader(http.StatusInternalServerError)
 		}
-		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	buf := bytes.NewBuffer([]byte{})
 	if err := json.NewEncoder(buf).Encode(stats); err != nil {
 		s.log.Error(err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	rw.Header().Set("Content-Type", contentTypeJSON)
 	rw.WriteHeader(http.StatusOK)
 	fmt.Fprint(rw, buf)
 
 }
+
+// APIKeyRequest - запрос на выпуск API ключа. Если User не задан,
+// ключ выпускается для нового пользователя
+type APIKeyRequest struct {
+	User string `json:"user,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// APIKeyResponse - выпущенный API ключ. Key возвращается только один раз
+type APIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// checkTrusted проверяет, что клиент входит в доверенную подсеть.
+// Если нет, пишет в ответ 403 и возвращает false
+func (s *Server) checkTrusted(rw http.ResponseWriter, req *http.Request) bool {
+	err := s.service.CheckTrustedIP(s.clientIP(req))
+	if err == nil {
+		return true
+	}
+	switch {
+	case errors.Is(err, models.ErrNotTrustedSubnet), errors.Is(err, models.ErrEmptySubnet):
+		s.log.Info("IP не входит в доверенную подсеть")
+		rw.WriteHeader(http.StatusForbidden)
+	default:
+		s.log.Error(err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+	}
+	return false
+}
+
+// issueAPIKey выпускает API ключ, доступен только из доверенной подсети
+func (s *Server) issueAPIKey(rw http.ResponseWriter, req *http.Request) {
+	s.log.Info("Issue API key")
+	if !s.checkTrusted(rw, req) {
+		return
+	}
+
+	var request APIKeyRequest
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	apiKey, key, err := s.service.IssueAPIKey(req.Context(), request.User, request.Name)
+	if err != nil {
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := json.NewEncoder(buf).Encode(APIKeyResponse{APIKey: apiKey, Key: key}); err != nil {
+		s.log.Error(err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", contentTypeJSON)
+	rw.WriteHeader(http.StatusCreated)
+	fmt.Fprint(rw, buf)
+}
+
+// revokeAPIKey отзывает API ключ, доступен только из доверенной подсети
+func (s *Server) revokeAPIKey(rw http.ResponseWriter, req *http.Request) {
+	s.log.Info("Revoke API key")
+	if !s.checkTrusted(rw, req) {
+		return
+	}
+
+	err := s.service.RevokeAPIKey(req.Context(), chi.URLParam(req, paramID))
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Error(err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"net"
 	"net/http/pprof"
 
+	"example.com/shortener/internal/app/ratelimit"
 	service "example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/session"
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 )
@@ -11,47 +14,75 @@ import (
 // Server реализует все методы-обработчики.
 // поле service служит для взаимодействия с модулем service
 type Server struct {
-	service service.Service
-	log     *logrus.Logger
+	service        service.Service
+	log            *logrus.Logger
+	limits         ratelimit.Limits
+	trustedProxies []*net.IPNet
+	sessions       *session.Manager
 }
 
 // NewRouter возвращает экземпляр роутера chi
 // и определяет основные обработчики для приложения
 func NewRouter(service *service.Service, log *logrus.Logger) chi.Router {
 	log.Println("выбираем роутер")
+	trustedProxies, err := ratelimit.ParseNetworks(service.Config.TrustedProxies)
+	if err != nil {
+		log.Error(err.Error())
+	}
 	serv := &Server{
-		service: *service,
-		log:     log,
+		service:        *service,
+		log:            log,
+		limits:         ratelimit.NewLimits(service.Config),
+		trustedProxies: trustedProxies,
+		sessions:       session.New(service.CookieKeys(), service.Config),
 	}
 
 	// определяем роутер chi
 	r := chi.NewRouter()
 
+	// проверки для оркестратора не требуют сессии и не выдают куки
+	r.Get("/healthz", serv.Healthz)
+	r.Get("/readyz", serv.Readyz)
+
 	// создадим суброутер, который будет содержать две функции
 	r.Route("/", func(r chi.Router) {
 		// аутентификация пользователя
-		r.Use(userAuth)
+		r.Use(serv.userAuth)
 		// обработка сжатия gzip
 		r.Use(gzipHandle)
 
 		r.HandleFunc("/debug/pprof", pprof.Index)
 		r.HandleFunc("/debug/pprof/profile", pprof.Profile)
 
-		r.Post("/api/shorten/batch", serv.shortenBatch)
+		// создание ссылок и переходы по ним ограничены по частоте
+		create := r.With(serv.rateLimit(serv.limits.Create))
+		redirect := r.With(serv.rateLimit(serv.limits.Redirect))
+
+		create.Post("/api/shorten/batch", serv.shortenBatch)
 		//удаление URL пользователем
 		r.Delete("/api/user/urls", serv.DeleteURLs)
 		// сокращение URL в JSON формате
-		r.Post("/api/shorten", serv.shortenJSON)
+		create.Post("/api/shorten", serv.shortenJSON)
 		// все URL пользователя, которые он сокращал
 		r.Get("/api/user/urls", serv.GetUserURLs)
+		// статистика переходов по URL пользователя
+		r.Get("/api/user/urls/{id}/stats", serv.GetLinkStats)
+		// изменение исходного URL и история его ревизий
+		r.Patch("/api/user/urls/{id}", serv.updateURL)
+		r.Get("/api/user/urls/{id}/revisions", serv.GetRevisions)
+		// восстановление удаленного URL
+		r.Post("/api/user/urls/{id}/restore", serv.restoreURL)
 		// возвращает общее число сокращенных URL и пользователей
 		r.Get("/api/internal/stats", serv.GetStats)
+		// выпуск и отзыв API ключей
+		r.Post("/api/internal/keys", serv.issueAPIKey)
+		r.Delete("/api/internal/keys/{id}", serv.revokeAPIKey)
 		// проверка соединения с бд
 		r.Get("/ping", serv.PingConnection)
 		// получение полного URL по сокращенному
-		r.Get("/{id}", serv.GetFullURL)
+		redirect.Get("/{id}", serv.GetFullURL)
 		// сокращение URL
-		r.Post("/", serv.ShortenURL)
+		create.Post("/", serv.ShortenURL)
 
 	})
 	return r
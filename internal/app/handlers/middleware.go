@@ -2,12 +2,18 @@ package handlers
 
 import (
 	"compress/gzip"
+	"errors"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
-	"example.com/shortener/internal/config/utils"
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/ratelimit"
+	"example.com/shortener/internal/app/session"
+	"github.com/sirupsen/logrus"
 )
 
 // gzipHandle распаковывает данные запроса, поддерживающего gzip-сжатие
@@ -49,45 +55,95 @@ func gzipHandle(next http.Handler) http.Handler {
 	})
 }
 
-// AddCookie генерирует куки для пользователя и добавляет к запросу с помощью
-// func (*http.Request).AddCookie(c *http.Cookie)
-func AddCookie(r *http.Request) error {
-	log.Println("Не нашли куки User")
-	usercookie, err := utils.WriteCookies()
-	if err != nil {
-		log.Printf("handlers_base|userAuth|%v\n", err)
-		return err
-	}
-	// выдать пользователю симметрично подписанную куку
-	log.Printf("куки %s\n", &usercookie)
-	r.AddCookie(&usercookie)
-	return nil
-}
-
-func userAuth(next http.Handler) http.Handler {
-	log.Println("middleware")
+// userAuth определяет пользователя запроса и кладет его идентификатор
+// в контекст. Пользователь берется из API ключа в заголовке
+// Authorization: Bearer, иначе из куки сессии User. Если куки нет или она
+// не прошла проверку, заводится новый пользователь. Кука выдается заново
+// новому пользователю, по куке прежнего формата и после половины ее срока
+func (s *Server) userAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println("Получаем куки")
-		// получаем куки
-		cookie, err := r.Cookie("User")
-		if err != nil {
-			if err = AddCookie(r); err != nil {
+		if key, ok := bearerToken(r); ok {
+			user, err := s.service.UserByAPIKey(r.Context(), key)
+			if err != nil {
+				if errors.Is(err, models.ErrAPIKeyNotFound) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				s.log.Error(err.Error())
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			next.ServeHTTP(w, r)
+			// клиентам с API ключом кука не выдается: по ней можно было бы
+			// действовать от имени владельца и после отзыва ключа
+			next.ServeHTTP(w, r.WithContext(session.WithUser(r.Context(), user)))
 			return
 		}
-		log.Printf("Нашли куки %s\n", cookie)
-		err = utils.ReadCookies(*cookie)
-		if err != nil {
-			log.Printf("handlers_base|userAuth|%v\n", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		r.AddCookie(cookie)
 
-		// замыкание — используем ServeHTTP следующего хендлера
-		next.ServeHTTP(w, r)
+		var user string
+		renew := true
+		if cookie, err := r.Cookie(session.CookieName); err == nil {
+			user, renew, err = s.sessions.Verify(cookie.Value)
+			if err != nil {
+				s.log.WithFields(logrus.Fields{"error": err}).Info("Кука User не прошла проверку")
+				user, renew = "", true
+			}
+		}
+		if user == "" {
+			var err error
+			user, err = session.NewUser()
+			if err != nil {
+				s.log.Error(err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if renew {
+			http.SetCookie(w, s.sessions.Issue(user))
+		}
+		next.ServeHTTP(w, r.WithContext(session.WithUser(r.Context(), user)))
 	})
 }
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+// rateLimit ограничивает частоту запросов с IP адреса клиента и от пользователя
+// запроса. В ответ добавляются заголовки RateLimit-*, а при превышении
+// лимита возвращается 429 с заголовком Retry-After
+func (s *Server) rateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result := limiter.Allow(ratelimit.IPKey(s.clientIP(r)))
+			if user := session.UserFromContext(r.Context()); user != "" {
+				result = result.Stricter(limiter.Allow(ratelimit.UserKey(user)))
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds округляет длительность вверх до целых секунд
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,115 @@
+// Модуль keyring хранит набор ключей подписи с идентификаторами (kid).
+// Новые куки и токены подписываются текущим ключом, а проверяются ключом,
+// идентификатор которого в них записан. Так при смене ключа ранее выданные
+// куки и токены продолжают проверяться, пока старый ключ остается в наборе
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultKeyID - идентификатор ключа для куки и токенов, выданных
+// до появления идентификаторов ключей
+const DefaultKeyID = "default"
+
+// secretBytes - число случайных байт в ключе, который создает Generate
+const secretBytes = 32
+
+// ErrUnknownKey - в наборе нет ключа с таким идентификатором
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// validKeyID - допустимые символы идентификатора ключа. Точка, двоеточие
+// и запятая используются как разделители
+var validKeyID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Keyring - набор ключей подписи
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// Parse разбирает список ключей "kid:secret" через запятую. Первый ключ
+// текущий, им подписываются новые куки и токены, остальные нужны только для
+// проверки. Если список пустой, набор состоит из случайного ключа:
+// подписанные им куки и токены действуют только до перезапуска процесса.
+// Встроенного ключа нет, куки и токены без идентификатора ключа проверяются,
+// только если ключ DefaultKeyID явно указан в списке
+func Parse(list string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(item, ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("signing key %q: expected kid:secret", kid)
+		}
+		if !validKeyID.MatchString(kid) {
+			return nil, fmt.Errorf("signing key %q: invalid key id", kid)
+		}
+		if _, ok := k.keys[kid]; ok {
+			return nil, fmt.Errorf("signing key %q: duplicate key id", kid)
+		}
+		k.keys[kid] = []byte(secret)
+		if k.current == "" {
+			k.current = kid
+		}
+	}
+	if k.current == "" {
+		kid, secret, err := randomKey()
+		if err != nil {
+			return nil, err
+		}
+		k.current = kid
+		k.keys[kid] = secret
+	}
+	return k, nil
+}
+
+// Current возвращает текущий ключ и его идентификатор
+func (k *Keyring) Current() (string, []byte) {
+	return k.current, k.keys[k.current]
+}
+
+// Key возвращает ключ по идентификатору. Пустой идентификатор
+// означает ключ DefaultKeyID
+func (k *Keyring) Key(kid string) ([]byte, error) {
+	if kid == "" {
+		kid = DefaultKeyID
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// Generate создает новый ключ в формате "kid:secret".
+// Идентификатор и ключ случайные
+func Generate() (string, error) {
+	kid, secret, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+	return kid + ":" + string(secret), nil
+}
+
+// randomKey возвращает случайные идентификатор и ключ
+func randomKey() (string, []byte, error) {
+	id := make([]byte, 4)
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(id), []byte(base64.RawURLEncoding.EncodeToString(secret)), nil
+}
@@ -0,0 +1,60 @@
+package keyring
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	keys, err := Parse("new:secret2, old:secret1")
+	require.NoError(t, err)
+	kid, secret := keys.Current()
+	assert.Equal(t, "new", kid)
+	assert.Equal(t, []byte("secret2"), secret)
+	secret, err = keys.Key("old")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret1"), secret)
+	_, err = keys.Key("")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// без ключей используется случайный ключ, свой для каждого набора
+	keys, err = Parse("")
+	require.NoError(t, err)
+	kid, secret = keys.Current()
+	assert.NotEqual(t, DefaultKeyID, kid)
+	assert.Len(t, secret, 43)
+	other, err := Parse("")
+	require.NoError(t, err)
+	_, otherSecret := other.Current()
+	assert.NotEqual(t, secret, otherSecret)
+	_, err = keys.Key("")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// прежний ключ принимается, только если задан явно
+	keys, err = Parse("new:secret2,default:secret key")
+	require.NoError(t, err)
+	secret, err = keys.Key("")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret key"), secret)
+
+	for _, list := range []string{"secret", "kid:", "k.id:secret", "a:1,a:2"} {
+		_, err := Parse(list)
+		assert.Error(t, err, list)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	first, err := Generate()
+	require.NoError(t, err)
+	second, err := Generate()
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	keys, err := Parse(first + "," + second)
+	require.NoError(t, err)
+	kid, _ := keys.Current()
+	assert.True(t, strings.HasPrefix(first, kid+":"))
+}
@@ -0,0 +1,263 @@
+// Модуль metrics собирает метрики сервиса в формате Prometheus: запросы HTTP
+// и gRPC, время операций хранилища, очередь удаления, пул соединений с бд,
+// число созданных ссылок и переходов. Метрики отдаются на отдельном
+// служебном адресе
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/shortener/internal/app/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// namespace - префикс имен метрик
+const namespace = "shortener"
+
+// unmatchedRoute - метка маршрута для запросов, не попавших ни в один
+// маршрут, чтобы произвольные пути не порождали новые ряды
+const unmatchedRoute = "unmatched"
+
+// Metrics хранит метрики сервиса и реестр, в котором они зарегистрированы
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	grpcRequests  *prometheus.CounterVec
+	grpcDuration  *prometheus.HistogramVec
+	storeDuration *prometheus.HistogramVec
+	storeErrors   *prometheus.CounterVec
+	linksCreated  prometheus.Counter
+	redirects     prometheus.Counter
+}
+
+// New - конструктор для Metrics. Кроме метрик сервиса в реестре
+// регистрируются метрики среды выполнения Go и процесса
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		grpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_requests_total",
+			Help:      "gRPC calls by method and status code.",
+		}, []string{"method", "code"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "gRPC call latency by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Storage operation latency by Storer method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_errors_total",
+			Help:      "Storage operations that failed, by Storer method.",
+		}, []string{"operation"}),
+		linksCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "links_created_total",
+			Help:      "Short links created.",
+		}),
+		redirects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redirects_total",
+			Help:      "Short links resolved to the original URL.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration,
+		m.grpcRequests, m.grpcDuration,
+		m.storeDuration, m.storeErrors,
+		m.linksCreated, m.redirects,
+	)
+	return m
+}
+
+// Handler возвращает обработчик, который отдает метрики
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDeleteQueue регистрирует метрику длины очереди токенов на удаление
+func (m *Metrics) RegisterDeleteQueue(queue chan string) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "delete_queue_length",
+		Help:      "Tokens waiting in the deletion queue.",
+	}, func() float64 { return float64(len(queue)) }))
+}
+
+// RegisterBackend регистрирует метрику с названием хранилища, с которым
+// работает сервис
+func (m *Metrics) RegisterBackend(backend string) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "storage_backend_info",
+		Help:        "Storage backend in use, always 1.",
+		ConstLabels: prometheus.Labels{"backend": backend},
+	}, func() float64 { return 1 }))
+}
+
+// RegisterLinks регистрирует метрику с числом ссылок в хранилище.
+// Число запрашивается у хранилища при каждом сборе метрик, поэтому, в отличие
+// от links_created_total, учитывает удаленные ссылки, перезапуски и другие реплики
+func (m *Metrics) RegisterLinks(count func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "links",
+		Help:      "Short links in the storage.",
+	}, func() float64 { return float64(count()) }))
+}
+
+// PoolStater - хранилище с пулом соединений pgx
+type PoolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// RegisterPool регистрирует метрики пула соединений с бд
+func (m *Metrics) RegisterPool(pool PoolStater) {
+	m.registry.MustRegister(poolCollector{pool: pool})
+}
+
+// HTTPMiddleware считает запросы к роутеру chi и время их обработки.
+// Маршрут берется из шаблона chi (например, /{id}), а не из пути запроса
+func (m *Metrics) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		// контекст маршрута создается заранее, тогда роутер заполнит его,
+		// и после обработки из него можно прочитать шаблон маршрута
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil {
+			rctx = chi.NewRouteContext()
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		}
+		sw := middleware.NewStatusWriter(w)
+		next.ServeHTTP(sw, r)
+
+		labels := prometheus.Labels{"route": routeLabel(rctx), "method": r.Method, "status": strconv.Itoa(sw.Status())}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeLabel возвращает шаблон маршрута запроса. Если запрос не дошел
+// до обработчика, остается только шаблон /* смонтированного суброутера
+func routeLabel(rctx *chi.Context) string {
+	if len(rctx.RoutePatterns) == 0 {
+		return unmatchedRoute
+	}
+	route := rctx.RoutePattern()
+	if strings.HasSuffix(route, "/*") {
+		return unmatchedRoute
+	}
+	// chi отрезает завершающий слеш, и от корня остается пустая строка
+	if route == "" {
+		return "/"
+	}
+	return route
+}
+
+// UnaryServerInterceptor считает вызовы gRPC и время их выполнения
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeGRPC(info.FullMethod, err, start)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor считает потоковые вызовы gRPC. Время считается
+// до закрытия потока
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observeGRPC(info.FullMethod, err, start)
+		return err
+	}
+}
+
+func (m *Metrics) observeGRPC(method string, err error, start time.Time) {
+	labels := prometheus.Labels{"method": method, "code": status.Code(err).String()}
+	m.grpcRequests.With(labels).Inc()
+	m.grpcDuration.With(labels).Observe(time.Since(start).Seconds())
+}
+
+// poolCollector отдает статистику пула pgx на момент сбора метрик
+type poolCollector struct {
+	pool PoolStater
+}
+
+var (
+	poolAcquiredDesc = prometheus.NewDesc(namespace+"_db_pool_acquired_connections",
+		"Connections currently in use.", nil, nil)
+	poolIdleDesc = prometheus.NewDesc(namespace+"_db_pool_idle_connections",
+		"Idle connections in the pool.", nil, nil)
+	poolTotalDesc = prometheus.NewDesc(namespace+"_db_pool_total_connections",
+		"All connections in the pool, including ones being established.", nil, nil)
+	poolMaxDesc = prometheus.NewDesc(namespace+"_db_pool_max_connections",
+		"Maximum size of the pool.", nil, nil)
+	poolAcquiresDesc = prometheus.NewDesc(namespace+"_db_pool_acquires_total",
+		"Successful connection acquires.", nil, nil)
+	poolEmptyAcquiresDesc = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total",
+		"Acquires that had to wait for a connection.", nil, nil)
+	poolCanceledAcquiresDesc = prometheus.NewDesc(namespace+"_db_pool_canceled_acquires_total",
+		"Acquires canceled by the context.", nil, nil)
+	poolAcquireDurationDesc = prometheus.NewDesc(namespace+"_db_pool_acquire_duration_seconds_total",
+		"Total time spent acquiring connections.", nil, nil)
+)
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredDesc
+	ch <- poolIdleDesc
+	ch <- poolTotalDesc
+	ch <- poolMaxDesc
+	ch <- poolAcquiresDesc
+	ch <- poolEmptyAcquiresDesc
+	ch <- poolCanceledAcquiresDesc
+	ch <- poolAcquireDurationDesc
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquiresDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/storage/memory"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	m := New()
+	r := chi.NewRouter()
+	r.Route("/", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTemporaryRedirect)
+		})
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+	})
+	handler := m.HTTPMiddleware(r)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/abc", nil),
+		httptest.NewRequest(http.MethodGet, "/def", nil),
+		httptest.NewRequest(http.MethodPost, "/", nil),
+		httptest.NewRequest(http.MethodGet, "/a/b/c", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// маршрут берется из шаблона, а не из пути
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("/{id}", "GET", "307")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("/", "POST", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues(unmatchedRoute, "GET", "404")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.httpDuration))
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	m := New()
+	unary := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.Handlers/GetFullURL"}
+
+	_, err := unary(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	_, err = unary(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+	require.Error(t, err)
+
+	stream := m.StreamServerInterceptor()
+	err = stream(nil, nil, &grpc.StreamServerInfo{FullMethod: "/grpc.Handlers/WatchUserURLs"},
+		func(srv interface{}, stream grpc.ServerStream) error { return nil })
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.grpcRequests.WithLabelValues(info.FullMethod, "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.grpcRequests.WithLabelValues(info.FullMethod, "NotFound")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.grpcRequests.WithLabelValues("/grpc.Handlers/WatchUserURLs", "OK")))
+}
+
+// failingStorer - хранилище, у которого не работает Ping
+type failingStorer struct {
+	*memory.MemoryStorage
+}
+
+func (failingStorer) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestStorer(t *testing.T) {
+	m := New()
+	s := NewStorer(failingStorer{memory.New(config.Config{}, logger.InitLog())}, m)
+	ctx := context.Background()
+
+	_, err := s.AddLink(ctx, "abc", "https://practicum.yandex.ru", "user", time.Time{})
+	require.NoError(t, err)
+	_, err = s.AddLink(ctx, "abc", "https://ya.ru", "user", time.Time{})
+	require.ErrorIs(t, err, models.ErrShortURLAlreadyExist)
+
+	resp, err := s.ShortenBatch(ctx, []models.BatchReq{
+		{CorrID: "1", URL: "https://ya.ru", Token: "ghi"},
+		{CorrID: "2", URL: "https://go.dev", Token: "jkl"},
+	}, "user")
+	require.NoError(t, err)
+	require.Len(t, resp, 2)
+
+	_, err = s.GetLongURL(ctx, "abc")
+	require.NoError(t, err)
+	_, err = s.GetLongURL(ctx, "missing")
+	require.Error(t, err)
+	require.Error(t, s.Ping(ctx))
+
+	// ссылка из AddLink и две из пакета
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.linksCreated))
+	// число ссылок берется из хранилища при сборе метрик
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "shortener_links 3\n")
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.redirects))
+	// занятый токен и отсутствующая ссылка ошибками хранилища не считаются
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.storeErrors.WithLabelValues("AddLink")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.storeErrors.WithLabelValues("GetLongURL")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.storeErrors.WithLabelValues("Ping")))
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	queue := make(chan string, 10)
+	queue <- "abc"
+	m.RegisterDeleteQueue(queue)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "shortener_delete_queue_length 1")
+	assert.Contains(t, string(body), "shortener_links_created_total 0")
+	assert.Contains(t, string(body), "go_goroutines")
+}
@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/service"
+)
+
+// storer оборачивает хранилище и замеряет время каждой операции.
+// Заодно считает созданные ссылки и переходы
+type storer struct {
+	storage service.Storer
+	metrics *Metrics
+}
+
+// проверка на имплементацию интерфейса
+var _ service.Storer = storer{}
+
+// NewStorer возвращает хранилище storage с метриками и регистрирует метрики
+// с его названием и числом ссылок. Если у хранилища есть пул соединений pgx,
+// регистрируются и метрики пула. Пул ищется и под обертками хранилища (например, кэшем)
+func NewStorer(storage service.Storer, m *Metrics) service.Storer {
+	m.RegisterBackend(storage.Backend())
+	m.RegisterLinks(storage.GetStorageLen)
+	if pool, ok := findPool(storage); ok {
+		m.RegisterPool(pool)
+	}
+	return storer{storage: storage, metrics: m}
+}
+
+// Unwrap возвращает хранилище под оберткой
+func (s storer) Unwrap() service.Storer {
+	return s.storage
+}
+
+// findPool ищет пул соединений у хранилища или хранилищ под его обертками
+func findPool(storage service.Storer) (PoolStater, bool) {
+	for {
+		if pool, ok := storage.(PoolStater); ok {
+			return pool, true
+		}
+		wrapper, ok := storage.(interface{ Unwrap() service.Storer })
+		if !ok {
+			return nil, false
+		}
+		storage = wrapper.Unwrap()
+	}
+}
+
+// observe записывает время операции и ошибку хранилища. Ошибки, которыми
+// хранилище отвечает на обычные ситуации (ссылки нет, URL уже сокращен),
+// ошибками хранилища не считаются
+func (s storer) observe(operation string, start time.Time, err error) {
+	s.metrics.storeDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && !models.IsExpected(err) {
+		s.metrics.storeErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (s storer) AddLink(ctx context.Context, sToken string, longURL string, user string,
+	expiresAt time.Time) (string, error) {
+	start := time.Now()
+	shortURL, err := s.storage.AddLink(ctx, sToken, longURL, user, expiresAt)
+	s.observe("AddLink", start, err)
+	if err == nil {
+		s.metrics.linksCreated.Inc()
+	}
+	return shortURL, err
+}
+
+func (s storer) GetLongURL(ctx context.Context, sToken string) (string, error) {
+	start := time.Now()
+	longURL, err := s.storage.GetLongURL(ctx, sToken)
+	s.observe("GetLongURL", start, err)
+	// полный URL запрашивается только при переходе по ссылке
+	if err == nil {
+		s.metrics.redirects.Inc()
+	}
+	return longURL, err
+}
+
+func (s storer) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	start := time.Now()
+	longURL, expiresAt, err := s.storage.ResolveURL(ctx, sToken)
+	s.observe("ResolveURL", start, err)
+	return longURL, expiresAt, err
+}
+
+func (s storer) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := s.storage.Ping(ctx)
+	s.observe("Ping", start, err)
+	return err
+}
+
+func (s storer) GetAllURLS(ctx context.Context, cookie string) (map[string]string, error) {
+	start := time.Now()
+	urls, err := s.storage.GetAllURLS(ctx, cookie)
+	s.observe("GetAllURLS", start, err)
+	return urls, err
+}
+
+func (s storer) GetUserURLsPage(ctx context.Context, cookie string, after string,
+	limit int) ([]models.LinksData, error) {
+	start := time.Now()
+	links, err := s.storage.GetUserURLsPage(ctx, cookie, after, limit)
+	s.observe("GetUserURLsPage", start, err)
+	return links, err
+}
+
+func (s storer) ShortenBatch(ctx context.Context, batchReq []models.BatchReq,
+	cookie string) ([]models.BatchResp, error) {
+	start := time.Now()
+	resp, err := s.storage.ShortenBatch(ctx, batchReq, cookie)
+	s.observe("ShortenBatch", start, err)
+	if err == nil {
+		for _, r := range resp {
+			if r.Error == "" {
+				s.metrics.linksCreated.Inc()
+			}
+		}
+	}
+	return resp, err
+}
+
+func (s storer) BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error) {
+	start := time.Now()
+	results, err := s.storage.BatchDelete(ctx, sTokens)
+	s.observe("BatchDelete", start, err)
+	return results, err
+}
+
+func (s storer) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error) {
+	start := time.Now()
+	shortURL, err := s.storage.UpdateURL(ctx, sToken, user, longURL)
+	s.observe("UpdateURL", start, err)
+	return shortURL, err
+}
+
+func (s storer) GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error) {
+	start := time.Now()
+	link, err := s.storage.GetRevisions(ctx, sToken, user)
+	s.observe("GetRevisions", start, err)
+	return link, err
+}
+
+func (s storer) RestoreURL(ctx context.Context, sToken string, user string) error {
+	start := time.Now()
+	err := s.storage.RestoreURL(ctx, sToken, user)
+	s.observe("RestoreURL", start, err)
+	return err
+}
+
+func (s storer) GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error) {
+	start := time.Now()
+	links, err := s.storage.GetDeletedURLs(ctx, user)
+	s.observe("GetDeletedURLs", start, err)
+	return links, err
+}
+
+func (s storer) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	start := time.Now()
+	n, err := s.storage.PurgeDeleted(ctx, before)
+	s.observe("PurgeDeleted", start, err)
+	return n, err
+}
+
+func (s storer) Close() error {
+	return s.storage.Close()
+}
+
+func (s storer) Backend() string {
+	return s.storage.Backend()
+}
+
+func (s storer) ReserveTokens(ctx context.Context, n uint64) (uint64, error) {
+	start := time.Now()
+	from, err := s.storage.ReserveTokens(ctx, n)
+	s.observe("ReserveTokens", start, err)
+	return from, err
+}
+
+func (s storer) GetStorageLen() int {
+	start := time.Now()
+	n := s.storage.GetStorageLen()
+	s.observe("GetStorageLen", start, nil)
+	return n
+}
+
+func (s storer) GetStats(ctx context.Context, top int) (models.Stats, error) {
+	start := time.Now()
+	stats, err := s.storage.GetStats(ctx, top)
+	s.observe("GetStats", start, err)
+	return stats, err
+}
+
+func (s storer) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	start := time.Now()
+	n, err := s.storage.DeleteExpired(ctx, now)
+	s.observe("DeleteExpired", start, err)
+	return n, err
+}
+
+func (s storer) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	start := time.Now()
+	err := s.storage.SaveAPIKey(ctx, key)
+	s.observe("SaveAPIKey", start, err)
+	return err
+}
+
+func (s storer) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	start := time.Now()
+	key, err := s.storage.GetAPIKey(ctx, hash)
+	s.observe("GetAPIKey", start, err)
+	return key, err
+}
+
+func (s storer) RevokeAPIKey(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.storage.RevokeAPIKey(ctx, id)
+	s.observe("RevokeAPIKey", start, err)
+	return err
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewStatusWriter(rec)
+	assert.Equal(t, http.StatusOK, w.Status())
+
+	w.WriteHeader(http.StatusNotFound)
+	// повторный вызов не меняет уже отправленный код
+	w.WriteHeader(http.StatusInternalServerError)
+	assert.Equal(t, http.StatusNotFound, w.Status())
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// ответ без WriteHeader отдается с кодом 200
+	w = NewStatusWriter(httptest.NewRecorder())
+	_, err := w.Write([]byte("ok"))
+	assert.NoError(t, err)
+	w.WriteHeader(http.StatusTeapot)
+	assert.Equal(t, http.StatusOK, w.Status())
+}
@@ -3,25 +3,75 @@ package models
 
 import (
 	"errors"
+	"time"
 )
 
-// в BatchReq передаются данные для batch запросов
+// в BatchReq передаются данные для batch запросов.
+// ExpiresAt (RFC3339) и TTL (в секундах) задают необязательный срок действия ссылки,
+// Expires - вычисленное по ним время, после которого ссылка перестает работать,
+// Token - сокращенный URL, который сервис выбрал для ссылки
 type BatchReq struct {
-	CorrID string `json:"correlation_id"`
-	URL    string `json:"original_url"`
+	CorrID    string    `json:"correlation_id"`
+	URL       string    `json:"original_url"`
+	ExpiresAt string    `json:"expires_at,omitempty"`
+	TTL       int64     `json:"ttl,omitempty"`
+	Expires   time.Time `json:"-"`
+	Token     string    `json:"-"`
 }
 
-// BatchResp используется для передачи ответа в формате json
+// BatchResp используется для передачи ответа в формате json.
+// Если URL из запроса не удалось сократить, причина записывается в Error
 type BatchResp struct {
 	CorrID   string `json:"correlation_id"`
-	ShortURL string `json:"short_url"`
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
 // в структуру LinksData парсим данные из sql запросов
 type LinksData struct {
-	ShortURL string `json:"short"`
-	LongURL  string `json:"long"`
-	User     string `json:"user"`
+	ShortURL  string     `json:"short"`
+	LongURL   string     `json:"long"`
+	User      string     `json:"user"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Типы событий об изменении ссылок пользователя
+const (
+	EventCreated  = "created"
+	EventDeleted  = "deleted"
+	EventUpdated  = "updated"
+	EventRestored = "restored"
+)
+
+// LinkEvent - событие о создании, изменении, удалении или восстановлении ссылки пользователя
+type LinkEvent struct {
+	Type     string
+	ShortURL string
+	LongURL  string
+	User     string
+	Time     time.Time
+}
+
+// DeletedLink - удаленная ссылка пользователя, которую еще можно восстановить
+type DeletedLink struct {
+	ShortURL  string    `json:"short_url"`
+	LongURL   string    `json:"original_url"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Revision - прежний исходный URL ссылки. Ревизии нумеруются с единицы
+// в порядке замены, ReplacedAt - когда URL был заменен
+type Revision struct {
+	Revision   int       `json:"revision"`
+	LongURL    string    `json:"original_url"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// LinkRevisions - текущий исходный URL ссылки и прежние URL
+type LinkRevisions struct {
+	ShortURL  string     `json:"short_url"`
+	LongURL   string     `json:"original_url"`
+	Revisions []Revision `json:"revisions"`
 }
 
 // Структура TokenUser, куда будем накапливать токены URLов, подлежащиe удалению
@@ -30,19 +80,147 @@ type TokenUser struct {
 	User  string
 }
 
+// Результаты удаления ссылки в DeleteResult
+const (
+	DeleteDeleted        = "deleted"
+	DeleteNotFound       = "not_found"
+	DeleteNotOwner       = "not_owner"
+	DeleteAlreadyDeleted = "already_deleted"
+)
+
+// DeleteResult - результат удаления одной ссылки
+type DeleteResult struct {
+	ShortURL string `json:"short_url"`
+	Status   string `json:"status"`
+}
+
 // Структура struct для общего числа пользователей и скоращенных URL
 type Stats struct {
-	URLs  int `json:"urls"`
-	Users int `json:"users"`
+	URLs       int         `json:"urls"`
+	Users      int         `json:"users"`
+	Deleted    int         `json:"deleted"`
+	Created24h int         `json:"created_24h"`
+	Created7d  int         `json:"created_7d"`
+	Redirects  int64       `json:"redirects"`
+	TopUsers   []UserLinks `json:"top_users"`
+	// Cache - счетчики кэша переходов, если он включен
+	Cache *CacheStats `json:"cache,omitempty"`
+}
+
+// CacheStats - попадания и промахи кэша переходов и число записей в нем
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+// UserLinks - число ссылок пользователя
+type UserLinks struct {
+	User  string `json:"user"`
+	Links int    `json:"links"`
+}
+
+// Click - переход по сокращенной ссылке.
+// Вместо IP адреса клиента хранится только его хэш
+type Click struct {
+	Time      time.Time `json:"time"`
+	ShortURL  string    `json:"short_url"`
+	Referer   string    `json:"referer"`
+	UserAgent string    `json:"user_agent"`
+	Country   string    `json:"country"`
+	IPHash    string    `json:"ip_hash"`
+}
+
+// DayClicks - число переходов за день (дата в формате 2006-01-02, UTC)
+type DayClicks struct {
+	Date   string `json:"date"`
+	Clicks int    `json:"clicks"`
 }
 
+// RefererClicks - число переходов с одного источника
+type RefererClicks struct {
+	Referer string `json:"referer"`
+	Clicks  int    `json:"clicks"`
+}
 
+// LinkStats - статистика переходов по сокращенной ссылке
+type LinkStats struct {
+	ShortURL     string          `json:"short_url"`
+	Total        int             `json:"total"`
+	Days         []DayClicks     `json:"days"`
+	TopReferrers []RefererClicks `json:"top_referrers"`
+}
+
+// APIKey - ключ для доступа к API от имени пользователя User.
+// Сам ключ не хранится, по нему ищется хэш Hash
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	User      string    `json:"user"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Названия хранилищ
+const (
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+	BackendPostgres = "postgres"
+	BackendBolt     = "bolt"
+	BackendRedis    = "redis"
+)
+
+// Состояния сервиса в проверке готовности
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+)
+
+// Health - результат проверки готовности сервиса. Status - худшее из
+// состояний отдельных проверок, Backend - хранилище, с которым работает сервис
+type Health struct {
+	Status  string                 `json:"status"`
+	Backend string                 `json:"backend"`
+	Checks  map[string]HealthCheck `json:"checks"`
+}
+
+// HealthCheck - результат одной проверки готовности
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
 
 // Сообщения об ошибках
 var (
-	ErrorAlreadyExist   = errors.New("already exist")
-	ErrLinkNotFound     = errors.New("link is not found")
-	ErrLinkDeleted      = errors.New("link has been deleted")
-	ErrNotTrustedSubnet = errors.New("not trusted subnet")
-	ErrEmptySubnet      = errors.New("empty subnet")
+	ErrorAlreadyExist       = errors.New("already exist")
+	ErrShortURLAlreadyExist = errors.New("short url already exist")
+	ErrInvalidAlias         = errors.New("invalid alias")
+	ErrReservedAlias        = errors.New("alias is reserved")
+	ErrLinkNotFound         = errors.New("link is not found")
+	ErrLinkDeleted          = errors.New("link has been deleted")
+	ErrLinkExpired          = errors.New("link has expired")
+	ErrInvalidExpiry        = errors.New("invalid expiration time")
+	ErrInvalidURL           = errors.New("invalid url")
+	ErrDuplicateURL         = errors.New("duplicate url in batch")
+	ErrNotTrustedSubnet     = errors.New("not trusted subnet")
+	ErrEmptySubnet          = errors.New("empty subnet")
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+	ErrWorkerStalled        = errors.New("delete worker is not running")
+	ErrRevisionNotFound     = errors.New("revision is not found")
 )
+
+// IsExpected сообщает, что хранилище вернуло ошибку на обычную ситуацию
+// (ссылки нет, URL уже сокращен), а не из-за сбоя
+func IsExpected(err error) bool {
+	for _, expected := range []error{
+		ErrorAlreadyExist, ErrShortURLAlreadyExist,
+		ErrLinkNotFound, ErrLinkDeleted, ErrLinkExpired,
+		ErrAPIKeyNotFound,
+	} {
+		if errors.Is(err, expected) {
+			return true
+		}
+	}
+	return false
+}
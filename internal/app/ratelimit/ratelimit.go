@@ -0,0 +1,217 @@
+// Модуль ratelimit ограничивает частоту запросов алгоритмом token bucket.
+// У каждого ключа (пользователя или IP адреса) своя корзина на burst токенов,
+// которая пополняется со скоростью rate токенов в секунду.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/shortener/internal/config"
+)
+
+// sweepInterval - как часто из памяти удаляются корзины давно не активных ключей
+const sweepInterval = time.Minute
+
+// Result - результат проверки лимита для ключа
+type Result struct {
+	Allowed bool
+	// Limit - размер корзины, столько запросов можно сделать подряд
+	Limit int
+	// Remaining - сколько запросов осталось в корзине
+	Remaining int
+	// Reset - через сколько корзина наполнится полностью
+	Reset time.Duration
+	// RetryAfter - через сколько можно повторить отклоненный запрос
+	RetryAfter time.Duration
+}
+
+// Stricter возвращает более строгий из двух результатов
+func (r Result) Stricter(other Result) Result {
+	if r.Allowed != other.Allowed {
+		if !r.Allowed {
+			return r
+		}
+		return other
+	}
+	if other.Remaining < r.Remaining || (other.Remaining == r.Remaining && other.RetryAfter > r.RetryAfter) {
+		return other
+	}
+	return r
+}
+
+// bucket - корзина токенов одного ключа
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter ограничивает частоту запросов по ключам.
+// Нулевой указатель *Limiter пропускает все запросы
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New - конструктор для Limiter. Если rate не положительный,
+// возвращается nil, и ограничение не действует
+func New(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+	return &Limiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow забирает токен из корзины ключа key, если он там есть
+func (l *Limiter) Allow(key string) Result {
+	if l == nil {
+		return Result{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	result := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.burst) - b.tokens)
+	return result
+}
+
+// duration возвращает время, за которое в корзине прибавится tokens токенов
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep удаляет корзины, которые успели наполниться полностью:
+// они ничем не отличаются от новых. Вызывается под мьютексом
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Limits - лимиты для разных видов запросов
+type Limits struct {
+	// Create - создание сокращенных ссылок
+	Create *Limiter
+	// Redirect - переходы по сокращенным ссылкам
+	Redirect *Limiter
+}
+
+// NewLimits создает лимиты по конфигурации
+func NewLimits(cfg config.Config) Limits {
+	return Limits{
+		Create:   New(cfg.RateLimitCreate, cfg.RateLimitCreateBurst),
+		Redirect: New(cfg.RateLimitRedirect, cfg.RateLimitRedirectBurst),
+	}
+}
+
+// UserKey возвращает ключ корзины пользователя
+func UserKey(user string) string {
+	return "user:" + user
+}
+
+// IPKey возвращает ключ корзины IP адреса
+func IPKey(ip net.IP) string {
+	return "ip:" + ip.String()
+}
+
+// ParseNetworks разбирает список подсетей через запятую.
+// Отдельный IP адрес считается подсетью из одного адреса
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ClientIP возвращает IP адрес клиента. Заголовкам X-Real-IP и X-Forwarded-For
+// можно верить, только если запрос пришел от доверенного прокси: иначе клиент
+// подставил бы в них любой адрес. remoteIP - адрес, с которого пришло соединение
+func ClientIP(remoteIP net.IP, realIP string, forwardedFor string, trusted []*net.IPNet) net.IP {
+	if remoteIP == nil || !contains(trusted, remoteIP) {
+		return remoteIP
+	}
+	if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+		return ip
+	}
+	// в X-Forwarded-For каждый прокси дописывает адрес справа, поэтому идем
+	// справа налево до первого адреса, который не принадлежит доверенным прокси
+	ip := remoteIP
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !contains(trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// contains проверяет, входит ли ip в одну из подсетей
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
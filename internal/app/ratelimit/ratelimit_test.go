@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := New(1, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		result := l.Allow("key")
+		require.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+	result := l.Allow("key")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.Reset)
+
+	// у другого ключа своя корзина
+	assert.True(t, l.Allow("other").Allowed)
+
+	// за секунду корзина пополняется на один токен
+	now = now.Add(time.Second)
+	assert.True(t, l.Allow("key").Allowed)
+	assert.False(t, l.Allow("key").Allowed)
+
+	// наполнившиеся корзины удаляются из памяти
+	now = now.Add(sweepInterval)
+	l.Allow("key")
+	assert.Len(t, l.buckets, 1)
+
+	// без лимита разрешено все
+	var unlimited *Limiter
+	assert.Nil(t, New(0, 10))
+	assert.True(t, unlimited.Allow("key").Allowed)
+}
+
+func TestStricter(t *testing.T) {
+	allowed := Result{Allowed: true, Limit: 10, Remaining: 5}
+	low := Result{Allowed: true, Limit: 10, Remaining: 1}
+	denied := Result{Limit: 10, RetryAfter: time.Second}
+
+	assert.Equal(t, low, allowed.Stricter(low))
+	assert.Equal(t, low, low.Stricter(allowed))
+	assert.Equal(t, denied, allowed.Stricter(denied))
+	assert.Equal(t, denied, denied.Stricter(allowed))
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks("10.0.0.0/8, 192.168.1.1,::1")
+	require.NoError(t, err)
+	require.Len(t, networks, 3)
+	assert.Equal(t, "192.168.1.1/32", networks[1].String())
+	assert.Equal(t, "::1/128", networks[2].String())
+
+	networks, err = ParseNetworks("")
+	require.NoError(t, err)
+	assert.Empty(t, networks)
+
+	_, err = ParseNetworks("10.0.0.0/8,proxy")
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNetworks("10.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remote       string
+		realIP       string
+		forwardedFor string
+		want         string
+	}{
+		{name: "direct", remote: "1.2.3.4", want: "1.2.3.4"},
+		{name: "spoofed headers from untrusted", remote: "1.2.3.4", realIP: "5.6.7.8", forwardedFor: "5.6.7.8", want: "1.2.3.4"},
+		{name: "real ip from proxy", remote: "10.0.0.1", realIP: "5.6.7.8", want: "5.6.7.8"},
+		{name: "forwarded for from proxy", remote: "10.0.0.1", forwardedFor: "5.6.7.8", want: "5.6.7.8"},
+		{name: "proxy chain", remote: "10.0.0.1", forwardedFor: "5.6.7.8, 10.0.0.2", want: "5.6.7.8"},
+		{name: "spoofed first hop", remote: "10.0.0.1", forwardedFor: "9.9.9.9, 5.6.7.8", want: "5.6.7.8"},
+		{name: "no headers from proxy", remote: "10.0.0.1", want: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip := ClientIP(net.ParseIP(tt.remote), tt.realIP, tt.forwardedFor, trusted)
+			assert.Equal(t, tt.want, ip.String())
+		})
+	}
+}
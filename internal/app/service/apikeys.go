@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/session"
+)
+
+// APIKeyPrefix - префикс, по которому API ключ отличается от других токенов
+const APIKeyPrefix = "sk_"
+
+// apiKeyBytes - число случайных байт в API ключе
+const apiKeyBytes = 32
+
+// IssueAPIKey выпускает API ключ для пользователя user. Если user пустой,
+// для ключа заводится новый пользователь. Ключ возвращается только здесь,
+// в хранилище остается его хэш
+func (s Service) IssueAPIKey(ctx context.Context, user string, name string) (models.APIKey, string, error) {
+	if user == "" {
+		var err error
+		user, err = session.NewUser()
+		if err != nil {
+			return models.APIKey{}, "", err
+		}
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(id); err != nil {
+		return models.APIKey{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, "", err
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := models.APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		User:      user,
+		Hash:      hashAPIKey(key),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.storage.SaveAPIKey(ctx, apiKey); err != nil {
+		return models.APIKey{}, "", err
+	}
+	return apiKey, key, nil
+}
+
+// RevokeAPIKey отзывает API ключ по идентификатору
+func (s Service) RevokeAPIKey(ctx context.Context, id string) error {
+	return s.storage.RevokeAPIKey(ctx, id)
+}
+
+// UserByAPIKey возвращает пользователя, которому выпущен API ключ
+func (s Service) UserByAPIKey(ctx context.Context, key string) (string, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return "", models.ErrAPIKeyNotFound
+	}
+	apiKey, err := s.storage.GetAPIKey(ctx, hashAPIKey(key))
+	if err != nil {
+		return "", err
+	}
+	return apiKey.User, nil
+}
+
+// hashAPIKey возвращает хэш API ключа. В ключе достаточно случайных байт,
+// поэтому соль и медленный хэш не нужны
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"sync"
+
+	"example.com/shortener/internal/app/models"
+)
+
+// eventBuffer - размер буфера канала подписчика. Подписчик, который
+// не успевает читать события, отключается, чтобы не задерживать остальных
+const eventBuffer = 64
+
+// EventHub рассылает события об изменении ссылок подписчикам пользователя
+type EventHub struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan models.LinkEvent]struct{}
+	closed bool
+}
+
+// NewEventHub - конструктор для EventHub
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subs: make(map[string]map[chan models.LinkEvent]struct{}),
+	}
+}
+
+// Subscribe подписывает на события ссылок пользователя user.
+// Канал закрывается после вызова cancel, при закрытии EventHub
+// или если подписчик не успевает читать события
+func (h *EventHub) Subscribe(user string) (<-chan models.LinkEvent, func()) {
+	ch := make(chan models.LinkEvent, eventBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subs[user] == nil {
+		h.subs[user] = make(map[chan models.LinkEvent]struct{})
+	}
+	h.subs[user][ch] = struct{}{}
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(user, ch)
+	}
+	return ch, cancel
+}
+
+// Publish отправляет события подписчикам пользователей, к которым они относятся
+func (h *EventHub) Publish(events ...models.LinkEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, event := range events {
+		for ch := range h.subs[event.User] {
+			select {
+			case ch <- event:
+			default:
+				h.remove(event.User, ch)
+			}
+		}
+	}
+}
+
+// Close закрывает каналы всех подписчиков
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for user, subs := range h.subs {
+		for ch := range subs {
+			h.remove(user, ch)
+		}
+	}
+	h.closed = true
+}
+
+// remove отписывает канал и закрывает его, если он еще подписан.
+// Вызывается под мьютексом
+func (h *EventHub) remove(user string, ch chan models.LinkEvent) {
+	subs, ok := h.subs[user]
+	if !ok {
+		return
+	}
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(h.subs, user)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+)
+
+// Проверки готовности сервиса
+const (
+	CheckStorage = "storage"
+	CheckFile    = "file"
+	CheckWorker  = "delete_worker"
+)
+
+// healthTimeout - сколько ждать ответа хранилища при проверке готовности
+const healthTimeout = 2 * time.Second
+
+// workerStallTimeout - сколько цикл удаления может не проходить, прежде чем
+// считается зависшим. В простое он просыпается по таймеру раз в полсекунды
+var workerStallTimeout = 10 * time.Second
+
+// Health проверяет готовность сервиса: доступность хранилища, возможность
+// записи в файл журнала (для хранилища file) и работу цикла
+// удаления ссылок. Недоступное хранилище делает сервис неготовым,
+// остальные сбои - работающим с ограничениями (degraded)
+func (s Service) Health(ctx context.Context) models.Health {
+	health := models.Health{
+		Status:  models.HealthOK,
+		Backend: s.storage.Backend(),
+		Checks:  make(map[string]models.HealthCheck),
+	}
+	report := func(name string, err error, failed string) {
+		check := models.HealthCheck{Status: models.HealthOK}
+		if err != nil {
+			check = models.HealthCheck{Status: failed, Error: err.Error()}
+			if failed == models.HealthUnavailable || health.Status == models.HealthOK {
+				health.Status = failed
+			}
+		}
+		health.Checks[name] = check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	report(CheckStorage, s.storage.Ping(ctx), models.HealthUnavailable)
+
+	if health.Backend == models.BackendFile {
+		report(CheckFile, checkWritable(s.Config.File), models.HealthDegraded)
+	}
+
+	var workerErr error
+	if beat := time.Unix(0, atomic.LoadInt64(s.workerBeat)); time.Since(beat) > workerStallTimeout {
+		workerErr = models.ErrWorkerStalled
+	}
+	report(CheckWorker, workerErr, models.HealthDegraded)
+	return health
+}
+
+// checkWritable проверяет, что в файл можно дописывать
+func checkWritable(filename string) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0664)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
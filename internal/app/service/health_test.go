@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/storage/memory"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableStorer - хранилище, до которого нельзя достучаться
+type unreachableStorer struct {
+	*memory.MemoryStorage
+}
+
+func (unreachableStorer) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+// fileStorer - хранилище file, файл которого задан в конфигурации сервиса
+type fileStorer struct {
+	*memory.MemoryStorage
+}
+
+func (fileStorer) Backend() string {
+	return models.BackendFile
+}
+
+func TestHealth(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		serv := New(config.Config{}, memory.New(config.Config{}, log), log)
+		defer serv.Close()
+		health := serv.Health(ctx)
+		assert.Equal(t, models.HealthOK, health.Status)
+		assert.Equal(t, models.BackendMemory, health.Backend)
+		assert.Equal(t, models.HealthOK, health.Checks[CheckStorage].Status)
+		assert.Equal(t, models.HealthOK, health.Checks[CheckWorker].Status)
+		// без файла проверять нечего
+		assert.NotContains(t, health.Checks, CheckFile)
+	})
+
+	t.Run("file is not writable", func(t *testing.T) {
+		// в каталог нельзя дописывать даже от root
+		serv := New(config.Config{File: t.TempDir()}, fileStorer{memory.New(config.Config{}, log)}, log)
+		defer serv.Close()
+		health := serv.Health(ctx)
+		assert.Equal(t, models.BackendFile, health.Backend)
+		assert.Equal(t, models.HealthDegraded, health.Status)
+		assert.Equal(t, models.HealthDegraded, health.Checks[CheckFile].Status)
+		assert.NotEmpty(t, health.Checks[CheckFile].Error)
+	})
+
+	t.Run("storage is unreachable", func(t *testing.T) {
+		serv := New(config.Config{File: t.TempDir()}, unreachableStorer{memory.New(config.Config{}, log)}, log)
+		defer serv.Close()
+		health := serv.Health(ctx)
+		// недоступное хранилище важнее остальных сбоев
+		assert.Equal(t, models.HealthUnavailable, health.Status)
+		assert.Equal(t, "connection refused", health.Checks[CheckStorage].Error)
+	})
+
+	t.Run("delete worker stalled", func(t *testing.T) {
+		serv := New(config.Config{}, memory.New(config.Config{}, log), log)
+		// после закрытия сервиса цикл удаления остановлен
+		require.NoError(t, serv.Close())
+		atomic.StoreInt64(serv.workerBeat, time.Now().Add(-time.Hour).UnixNano())
+		health := serv.Health(ctx)
+		assert.Equal(t, models.HealthDegraded, health.Status)
+		assert.Equal(t, models.ErrWorkerStalled.Error(), health.Checks[CheckWorker].Error)
+	})
+}
@@ -4,30 +4,89 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	urlNet "net/url"
 
+	"example.com/shortener/internal/app/analytics"
+	"example.com/shortener/internal/app/keyring"
 	"example.com/shortener/internal/app/models"
 	"example.com/shortener/internal/config"
-	"example.com/shortener/internal/config/utils"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
 // Storer - интерфейс взаимодействия с хранилищем
 type Storer interface {
-	AddLink(ctx context.Context, sToken string, longURL string, user string) (string, error)
+	AddLink(ctx context.Context, sToken string, longURL string, user string, expiresAt time.Time) (string, error)
 	GetLongURL(ctx context.Context, sToken string) (string, error)
+	// ResolveURL возвращает исходный URL и срок действия ссылки,
+	// нулевой, если срок не задан. Ошибки те же, что у GetLongURL
+	ResolveURL(ctx context.Context, sToken string) (string, time.Time, error)
 	Ping(ctx context.Context) error
 	GetAllURLS(ctx context.Context, cookie string) (map[string]string, error)
+	GetUserURLsPage(ctx context.Context, cookie string, after string, limit int) ([]models.LinksData, error)
+	// ShortenBatch сохраняет ссылки с токенами из BatchReq.Token и возвращает
+	// ответ на каждый элемент batchReq в том же порядке.
+	// Для URL, которые уже есть в хранилище, в ответе указывается
+	// существующий сокращенный URL и ошибка models.ErrorAlreadyExist.
+	// Если какой-то из токенов занят, не сохраняется ничего
+	// и возвращается models.ErrShortURLAlreadyExist
 	ShortenBatch(ctx context.Context, batchReq []models.BatchReq, cookie string) ([]models.BatchResp, error)
-	BatchDelete(ctx context.Context, sTokens []models.TokenUser)
+	// BatchDelete ставит метки удаления на ссылки и возвращает результат
+	// для каждого токена в порядке запроса
+	BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error)
 	Close() error
 	GetStorageLen() int
-	GetStats(ctx context.Context) models.Stats
+	// ReserveTokens резервирует n номеров для последовательных токенов
+	// и возвращает последний номер перед резервом: вызывающему принадлежат
+	// номера с from+1 по from+n. Верхняя граница сохраняется в хранилище,
+	// поэтому номера не выдаются повторно после удаления ссылок и перезапуска
+	ReserveTokens(ctx context.Context, n uint64) (uint64, error)
+	GetStats(ctx context.Context, top int) (models.Stats, error)
+	// DeleteExpired ставит метку удаления на ссылки, срок действия которых
+	// истек к моменту now, временем удаления становится срок действия.
+	// Переходы по ним по-прежнему отвечают 410, а окончательно ссылки
+	// удаляет PurgeDeleted. Возвращает число помеченных ссылок
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	// RestoreURL снимает метку удаления со ссылки пользователя user.
+	// Возвращает models.ErrLinkNotFound, если ссылки нет или она чужая.
+	// Восстановление неудаленной ссылки ничего не меняет
+	RestoreURL(ctx context.Context, sToken string, user string) error
+	// GetDeletedURLs возвращает удаленные ссылки пользователя
+	// в порядке сокращенных URL
+	GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error)
+	// PurgeDeleted окончательно удаляет ссылки, удаленные не позже before,
+	// и возвращает их число
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+	// SaveAPIKey сохраняет API ключ, вместо самого ключа хранится его хэш
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+	// GetAPIKey ищет API ключ по хэшу и возвращает models.ErrAPIKeyNotFound,
+	// если ключа нет или он отозван
+	GetAPIKey(ctx context.Context, hash string) (models.APIKey, error)
+	// RevokeAPIKey отзывает API ключ по идентификатору
+	// или возвращает models.ErrAPIKeyNotFound
+	RevokeAPIKey(ctx context.Context, id string) error
+	// UpdateURL меняет исходный URL ссылки пользователя user, прежний URL
+	// сохраняется в истории ревизий. Возвращает models.ErrLinkNotFound, если
+	// ссылки нет или она чужая, и models.ErrLinkDeleted для удаленной ссылки.
+	// Если longURL уже сокращен другой ссылкой, возвращается ее токен
+	// и models.ErrorAlreadyExist. Замена URL на тот же ничего не меняет
+	UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error)
+	// GetRevisions возвращает текущий и прежние исходные URL ссылки
+	// пользователя user или models.ErrLinkNotFound
+	GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error)
+	// Backend возвращает название хранилища для проверки готовности
+	Backend() string
 }
 
 var once sync.Once
@@ -35,14 +94,32 @@ var once sync.Once
 // Service - реализует методы, которые подготавливают и обрабатывают
 // данные из модуля handlers для последующей передачи в хранилище
 type Service struct {
-	Config  config.Config
-	storage Storer
-	Once    *sync.Once
-	OutCh   chan string
-	userCh  chan string
-	log     *logrus.Logger
+	Config    config.Config
+	storage   Storer
+	Once      *sync.Once
+	OutCh     chan string
+	userCh    chan string
+	log       *logrus.Logger
+	cancel    context.CancelFunc
+	analytics *analytics.Recorder
+	events    *EventHub
+	tokens    TokenGenerator
+	// tokenLength - текущая длина токенов, растет при заполнении пространства токенов
+	tokenLength *int32
+	cookieKeys  *keyring.Keyring
+	jwtKeys     *keyring.Keyring
+	// workerBeat - время последнего прохода цикла удаления (UnixNano)
+	workerBeat *int64
 }
 
+// Ключи, которыми подписывались куки и jwt токены до появления настроек
+// COOKIE_KEYS и JWT_KEYS. Они опубликованы в исходниках, поэтому подписывать
+// ими нельзя: их можно указать в списке только для проверки выданных раньше
+const (
+	legacyCookieSecret = "secret key"
+	legacyJWTSecret    = "secret"
+)
+
 // New - конструктор для пакета service
 func New(cfg config.Config, storage Storer, log *logrus.Logger) *Service {
 	service := &Service{
@@ -51,17 +128,75 @@ func New(cfg config.Config, storage Storer, log *logrus.Logger) *Service {
 		OutCh:   make(chan string, config.BatchSize),
 		userCh:  make(chan string),
 		log:     log,
+		events:  NewEventHub(),
+		// до первого прохода цикла удаления считаем его живым
+		workerBeat: new(int64),
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	go service.RecieveTokensFromChannel(ctx)
-	time.AfterFunc(60*time.Second, func() {
-		log.Debug("Запускаем cancel")
-		cancel()
+	atomic.StoreInt64(service.workerBeat, time.Now().UnixNano())
+
+	tokens, err := NewTokenGenerator(cfg, func(n uint64) (uint64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), reserveTimeout)
+		defer cancel()
+		return storage.ReserveTokens(ctx, n)
 	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	length := int32(cfg.TokenLength)
+	if length <= 0 {
+		length = DefaultTokenLength
+	}
+	if length > MaxTokenLength {
+		length = MaxTokenLength
+	}
+	service.tokens = tokens
+	service.tokenLength = &length
+
+	service.cookieKeys, err = parseKeys("COOKIE_KEYS", cfg.CookieKeys, legacyCookieSecret, log)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	service.jwtKeys, err = parseKeys("JWT_KEYS", cfg.JWTKeys, legacyJWTSecret, log)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	// раньше доверенная подсеть проверялась по X-Real-IP от любого клиента
+	if cfg.Subnet != "" && strings.TrimSpace(cfg.TrustedProxies) == "" {
+		log.Warn("TRUSTED_SUBNET задан без TRUSTED_PROXIES: заголовок X-Real-IP не учитывается, " +
+			"в доверенной подсети проверяется адрес соединения. Если сервис работает за прокси, добавьте его в TRUSTED_PROXIES")
+	}
+
+	// фоновые задачи работают до закрытия сервиса
+	var ctx context.Context
+	ctx, service.cancel = context.WithCancel(context.Background())
+	go service.RecieveTokensFromChannel(ctx)
+
+	// переходы по ссылкам сохраняются асинхронно
+	geo, err := analytics.LoadGeoTable(cfg.GeoFile)
+	if err != nil {
+		log.Error(err.Error())
+	}
+	clicks := analytics.New(findPool(storage), MaxStatsDays)
+	service.analytics = analytics.NewRecorder(clicks, geo, cfg.AnalyticsSalt, log)
+
+	// очистка ссылок с истекшим сроком действия
+	if cfg.ExpireInterval > 0 {
+		go service.SweepExpired(ctx, cfg.ExpireInterval)
+	}
 
 	return service
 }
 
+// CookieKeys возвращает ключи подписи куки User
+func (s Service) CookieKeys() *keyring.Keyring {
+	return s.cookieKeys
+}
+
+// JWTKeys возвращает ключи подписи jwt токенов
+func (s Service) JWTKeys() *keyring.Keyring {
+	return s.jwtKeys
+}
+
 // AddDeletedTokens складывает токены, переданные пользователем для удаления в канал
 // service.outCh
 func (s Service) AddDeletedTokens(sTokens []string, user string) {
@@ -78,22 +213,29 @@ func (s Service) AddDeletedTokens(sTokens []string, user string) {
 
 }
 
-var deletedTokens = make([]models.TokenUser, 0, config.BatchSize*2)
-
 // RecieveTokensFromChannel получает куки пользователя
 // из канала service.userCh, токены для удаления из канала service.outCh
 // и запускает удаление с помощью batch запроса
 func (s Service) RecieveTokensFromChannel(ctx context.Context) {
 	var user string
+	deletedTokens := make([]models.TokenUser, 0, config.BatchSize*2)
 	s.log.Debug("Запустили канал")
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 	// считываем значения из канала, пока он не будет закрыт
 	for {
+		atomic.StoreInt64(s.workerBeat, time.Now().UnixNano())
 		select {
-		case u := <-s.userCh:
+		case u, ok := <-s.userCh:
+			if !ok {
+				return
+			}
 			user = u
-		case x := <-s.OutCh:
+		case x, ok := <-s.OutCh:
+			if !ok {
+				s.deleteTokens(ctx, deletedTokens)
+				return
+			}
 			s.log.WithFields(logrus.Fields{"cookie": user}).Debug("Куки в RecieveTokensFromChannel")
 			deletedTokens = append(deletedTokens, models.TokenUser{
 				Token: x,
@@ -103,11 +245,11 @@ func (s Service) RecieveTokensFromChannel(ctx context.Context) {
 				Debug("Приняли токенов из канала")
 			if len(deletedTokens) >= config.BatchSize {
 				s.log.WithFields(logrus.Fields{"deleted tokens": deletedTokens})
-				s.storage.BatchDelete(ctx, deletedTokens)
+				s.deleteTokens(ctx, deletedTokens)
 				deletedTokens = deletedTokens[:0]
 			}
 		case <-ticker.C:
-			s.storage.BatchDelete(ctx, deletedTokens)
+			s.deleteTokens(ctx, deletedTokens)
 			deletedTokens = deletedTokens[:0]
 
 		case <-ctx.Done():
@@ -117,6 +259,72 @@ func (s Service) RecieveTokensFromChannel(ctx context.Context) {
 	}
 }
 
+// deleteTokens удаляет ссылки из хранилища в фоне, ошибки только логируются
+func (s Service) deleteTokens(ctx context.Context, tokens []models.TokenUser) {
+	if len(tokens) == 0 {
+		return
+	}
+	if _, err := s.batchDelete(ctx, tokens); err != nil {
+		s.log.Error(err.Error())
+	}
+}
+
+// DeleteURLs сразу удаляет ссылки пользователя user и возвращает результат
+// по каждому токену в порядке запроса
+func (s Service) DeleteURLs(ctx context.Context, sTokens []string, user string) ([]models.DeleteResult, error) {
+	tokens := make([]models.TokenUser, 0, len(sTokens))
+	for _, token := range sTokens {
+		tokens = append(tokens, models.TokenUser{Token: s.GetLongToken(token), User: user})
+	}
+	return s.batchDelete(ctx, tokens)
+}
+
+// batchDelete удаляет ссылки из хранилища и уведомляет подписчиков
+// о действительно удаленных
+func (s Service) batchDelete(ctx context.Context, tokens []models.TokenUser) ([]models.DeleteResult, error) {
+	results, err := s.storage.BatchDelete(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	events := make([]models.LinkEvent, 0, len(results))
+	for i, r := range results {
+		if r.Status != models.DeleteDeleted {
+			continue
+		}
+		events = append(events, models.LinkEvent{
+			Type:     models.EventDeleted,
+			ShortURL: r.ShortURL,
+			User:     tokens[i].User,
+			Time:     now,
+		})
+	}
+	s.events.Publish(events...)
+	return results, nil
+}
+
+// RestoreURL восстанавливает удаленную ссылку с токеном sToken,
+// если ее сократил пользователь user, и уведомляет об этом подписчиков
+func (s Service) RestoreURL(ctx context.Context, sToken string, user string) error {
+	shortURL := s.GetLongToken(sToken)
+	if err := s.storage.RestoreURL(ctx, shortURL, user); err != nil {
+		return err
+	}
+	s.events.Publish(models.LinkEvent{
+		Type:     models.EventRestored,
+		ShortURL: shortURL,
+		User:     user,
+		Time:     time.Now(),
+	})
+	return nil
+}
+
+// GetDeletedURLs возвращает удаленные ссылки пользователя, которые еще можно восстановить
+func (s Service) GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error) {
+	return s.storage.GetDeletedURLs(ctx, user)
+}
+
 // GetLongToken склеивает BaseURL с токеном
 func (s Service) GetLongToken(sToken string) string {
 	longToken := s.Config.BaseURL + sToken
@@ -128,10 +336,185 @@ func (s Service) GetLongToken(sToken string) string {
 	return longToken
 }
 
-// AddLink сохраняет сокращенный URL в хранилище
-func (s Service) AddLink(ctx context.Context, sToken string, longURL string, user string) (string, error) {
-	token := utils.GenRandToken(s.Config.BaseURL)
-	return s.storage.AddLink(ctx, token, longURL, user)
+// Ограничения для псевдонимов, которые пользователь выбирает сам
+var (
+	aliasPattern    = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,64}$`)
+	reservedAliases = map[string]struct{}{
+		"api":     {},
+		"ping":    {},
+		"debug":   {},
+		"healthz": {},
+		"readyz":  {},
+	}
+)
+
+// ValidateAlias проверяет, что псевдоним состоит из допустимых символов,
+// подходит по длине и не совпадает с зарезервированными путями сервиса
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return models.ErrInvalidAlias
+	}
+	if _, ok := reservedAliases[strings.ToLower(alias)]; ok {
+		return models.ErrReservedAlias
+	}
+	return nil
+}
+
+// ParseExpiry вычисляет время окончания действия ссылки по абсолютной дате
+// в формате RFC3339 или по времени жизни в секундах.
+// Если не задано ни то, ни другое, возвращается нулевое время - ссылка бессрочная
+func ParseExpiry(expiresAt string, ttl int64) (time.Time, error) {
+	if ttl < 0 || (expiresAt != "" && ttl != 0) {
+		return time.Time{}, models.ErrInvalidExpiry
+	}
+	if ttl > 0 {
+		return time.Now().Add(time.Duration(ttl) * time.Second), nil
+	}
+	if expiresAt == "" {
+		return time.Time{}, nil
+	}
+
+	expires, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil || !expires.After(time.Now()) {
+		return time.Time{}, models.ErrInvalidExpiry
+	}
+	return expires, nil
+}
+
+// findPool возвращает пул соединений с бд хранилища или хранилищ
+// под его обертками (трассировка, метрики, кэш), если он есть
+func findPool(storage Storer) *pgxpool.Pool {
+	for {
+		if pool, ok := storage.(interface{ Pool() *pgxpool.Pool }); ok {
+			return pool.Pool()
+		}
+		wrapper, ok := storage.(interface{ Unwrap() Storer })
+		if !ok {
+			return nil
+		}
+		storage = wrapper.Unwrap()
+	}
+}
+
+// parseKeys разбирает ключи подписи из настройки name. Прежний встроенный ключ
+// legacy не может быть текущим. Если ключи не заданы, используется случайный
+// ключ процесса, о чем пишется предупреждение
+func parseKeys(name string, list string, legacy string, log *logrus.Logger) (*keyring.Keyring, error) {
+	keys, err := keyring.Parse(list)
+	if err != nil {
+		return nil, err
+	}
+	if _, secret := keys.Current(); string(secret) == legacy {
+		return nil, fmt.Errorf("%s: the built-in legacy key can only verify, put a new key first", name)
+	}
+	if list == "" {
+		log.WithFields(logrus.Fields{"setting": name}).
+			Warn("Ключи подписи не заданы, используется случайный ключ: подписи не переживут перезапуск и не совпадут на других репликах")
+	}
+	return keys, nil
+}
+
+// AddLink сохраняет сокращенный URL в хранилище.
+// Если alias не пустой, он используется вместо сгенерированного токена.
+// Нулевое значение expiresAt означает бессрочную ссылку
+func (s Service) AddLink(ctx context.Context, alias string, longURL string, user string,
+	expiresAt time.Time) (string, error) {
+	if alias != "" {
+		if err := ValidateAlias(alias); err != nil {
+			return "", err
+		}
+		token, err := s.storage.AddLink(ctx, s.GetLongToken(alias), longURL, user, expiresAt)
+		if err == nil {
+			s.publishCreated(user, token, longURL)
+		}
+		return token, err
+	}
+
+	// если сгенерированный токен уже занят, генерируем новый
+	for attempt := 0; ; attempt++ {
+		token, length, err := s.newToken(longURL, attempt)
+		if err != nil {
+			return "", err
+		}
+		token, err = s.storage.AddLink(ctx, token, longURL, user, expiresAt)
+		if !errors.Is(err, models.ErrShortURLAlreadyExist) {
+			if err == nil {
+				s.publishCreated(user, token, longURL)
+			}
+			return token, err
+		}
+		if !s.tokenCollision(attempt, length) {
+			return "", err
+		}
+	}
+}
+
+// Параметры повторной генерации токена при совпадении с уже занятым
+const (
+	// maxTokenAttempts - число попыток сохранить ссылку со сгенерированным токеном
+	maxTokenAttempts = 10
+	// growAfterCollisions - после стольких совпадений подряд длина токена
+	// увеличивается на единицу: токены текущей длины почти все заняты
+	growAfterCollisions = 3
+	// reserveTimeout - таймаут резервирования номеров последовательных токенов
+	reserveTimeout = 5 * time.Second
+)
+
+// newToken генерирует токен текущей длины и возвращает сокращенный URL и длину токена
+func (s Service) newToken(longURL string, attempt int) (string, int, error) {
+	for {
+		length := int(atomic.LoadInt32(s.tokenLength))
+		token, err := s.tokens.Generate(longURL, length, attempt)
+		if errors.Is(err, errKeyspaceExhausted) && s.growTokenLength(length) {
+			continue
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		return s.GetLongToken(token), length, nil
+	}
+}
+
+// tokenCollision обрабатывает совпадение токена длины length с занятым
+// и возвращает false, если попытки исчерпаны. Если совпадения идут подряд,
+// длина токена увеличивается. Последовательные токены не повторяются,
+// занятыми оказываются только номера, выданные до появления счетчика
+// в хранилище, или совпавшие с alias. Такие номера пропускаются без ограничения
+// числа попыток и без роста длины
+func (s Service) tokenCollision(attempt int, length int) bool {
+	if _, ok := s.tokens.(SequentialGenerator); ok {
+		return true
+	}
+	if attempt+1 >= maxTokenAttempts {
+		return false
+	}
+	if (attempt+1)%growAfterCollisions == 0 {
+		s.growTokenLength(length)
+	}
+	return true
+}
+
+// growTokenLength увеличивает длину токена, если она все еще равна length.
+// Возвращает false, если длина уже максимальная
+func (s Service) growTokenLength(length int) bool {
+	if length >= MaxTokenLength {
+		return false
+	}
+	if atomic.CompareAndSwapInt32(s.tokenLength, int32(length), int32(length+1)) {
+		s.log.WithFields(logrus.Fields{"length": length + 1}).Info("Увеличили длину токена")
+	}
+	return true
+}
+
+// publishCreated уведомляет подписчиков пользователя о созданной ссылке
+func (s Service) publishCreated(user string, shortURL string, longURL string) {
+	s.events.Publish(models.LinkEvent{
+		Type:     models.EventCreated,
+		ShortURL: shortURL,
+		LongURL:  longURL,
+		User:     user,
+		Time:     time.Now(),
+	})
 }
 
 // GetLongURL возвращает исходный URL из хранилища
@@ -139,14 +522,227 @@ func (s Service) GetLongURL(ctx context.Context, sToken string) (string, error)
 	return s.storage.GetLongURL(ctx, sToken)
 }
 
+// RecordClick ставит переход по ссылке в очередь на сохранение в статистику
+func (s Service) RecordClick(shortURL, referer, userAgent string, ip net.IP) {
+	s.analytics.Record(shortURL, referer, userAgent, ip)
+}
+
+// Параметры статистики переходов по ссылке
+const (
+	DefaultStatsDays = 30
+	MaxStatsDays     = 365
+	topReferrers     = 10
+)
+
+// GetLinkStats возвращает статистику переходов за последние days дней
+// по ссылке с токеном sToken, если ее сократил пользователь user
+func (s Service) GetLinkStats(ctx context.Context, sToken string, user string, days int) (models.LinkStats, error) {
+	shortURL := s.GetLongToken(sToken)
+	links, err := s.storage.GetAllURLS(ctx, user)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+	if _, ok := links[shortURL]; !ok {
+		return models.LinkStats{}, models.ErrLinkNotFound
+	}
+	return s.analytics.GetLinkStats(ctx, shortURL, days, topReferrers)
+}
+
 // GetAllURLs возвращает все URL пользователя
 func (s Service) GetAllURLS(ctx context.Context, cookie string) (map[string]string, error) {
 	return s.storage.GetAllURLS(ctx, cookie)
 }
 
-// ShortenBatch обрабатывает URL, переданные в виде JSON объектов
+// Размер страницы при постраничной выборке ссылок пользователя
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// GetUserURLsPage возвращает страницу ссылок пользователя, следующих
+// за сокращенным URL after. Размер страницы ограничивается MaxPageSize
+func (s Service) GetUserURLsPage(ctx context.Context, cookie string, after string,
+	limit int) ([]models.LinksData, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return s.storage.GetUserURLsPage(ctx, cookie, after, limit)
+}
+
+// UpdateURL меняет исходный URL ссылки с токеном sToken, если ее сократил
+// пользователь user. Прежний URL сохраняется в истории ревизий. Если longURL
+// уже сокращен другой ссылкой, вместе с models.ErrorAlreadyExist в ShortURL
+// возвращается ее сокращенный URL
+func (s Service) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (models.LinkRevisions, error) {
+	if err := ValidateURL(longURL); err != nil {
+		return models.LinkRevisions{}, err
+	}
+	shortURL := s.GetLongToken(sToken)
+	existing, err := s.storage.UpdateURL(ctx, shortURL, user, longURL)
+	if err != nil {
+		return models.LinkRevisions{ShortURL: existing}, err
+	}
+	s.events.Publish(models.LinkEvent{
+		Type:     models.EventUpdated,
+		ShortURL: shortURL,
+		LongURL:  longURL,
+		User:     user,
+		Time:     time.Now(),
+	})
+	return s.storage.GetRevisions(ctx, shortURL, user)
+}
+
+// RollbackURL возвращает ссылке исходный URL из ревизии revision.
+// Откат тоже сохраняется в истории как новая ревизия
+func (s Service) RollbackURL(ctx context.Context, sToken string, user string, revision int) (models.LinkRevisions, error) {
+	link, err := s.GetRevisions(ctx, sToken, user)
+	if err != nil {
+		return models.LinkRevisions{}, err
+	}
+	for _, r := range link.Revisions {
+		if r.Revision == revision {
+			return s.UpdateURL(ctx, sToken, user, r.LongURL)
+		}
+	}
+	return models.LinkRevisions{}, models.ErrRevisionNotFound
+}
+
+// GetRevisions возвращает текущий и прежние исходные URL ссылки пользователя
+func (s Service) GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error) {
+	return s.storage.GetRevisions(ctx, s.GetLongToken(sToken), user)
+}
+
+// WatchUserURLs подписывает на события создания, изменения и удаления ссылок пользователя.
+// После окончания работы нужно вызвать cancel
+func (s Service) WatchUserURLs(user string) (<-chan models.LinkEvent, func()) {
+	return s.events.Subscribe(user)
+}
+
+// ShortenBatch обрабатывает URL, переданные в виде JSON объектов.
+// Некорректные URL и повторы внутри пакета не прерывают обработку:
+// для них в ответе заполняется поле Error, остальные URL сокращаются
 func (s Service) ShortenBatch(ctx context.Context, batchReq []models.BatchReq, cookie string) ([]models.BatchResp, error) {
-	return s.storage.ShortenBatch(ctx, batchReq, cookie)
+	response := make([]models.BatchResp, len(batchReq))
+	valid := make([]models.BatchReq, 0, len(batchReq))
+	// positions[i] - индекс valid[i] в исходном запросе
+	positions := make([]int, 0, len(batchReq))
+	seen := make(map[string]struct{}, len(batchReq))
+
+	for i, item := range batchReq {
+		response[i].CorrID = item.CorrID
+		if err := ValidateURL(item.URL); err != nil {
+			response[i].Error = err.Error()
+			continue
+		}
+		if _, ok := seen[item.URL]; ok {
+			response[i].Error = models.ErrDuplicateURL.Error()
+			continue
+		}
+		expires, err := ParseExpiry(item.ExpiresAt, item.TTL)
+		if err != nil {
+			response[i].Error = err.Error()
+			continue
+		}
+		seen[item.URL] = struct{}{}
+		item.Expires = expires
+		valid = append(valid, item)
+		positions = append(positions, i)
+	}
+	if len(valid) == 0 {
+		return response, nil
+	}
+
+	stored, err := s.shortenValid(ctx, valid, cookie)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	events := make([]models.LinkEvent, 0, len(stored))
+	for i, item := range stored {
+		response[positions[i]] = item
+		if item.Error != "" {
+			continue
+		}
+		events = append(events, models.LinkEvent{
+			Type:     models.EventCreated,
+			ShortURL: item.ShortURL,
+			LongURL:  valid[i].URL,
+			User:     cookie,
+			Time:     now,
+		})
+	}
+	s.events.Publish(events...)
+	return response, nil
+}
+
+// shortenValid генерирует токены для пакета и сохраняет его в хранилище.
+// Если какой-то из токенов уже занят, хранилище не сохраняет ничего,
+// и токены генерируются для всего пакета заново
+func (s Service) shortenValid(ctx context.Context, batchReq []models.BatchReq,
+	cookie string) ([]models.BatchResp, error) {
+	for attempt := 0; ; attempt++ {
+		var length int
+		for i := range batchReq {
+			token, l, err := s.newToken(batchReq[i].URL, attempt)
+			if err != nil {
+				return nil, err
+			}
+			batchReq[i].Token, length = token, l
+		}
+		stored, err := s.storage.ShortenBatch(ctx, batchReq, cookie)
+		if !errors.Is(err, models.ErrShortURLAlreadyExist) {
+			return stored, err
+		}
+		if !s.tokenCollision(attempt, length) {
+			return nil, err
+		}
+	}
+}
+
+// ValidateURL проверяет, что URL абсолютный, с хостом и схемой http или https
+func ValidateURL(longURL string) error {
+	u, err := urlNet.ParseRequestURI(longURL)
+	if err != nil || u.Host == "" {
+		return models.ErrInvalidURL
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return models.ErrInvalidURL
+	}
+	return nil
+}
+
+// SweepExpired с периодом interval удаляет из хранилища ссылки
+// с истекшим сроком действия и ссылки, удаленные раньше срока хранения
+// DeletedRetention, пока не будет отменен контекст
+func (s Service) SweepExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			count, err := s.storage.DeleteExpired(ctx, now)
+			if err != nil {
+				s.log.Error(err.Error())
+			} else if count > 0 {
+				s.log.WithFields(logrus.Fields{"count": count}).Info("Помечены удаленными ссылки с истекшим сроком действия")
+			}
+
+			if s.Config.DeletedRetention <= 0 {
+				continue
+			}
+			count, err = s.storage.PurgeDeleted(ctx, now.Add(-s.Config.DeletedRetention))
+			if err != nil {
+				s.log.Error(err.Error())
+			} else if count > 0 {
+				s.log.WithFields(logrus.Fields{"count": count}).Info("Окончательно удалены удаленные ссылки")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 // Ping проверяет соединение с БД
@@ -159,29 +755,67 @@ func (s Service) GetStorageLen() int {
 	return s.storage.GetStorageLen()
 }
 
-// Close - закрывает каналы
+// Close - останавливает фоновые задачи и закрывает каналы
 func (s Service) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.events.Close()
 	close(s.OutCh)
 	close(s.userCh)
+	if err := s.analytics.Close(); err != nil {
+		s.log.Error(err.Error())
+	}
 	return s.storage.Close()
 }
 
-// GetStats проверяет что ip адрес входит в доверенную подсеть
-func (s Service) CheckIPMask(ctx context.Context, ip net.IP) (models.Stats, error) {
+// CheckTrustedIP проверяет, что ip адрес входит в доверенную подсеть
+func (s Service) CheckTrustedIP(ip net.IP) error {
 	if s.Config.Subnet == "" {
-		return models.Stats{}, models.ErrEmptySubnet
+		return models.ErrEmptySubnet
 	}
 
 	_, trustedSubnet, err := net.ParseCIDR(s.Config.Subnet)
 	if err != nil {
 		s.log.Error(err.Error())
-		return models.Stats{}, err
+		return err
 	}
 
 	if !trustedSubnet.Contains(ip) {
-		return models.Stats{}, models.ErrNotTrustedSubnet
+		return models.ErrNotTrustedSubnet
+	}
+	return nil
+}
+
+// CheckIPMask проверяет что ip адрес входит в доверенную подсеть
+// и возвращает статистику сервиса
+func (s Service) CheckIPMask(ctx context.Context, ip net.IP) (models.Stats, error) {
+	if err := s.CheckTrustedIP(ip); err != nil {
+		return models.Stats{}, err
 	}
 
 	// если все ок с подсетью идем в хранилище
-	return s.storage.GetStats(ctx), nil
+	stats, err := s.storage.GetStats(ctx, topUsers)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	stats.Redirects, err = s.analytics.TotalClicks(ctx)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	// у куки прежнего формата идентификатор пользователя совпадает
+	// со значением куки, поэтому в статистике вместо него отдается хэш
+	for i := range stats.TopUsers {
+		stats.TopUsers[i].User = hashUser(stats.TopUsers[i].User)
+	}
+	return stats, nil
+}
+
+// topUsers - число пользователей с наибольшим числом ссылок в статистике
+const topUsers = 10
+
+// hashUser возвращает короткий хэш идентификатора пользователя
+func hashUser(user string) string {
+	sum := sha256.Sum256([]byte(user))
+	return hex.EncodeToString(sum[:8])
 }
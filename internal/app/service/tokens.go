@@ -0,0 +1,198 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"strconv"
+	"sync"
+
+	"example.com/shortener/internal/config"
+)
+
+// Стратегии генерации сокращенных токенов
+const (
+	// TokenRandom - случайный токен из криптостойкого генератора
+	TokenRandom = "random"
+	// TokenSequential - номер ссылки по счетчику, перемешанный и записанный
+	// в алфавите, переставленном по соли (по аналогии с Hashids)
+	TokenSequential = "sequential"
+	// TokenHash - токен из хэша исходного URL, для одного URL всегда одинаковый
+	TokenHash = "hash"
+)
+
+// Длина токена
+const (
+	DefaultTokenLength = 10
+	MaxTokenLength     = 32
+)
+
+// base62 - алфавит токенов
+const base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// errKeyspaceExhausted - все токены заданной длины уже выданы
+var errKeyspaceExhausted = errors.New("token keyspace exhausted")
+
+// TokenGenerator - стратегия генерации сокращенных токенов
+type TokenGenerator interface {
+	// Generate возвращает токен из length символов для longURL.
+	// attempt - номер попытки: если токен уже занят, генерация
+	// повторяется с увеличенным attempt
+	Generate(longURL string, length int, attempt int) (string, error)
+}
+
+// ReserveFunc резервирует n номеров счетчика и возвращает последний номер
+// перед резервом: генератору принадлежат номера с from+1 по from+n
+type ReserveFunc func(n uint64) (from uint64, err error)
+
+// NewTokenGenerator возвращает генератор токенов по стратегии из конфигурации.
+// reserve выдает номера счетчика в стратегии TokenSequential
+func NewTokenGenerator(cfg config.Config, reserve ReserveFunc) (TokenGenerator, error) {
+	switch cfg.TokenStrategy {
+	case "", TokenRandom:
+		return RandomGenerator{}, nil
+	case TokenSequential:
+		return NewSequentialGenerator(cfg.TokenSalt, reserve), nil
+	case TokenHash:
+		return HashGenerator{salt: cfg.TokenSalt}, nil
+	}
+	return nil, fmt.Errorf("unknown token strategy: %s", cfg.TokenStrategy)
+}
+
+// RandomGenerator генерирует случайные токены в алфавите base62
+type RandomGenerator struct{}
+
+// Generate возвращает случайный токен, longURL и attempt не используются
+func (RandomGenerator) Generate(longURL string, length int, attempt int) (string, error) {
+	token := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(token) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// отбрасываем байты, которые дали бы неравномерное распределение символов
+			if int(b) >= 256/len(base62)*len(base62) {
+				continue
+			}
+			token = append(token, base62[int(b)%len(base62)])
+			if len(token) == length {
+				break
+			}
+		}
+	}
+	return string(token), nil
+}
+
+// SequentialGenerator выдает токены по возрастающему счетчику.
+// Чтобы соседние номера не давали похожих токенов, номер умножается
+// на простое число по модулю 62^length, а результат записывается
+// в алфавите, переставленном по соли. Для каждой длины это взаимно
+// однозначное отображение, поэтому токены одной длины не повторяются.
+// Номера резервируются в хранилище блоками по sequenceBlock
+type SequentialGenerator struct {
+	counter  *sequenceCounter
+	alphabet string
+}
+
+// sequenceCounter выдает номера из зарезервированного блока
+type sequenceCounter struct {
+	mu      sync.Mutex
+	next    uint64
+	limit   uint64
+	reserve ReserveFunc
+}
+
+// sequentialMultiplier - простое число, взаимно простое с 62
+var sequentialMultiplier = big.NewInt(1_580_030_173)
+
+// sequenceBlock - число номеров, которые резервируются за одно обращение
+// к хранилищу. Неиспользованный остаток блока теряется при перезапуске
+const sequenceBlock = 100
+
+// NewSequentialGenerator - конструктор для SequentialGenerator,
+// номера счетчика резервируются через reserve
+func NewSequentialGenerator(salt string, reserve ReserveFunc) SequentialGenerator {
+	return SequentialGenerator{
+		counter:  &sequenceCounter{reserve: reserve},
+		alphabet: shuffleAlphabet(base62, salt),
+	}
+}
+
+// Generate возвращает токен для следующего номера счетчика, longURL не используется
+func (g SequentialGenerator) Generate(longURL string, length int, attempt int) (string, error) {
+	next, err := g.counter.Next()
+	if err != nil {
+		return "", err
+	}
+	n := new(big.Int).SetUint64(next)
+
+	keyspace := new(big.Int).Exp(big.NewInt(int64(len(base62))), big.NewInt(int64(length)), nil)
+	if n.Cmp(keyspace) >= 0 {
+		return "", errKeyspaceExhausted
+	}
+	n.Mul(n, sequentialMultiplier).Mod(n, keyspace)
+	return encodeBase62(n, g.alphabet, length), nil
+}
+
+// Next возвращает следующий номер, при необходимости резервируя новый блок
+func (c *sequenceCounter) Next() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next >= c.limit {
+		from, err := c.reserve(sequenceBlock)
+		if err != nil {
+			return 0, err
+		}
+		c.next, c.limit = from, from+sequenceBlock
+	}
+	c.next++
+	return c.next, nil
+}
+
+// HashGenerator строит токен по хэшу исходного URL
+type HashGenerator struct {
+	salt string
+}
+
+// Generate возвращает первые length символов хэша longURL в base62.
+// При повторных попытках к URL добавляется номер попытки
+func (g HashGenerator) Generate(longURL string, length int, attempt int) (string, error) {
+	data := g.salt + longURL
+	if attempt > 0 {
+		data += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(data))
+	return encodeBase62(new(big.Int).SetBytes(sum[:]), base62, length), nil
+}
+
+// encodeBase62 записывает младшие length разрядов n в алфавите alphabet
+func encodeBase62(n *big.Int, alphabet string, length int) string {
+	token := make([]byte, length)
+	base := big.NewInt(int64(len(alphabet)))
+	digit := new(big.Int)
+	n = new(big.Int).Set(n)
+	for i := length - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		token[i] = alphabet[digit.Int64()]
+	}
+	return string(token)
+}
+
+// shuffleAlphabet переставляет символы алфавита, порядок зависит только от соли
+func shuffleAlphabet(alphabet string, salt string) string {
+	sum := sha256.Sum256([]byte(salt))
+	var seed int64
+	for _, b := range sum[:8] {
+		seed = seed<<8 | int64(b)
+	}
+	shuffled := []byte(alphabet)
+	mrand.New(mrand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return string(shuffled)
+}
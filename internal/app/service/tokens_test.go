@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/storage/memory"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenGenerators(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator TokenGenerator
+	}{
+		{name: "random", generator: RandomGenerator{}},
+		{name: "sequential", generator: NewSequentialGenerator("salt", counterFrom(0))},
+		{name: "hash", generator: HashGenerator{salt: "salt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]struct{})
+			for i := 0; i < 1000; i++ {
+				token, err := tt.generator.Generate("https://example.com/"+strconv.Itoa(i), 8, 0)
+				require.NoError(t, err)
+				require.Len(t, token, 8)
+				for _, c := range token {
+					require.True(t, strings.ContainsRune(base62, c), token)
+				}
+				_, ok := seen[token]
+				require.False(t, ok, "повтор токена %s", token)
+				seen[token] = struct{}{}
+			}
+		})
+	}
+
+	// токен по хэшу зависит только от URL, соли и номера попытки
+	hash := HashGenerator{salt: "salt"}
+	first, _ := hash.Generate("https://example.com", 8, 0)
+	second, _ := hash.Generate("https://example.com", 8, 0)
+	retry, _ := hash.Generate("https://example.com", 8, 1)
+	salted, _ := HashGenerator{salt: "other"}.Generate("https://example.com", 8, 0)
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, retry)
+	assert.NotEqual(t, first, salted)
+
+	// соседние номера счетчика не дают похожих токенов
+	seq := NewSequentialGenerator("salt", counterFrom(0))
+	a, _ := seq.Generate("", 8, 0)
+	b, _ := seq.Generate("", 8, 0)
+	assert.NotEqual(t, a[:6], b[:6])
+
+	// счетчик не выходит за пространство токенов заданной длины
+	seq = NewSequentialGenerator("salt", counterFrom(61))
+	_, err := seq.Generate("", 1, 0)
+	assert.ErrorIs(t, err, errKeyspaceExhausted)
+}
+
+// counterFrom возвращает ReserveFunc, которая выдает номера подряд после start
+func counterFrom(start uint64) ReserveFunc {
+	return func(n uint64) (uint64, error) {
+		from := start
+		start += n
+		return from, nil
+	}
+}
+
+func TestNewTokenGenerator(t *testing.T) {
+	for _, strategy := range []string{"", TokenRandom, TokenSequential, TokenHash} {
+		_, err := NewTokenGenerator(config.Config{TokenStrategy: strategy}, counterFrom(0))
+		assert.NoError(t, err, strategy)
+	}
+	_, err := NewTokenGenerator(config.Config{TokenStrategy: "uuid"}, counterFrom(0))
+	assert.Error(t, err)
+}
+
+func TestTokenLengthGrowth(t *testing.T) {
+	for _, strategy := range []string{TokenRandom, TokenSequential, TokenHash} {
+		t.Run(strategy, func(t *testing.T) {
+			log := logger.InitLog()
+			ctx := context.Background()
+			// токенов из одного символа хватает только на 62 ссылки
+			cfg := config.Config{BaseURL: "http://localhost:8080/", TokenStrategy: strategy, TokenLength: 1}
+			s := New(cfg, memory.New(cfg, log), log)
+			defer s.Close()
+
+			for i := 0; i < 200; i++ {
+				_, err := s.AddLink(ctx, "", "https://example.com/"+strconv.Itoa(i), "user", time.Time{})
+				require.NoError(t, err)
+			}
+			assert.Equal(t, 200, s.GetStorageLen())
+			assert.Greater(t, *s.tokenLength, int32(1))
+
+			// пакет тоже сохраняется после совпадений и роста длины
+			batch := make([]models.BatchReq, 0, 100)
+			for i := 0; i < 100; i++ {
+				batch = append(batch, models.BatchReq{
+					CorrID: strconv.Itoa(i),
+					URL:    "https://example.com/batch/" + strconv.Itoa(i),
+				})
+			}
+			resp, err := s.ShortenBatch(ctx, batch, "user")
+			require.NoError(t, err)
+			for _, item := range resp {
+				assert.Empty(t, item.Error)
+			}
+			assert.Equal(t, 300, s.GetStorageLen())
+		})
+	}
+}
+
+func TestSequentialAfterPurge(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{
+		BaseURL:       "http://localhost:8080/",
+		File:          filepath.Join(t.TempDir(), "links.log"),
+		TokenStrategy: TokenSequential,
+	}
+
+	issued := make(map[string]struct{})
+	s := New(cfg, memory.New(cfg, log), log)
+	var deleted []models.TokenUser
+	for i := 0; i < 5; i++ {
+		token, err := s.AddLink(ctx, "", "https://example.com/"+strconv.Itoa(i), "user", time.Time{})
+		require.NoError(t, err)
+		issued[token] = struct{}{}
+		if i < 3 {
+			deleted = append(deleted, models.TokenUser{Token: token, User: "user"})
+		}
+	}
+	// ссылок становится меньше, чем выдано номеров
+	_, err := s.storage.BatchDelete(ctx, deleted)
+	require.NoError(t, err)
+	purged, err := s.storage.PurgeDeleted(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 3, purged)
+	require.NoError(t, s.Close())
+
+	// после перезапуска номера продолжаются с сохраненной границы
+	s = New(cfg, memory.New(cfg, log), log)
+	defer s.Close()
+	for i := 5; i < 10; i++ {
+		token, err := s.AddLink(ctx, "", "https://example.com/"+strconv.Itoa(i), "user", time.Time{})
+		require.NoError(t, err)
+		_, ok := issued[token]
+		require.False(t, ok, "повтор токена %s", token)
+		issued[token] = struct{}{}
+	}
+	assert.Equal(t, 7, s.GetStorageLen())
+}
+
+func TestHashSameURL(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{BaseURL: "http://localhost:8080/", TokenStrategy: TokenHash}
+	s := New(cfg, memory.New(cfg, log), log)
+	defer s.Close()
+
+	// повторное сокращение URL возвращает тот же токен, новая ссылка не создается
+	first, err := s.AddLink(ctx, "", "https://example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	second, err := s.AddLink(ctx, "", "https://example.com", "user2", time.Time{})
+	assert.ErrorIs(t, err, models.ErrorAlreadyExist)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, s.GetStorageLen())
+}
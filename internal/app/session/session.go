@@ -0,0 +1,177 @@
+// Модуль session выдает и проверяет подписанные куки User и jwt токены gRPC,
+// по которым определяется пользователь, и хранит идентификатор пользователя
+// в контексте запроса
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/shortener/internal/app/keyring"
+	"example.com/shortener/internal/config"
+)
+
+// CookieName - имя куки сессии
+const CookieName = "User"
+
+// DefaultTTL - срок действия куки, если он не задан в конфигурации
+const DefaultTTL = 365 * 24 * time.Hour
+
+// userIDBytes - число случайных байт в идентификаторе нового пользователя
+const userIDBytes = 16
+
+// Ошибки проверки куки
+var (
+	ErrInvalidCookie = errors.New("invalid session cookie")
+	ErrExpired       = errors.New("session has expired")
+)
+
+// Manager выдает и проверяет куки сессии.
+//
+// Кука состоит из четырех частей через точку: идентификатор ключа подписи,
+// идентификатор пользователя в base64, время окончания действия (unix)
+// и подпись HMAC-SHA256 первых трех частей. Куки прежних форматов
+// (подпись и случайный токен в base64, с идентификатором ключа через точку
+// или без него) принимаются, а идентификатором пользователя для них,
+// как и раньше, служит все значение куки
+type Manager struct {
+	keys   *keyring.Keyring
+	ttl    time.Duration
+	secure bool
+	now    func() time.Time
+}
+
+// New - конструктор для Manager. Secure выставляется, если сервер работает
+// по HTTPS или в конфигурации задан CookieSecure (HTTPS на прокси)
+func New(keys *keyring.Keyring, cfg config.Config) *Manager {
+	ttl := cfg.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Manager{
+		keys:   keys,
+		ttl:    ttl,
+		secure: cfg.HTTPS || cfg.CookieSecure,
+		now:    time.Now,
+	}
+}
+
+// NewUser возвращает идентификатор нового пользователя
+func NewUser() (string, error) {
+	b := make([]byte, userIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Issue выдает куку сессии пользователя user на срок ttl
+func (m *Manager) Issue(user string) *http.Cookie {
+	expires := m.now().Add(m.ttl).Truncate(time.Second)
+	kid, secret := m.keys.Current()
+	payload := kid + "." + base64.RawURLEncoding.EncodeToString([]byte(user)) +
+		"." + strconv.FormatInt(expires.Unix(), 10)
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    payload + "." + base64.RawURLEncoding.EncodeToString(sign(secret, payload)),
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(m.ttl / time.Second),
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Verify проверяет подпись и срок действия куки и возвращает пользователя.
+// renew сообщает, что куку нужно выдать заново: она прежнего формата
+// или прошла половина срока ее действия
+func (m *Manager) Verify(value string) (user string, renew bool, err error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 4 {
+		if err := m.verifyLegacy(value); err != nil {
+			return "", false, err
+		}
+		return value, true, nil
+	}
+
+	secret, err := m.keys.Key(parts[0])
+	if err != nil {
+		return "", false, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", false, ErrInvalidCookie
+	}
+	payload := value[:len(value)-len(parts[3])-1]
+	if !hmac.Equal(signature, sign(secret, payload)) {
+		return "", false, ErrInvalidCookie
+	}
+
+	userID, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(userID) == 0 {
+		return "", false, ErrInvalidCookie
+	}
+	expiresUnix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", false, ErrInvalidCookie
+	}
+	left := time.Unix(expiresUnix, 0).Sub(m.now())
+	if left <= 0 {
+		return "", false, ErrExpired
+	}
+	return string(userID), left < m.ttl/2, nil
+}
+
+// verifyLegacy проверяет куку прежнего формата: base64 от подписи
+// HMAC-SHA256 и случайного токена, перед которым может стоять
+// идентификатор ключа через точку
+func (m *Manager) verifyLegacy(value string) error {
+	kid, encoded, ok := strings.Cut(value, ".")
+	if !ok {
+		kid, encoded = "", value
+	}
+	secret, err := m.keys.Key(kid)
+	if err != nil {
+		return err
+	}
+	signedValue, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil || len(signedValue) <= sha256.Size {
+		return ErrInvalidCookie
+	}
+	if !hmac.Equal(signedValue[:sha256.Size], sign(secret, string(signedValue[sha256.Size:]))) {
+		return ErrInvalidCookie
+	}
+	return nil
+}
+
+// sign возвращает подпись HMAC-SHA256 строки payload
+func sign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// userKey - ключ контекста для идентификатора пользователя
+type userKey struct{}
+
+// WithUser возвращает контекст с идентификатором пользователя
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext возвращает идентификатор пользователя из контекста
+// или пустую строку, если пользователь не определен
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
@@ -0,0 +1,188 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/keyring"
+	"example.com/shortener/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newManager возвращает Manager с ключами list и управляемым временем
+func newManager(t *testing.T, list string, now *time.Time) *Manager {
+	t.Helper()
+	keys, err := keyring.Parse(list)
+	require.NoError(t, err)
+	m := New(keys, config.Config{SessionTTL: time.Hour})
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+// legacyCookie формирует куку прежнего формата: base64 от подписи и токена,
+// перед которым может стоять идентификатор ключа
+func legacyCookie(kid string, secret string, token string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(token))
+	value := base64.URLEncoding.EncodeToString(append(mac.Sum(nil), token...))
+	if kid != "" {
+		value = kid + "." + value
+	}
+	return value
+}
+
+func TestIssueVerify(t *testing.T) {
+	now := time.Now()
+	m := newManager(t, "k1:secret1", &now)
+
+	cookie := m.Issue("user1")
+	assert.Equal(t, CookieName, cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.False(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	assert.Equal(t, "/", cookie.Path)
+	assert.Equal(t, 3600, cookie.MaxAge)
+
+	user, renew, err := m.Verify(cookie.Value)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", user)
+	assert.False(t, renew)
+
+	// после половины срока куку нужно продлить, после окончания она не действует
+	now = now.Add(40 * time.Minute)
+	user, renew, err = m.Verify(cookie.Value)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", user)
+	assert.True(t, renew)
+	now = now.Add(30 * time.Minute)
+	_, _, err = m.Verify(cookie.Value)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	keys, err := keyring.Parse("")
+	require.NoError(t, err)
+	assert.True(t, New(keys, config.Config{HTTPS: true}).Issue("user1").Secure)
+	assert.True(t, New(keys, config.Config{CookieSecure: true}).Issue("user1").Secure)
+}
+
+func TestTampering(t *testing.T) {
+	now := time.Now()
+	m := newManager(t, "k1:secret1,k2:secret2", &now)
+	value := m.Issue("user1").Value
+	parts := strings.Split(value, ".")
+	other := strings.Split(m.Issue("user2").Value, ".")
+	flipped := "A"
+	if parts[3][0] == 'A' {
+		flipped = "B"
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "other user", value: strings.Join([]string{parts[0], other[1], parts[2], parts[3]}, ".")},
+		{name: "extended expiry", value: strings.Join([]string{parts[0], parts[1], "99999999999", parts[3]}, ".")},
+		{name: "other key id", value: strings.Join([]string{"k2", parts[1], parts[2], parts[3]}, ".")},
+		{name: "unknown key id", value: strings.Join([]string{"k3", parts[1], parts[2], parts[3]}, ".")},
+		{name: "flipped signature", value: strings.Join([]string{parts[0], parts[1], parts[2], flipped + parts[3][1:]}, ".")},
+		{name: "no signature", value: strings.Join([]string{parts[0], parts[1], parts[2], ""}, ".")},
+		{name: "bad base64", value: strings.Join([]string{parts[0], "!!", parts[2], parts[3]}, ".")},
+		{name: "empty", value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := m.Verify(tt.value)
+			assert.Error(t, err)
+		})
+	}
+
+	// ни одна обрезанная кука не проходит проверку и не вызывает панику
+	for i := 0; i < len(value); i++ {
+		_, _, err := m.Verify(value[:i])
+		assert.Error(t, err, value[:i])
+	}
+	legacy := legacyCookie("", "secret key", "token")
+	m = newManager(t, "", &now)
+	for i := 0; i < len(legacy); i++ {
+		_, _, err := m.Verify(legacy[:i])
+		assert.Error(t, err, legacy[:i])
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Now()
+	old := newManager(t, "old:secret1", &now)
+	rotated := newManager(t, "new:secret2,old:secret1", &now)
+
+	// после смены ключа старая кука проверяется, а новые подписываются новым ключом
+	cookie := old.Issue("user1")
+	user, _, err := rotated.Verify(cookie.Value)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", user)
+	fresh := rotated.Issue("user1")
+	assert.True(t, strings.HasPrefix(fresh.Value, "new."))
+
+	// кука с ключом, которого уже нет в наборе, не проверяется
+	_, _, err = old.Verify(fresh.Value)
+	assert.ErrorIs(t, err, keyring.ErrUnknownKey)
+}
+
+func TestLegacyCookie(t *testing.T) {
+	now := time.Now()
+	m := newManager(t, "new:secret2,old:secret1", &now)
+
+	// для куки прежнего формата пользователем остается все значение куки,
+	// и ее нужно выдать заново в новом формате
+	for _, value := range []string{legacyCookie("old", "secret1", "token"), legacyCookie("", "secret key", "token")} {
+		if !strings.HasPrefix(value, "old.") {
+			m = newManager(t, "new:secret2,default:secret key", &now)
+		}
+		user, renew, err := m.Verify(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, value, user)
+		assert.True(t, renew)
+
+		user, _, err = m.Verify(m.Issue(user).Value)
+		require.NoError(t, err)
+		assert.Equal(t, value, user)
+	}
+
+	_, _, err := m.Verify(legacyCookie("", "forged", "token"))
+	assert.ErrorIs(t, err, ErrInvalidCookie)
+	_, _, err = newManager(t, "new:secret2", &now).Verify(legacyCookie("", "secret key", "token"))
+	assert.ErrorIs(t, err, keyring.ErrUnknownKey)
+}
+
+func TestTokens(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	keys, err := keyring.Parse("new:secret2,old:secret1")
+	require.NoError(t, err)
+	tokens := NewTokens(keys, config.Config{SessionTTL: time.Hour})
+	tokens.now = func() time.Time { return now }
+
+	token, err := tokens.Issue("user")
+	require.NoError(t, err)
+	user, renew, err := tokens.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user", user)
+	assert.False(t, renew)
+
+	// после половины срока токен нужно перевыпустить
+	now = now.Add(40 * time.Minute)
+	user, renew, err = tokens.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user", user)
+	assert.True(t, renew)
+
+	now = now.Add(time.Hour)
+	_, _, err = tokens.Verify(token)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	_, _, err = tokens.Verify(token[:len(token)-2])
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,81 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/shortener/internal/app/keyring"
+	"example.com/shortener/internal/config"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrInvalidToken - jwt токен не прошел проверку
+var ErrInvalidToken = errors.New("invalid token")
+
+// clockSkew - насколько время выдачи токена может опережать часы сервера
+const clockSkew = time.Minute
+
+// Tokens выдает и проверяет jwt токены пользователей gRPC. Пользователи
+// те же, что и у куки сессии: идентификатор записывается в subject,
+// срок действия тот же, что у куки
+type Tokens struct {
+	keys *keyring.Keyring
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewTokens - конструктор для Tokens
+func NewTokens(keys *keyring.Keyring, cfg config.Config) *Tokens {
+	ttl := cfg.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Tokens{keys: keys, ttl: ttl, now: time.Now}
+}
+
+// Issue выдает токен пользователя user, подписанный текущим ключом.
+// Идентификатор ключа записывается в заголовок kid
+func (t *Tokens) Issue(user string) (string, error) {
+	now := t.now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   user,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.ttl).Unix(),
+	})
+	kid, secret := t.keys.Current()
+	token.Header["kid"] = kid
+	return token.SignedString(secret)
+}
+
+// Verify проверяет подпись и поля sub, iat и exp токена и возвращает
+// пользователя. renew сообщает, что прошла половина срока действия токена
+// и клиенту нужно выдать новый
+func (t *Tokens) Verify(tokenString string) (user string, renew bool, err error) {
+	var claims jwt.StandardClaims
+	// время проверяем сами по t.now, а не по jwt.TimeFunc
+	parser := jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}, SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(tokenString, &claims, t.keyFunc); err != nil {
+		return "", false, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" || claims.IssuedAt == 0 || claims.ExpiresAt == 0 {
+		return "", false, fmt.Errorf("%w: missing claims", ErrInvalidToken)
+	}
+
+	now := t.now()
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return "", false, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	left := time.Unix(claims.ExpiresAt, 0).Sub(now)
+	if left <= 0 {
+		return "", false, ErrExpired
+	}
+	return claims.Subject, left < t.ttl/2, nil
+}
+
+// keyFunc выбирает ключ для проверки токена по заголовку kid.
+// Токены без kid проверяются ключом keyring.DefaultKeyID
+func (t *Tokens) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	return t.keys.Key(kid)
+}
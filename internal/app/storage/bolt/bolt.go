@@ -0,0 +1,829 @@
+// модуль bolt реализует хранение данных во встроенной базе bbolt (B+ дерево
+// в одном файле). Кроме самих ссылок в отдельных бакетах хранятся индексы:
+// URL -> токен, ссылки пользователя, метки удаления, сроки действия
+// и счетчики для статистики, поэтому запросы не обходят все ссылки
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/config"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/bbolt"
+)
+
+// Бакеты базы
+var (
+	// links: токен -> link
+	bucketLinks = []byte("links")
+	// urls: исходный URL -> токен
+	bucketURLs = []byte("urls")
+	// user_urls: пользователь \x00 токен -> пусто, ключи пользователя
+	// лежат подряд в порядке токенов
+	bucketUserURLs = []byte("user_urls")
+	// user_counts: пользователь -> число ссылок
+	bucketUserCounts = []byte("user_counts")
+	// deleted: токен -> время удаления (UnixNano), удаленные ссылки
+	bucketDeleted = []byte("deleted")
+	// expires: срок действия (UnixNano) токен -> пусто, в порядке сроков
+	bucketExpires = []byte("expires")
+	// created: номер часа -> число созданных в этот час ссылок
+	bucketCreated = []byte("created")
+	// stats: название счетчика -> значение
+	bucketStats = []byte("stats")
+	// revisions: токен \x00 номер ревизии -> revision, прежние URL ссылки
+	bucketRevisions = []byte("revisions")
+	// api_keys: хэш ключа -> apiKey, api_key_ids: идентификатор -> хэш
+	bucketAPIKeys   = []byte("api_keys")
+	bucketAPIKeyIDs = []byte("api_key_ids")
+)
+
+// Счетчики в бакете stats
+var (
+	counterLinks   = []byte("links")
+	counterUsers   = []byte("users")
+	counterDeleted = []byte("deleted")
+	// counterSequence - последний зарезервированный номер последовательных токенов
+	counterSequence = []byte("sequence")
+)
+
+// userSep отделяет пользователя от токена в ключах user_urls
+// и токен от номера ревизии в ключах revisions
+const userSep = 0
+
+// openTimeout - сколько ждать, пока файл базы освободит другой процесс
+const openTimeout = time.Second
+
+// statsWeek - самый длинный период, за который считается число созданных ссылок
+const statsWeek = 7 * 24 * time.Hour
+
+// link - ссылка в бакете links
+type link struct {
+	LongURL   string     `json:"long_url"`
+	User      string     `json:"user"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// revision - прежний исходный URL в бакете revisions
+type revision struct {
+	LongURL    string    `json:"long_url"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// apiKey - API ключ в бакете api_keys. В models.APIKey хэш не сериализуется
+type apiKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	User      string    `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BoltStorage реализует методы для взаимодействия с хранилищем bbolt
+type BoltStorage struct {
+	db  *bbolt.DB
+	log *logrus.Logger
+}
+
+// New - конструктор для BoltStorage. Открывает или создает файл базы
+// config.BoltFile и недостающие бакеты
+func New(config config.Config, log *logrus.Logger) (*BoltStorage, error) {
+	db, err := bbolt.Open(config.BoltFile, 0600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{
+			bucketLinks, bucketURLs, bucketUserURLs, bucketUserCounts, bucketDeleted,
+			bucketExpires, bucketCreated, bucketStats, bucketRevisions, bucketAPIKeys, bucketAPIKeyIDs,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStorage{db: db, log: log}, nil
+}
+
+// AddLink записывает ссылку. Если исходный URL уже сокращен, возвращает
+// его токен и models.ErrorAlreadyExist
+func (s *BoltStorage) AddLink(ctx context.Context, sToken string, longURL string, user string,
+	expiresAt time.Time) (string, error) {
+	var existing string
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(bucketLinks).Get([]byte(sToken)) != nil {
+			return models.ErrShortURLAlreadyExist
+		}
+		if short := tx.Bucket(bucketURLs).Get([]byte(longURL)); short != nil {
+			existing = string(short)
+			return models.ErrorAlreadyExist
+		}
+		return putLink(tx, sToken, newLink(longURL, user, expiresAt))
+	})
+	if err != nil {
+		return existing, err
+	}
+	return sToken, nil
+}
+
+// GetLongURL возвращает исходный URL по токену
+func (s *BoltStorage) GetLongURL(ctx context.Context, sToken string) (string, error) {
+	longURL, _, err := s.ResolveURL(ctx, sToken)
+	return longURL, err
+}
+
+// ResolveURL возвращает исходный URL и срок действия ссылки
+func (s *BoltStorage) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	var longURL string
+	var expiresAt time.Time
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		l, err := getLink(tx, []byte(sToken))
+		if err != nil {
+			return err
+		}
+		if tx.Bucket(bucketDeleted).Get([]byte(sToken)) != nil {
+			return models.ErrLinkDeleted
+		}
+		if l.ExpiresAt != nil && !l.ExpiresAt.After(time.Now()) {
+			return models.ErrLinkExpired
+		}
+		longURL = l.LongURL
+		if l.ExpiresAt != nil {
+			expiresAt = *l.ExpiresAt
+		}
+		return nil
+	})
+	return longURL, expiresAt, err
+}
+
+// Ping возвращает ошибку, если база закрыта
+func (s *BoltStorage) Ping(ctx context.Context) error {
+	return s.db.View(func(tx *bbolt.Tx) error { return nil })
+}
+
+// Backend возвращает название хранилища
+func (s *BoltStorage) Backend() string {
+	return models.BackendBolt
+}
+
+// GetAllURLS возвращает все ссылки пользователя по индексу user_urls
+func (s *BoltStorage) GetAllURLS(ctx context.Context, cookie string) (map[string]string, error) {
+	userLinks := make(map[string]string)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return forUserLinks(tx, cookie, "", func(short []byte, l link) bool {
+			userLinks[string(short)] = l.LongURL
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userLinks, nil
+}
+
+// GetUserURLsPage возвращает не больше limit ссылок пользователя
+// с сокращенным URL больше after в порядке возрастания
+func (s *BoltStorage) GetUserURLsPage(ctx context.Context, cookie string, after string,
+	limit int) ([]models.LinksData, error) {
+	links := make([]models.LinksData, 0, limit)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return forUserLinks(tx, cookie, after, func(short []byte, l link) bool {
+			if len(links) >= limit {
+				return false
+			}
+			links = append(links, models.LinksData{ShortURL: string(short), LongURL: l.LongURL})
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// ShortenBatch сохраняет пакет ссылок в одной транзакции. Для URL, которые
+// уже сокращены или повторяются в пакете, ссылка не создается, а в ответе
+// заполняется поле Error. Если занят хотя бы один из токенов, транзакция
+// откатывается и не сохраняется ничего
+func (s *BoltStorage) ShortenBatch(ctx context.Context, batchReq []models.BatchReq, cookie string) ([]models.BatchResp, error) {
+	response := make([]models.BatchResp, 0, len(batchReq))
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		seen := make(map[string]struct{}, len(batchReq))
+		for _, item := range batchReq {
+			// URL, сокращенный ранее в этом же пакете, тоже есть в индексе,
+			// поэтому повтор проверяется первым
+			if _, ok := seen[item.URL]; ok {
+				response = append(response, models.BatchResp{
+					CorrID: item.CorrID,
+					Error:  models.ErrDuplicateURL.Error(),
+				})
+				continue
+			}
+			if short := tx.Bucket(bucketURLs).Get([]byte(item.URL)); short != nil {
+				response = append(response, models.BatchResp{
+					CorrID:   item.CorrID,
+					ShortURL: string(short),
+					Error:    models.ErrorAlreadyExist.Error(),
+				})
+				continue
+			}
+			seen[item.URL] = struct{}{}
+
+			if tx.Bucket(bucketLinks).Get([]byte(item.Token)) != nil {
+				return models.ErrShortURLAlreadyExist
+			}
+			if err := putLink(tx, item.Token, newLink(item.URL, cookie, item.Expires)); err != nil {
+				return err
+			}
+			response = append(response, models.BatchResp{CorrID: item.CorrID, ShortURL: item.Token})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// BatchDelete ставит метки удаления на ссылки, принадлежащие пользователям из запроса
+func (s *BoltStorage) BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error) {
+	var results []models.DeleteResult
+	deletedAt := uint64Key(uint64(time.Now().UnixNano()))
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		results = make([]models.DeleteResult, 0, len(sTokens))
+		deleted := tx.Bucket(bucketDeleted)
+		for _, v := range sTokens {
+			result := models.DeleteResult{ShortURL: v.Token, Status: models.DeleteDeleted}
+			l, err := getLink(tx, []byte(v.Token))
+			switch {
+			case errors.Is(err, models.ErrLinkNotFound):
+				result.Status = models.DeleteNotFound
+			case err != nil:
+				return err
+			case l.User != v.User:
+				result.Status = models.DeleteNotOwner
+			case deleted.Get([]byte(v.Token)) != nil:
+				result.Status = models.DeleteAlreadyDeleted
+			default:
+				if err := deleted.Put([]byte(v.Token), deletedAt); err != nil {
+					return err
+				}
+				if err := addCounter(tx.Bucket(bucketStats), counterDeleted, 1); err != nil {
+					return err
+				}
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// UpdateURL меняет исходный URL ссылки, прежний URL сохраняется в бакете
+// revisions. Если новый URL уже сокращен другой ссылкой, возвращает ее токен
+// и models.ErrorAlreadyExist
+func (s *BoltStorage) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error) {
+	var existing string
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		short := []byte(sToken)
+		l, err := getLink(tx, short)
+		if err != nil {
+			return err
+		}
+		if l.User != user {
+			return models.ErrLinkNotFound
+		}
+		if tx.Bucket(bucketDeleted).Get(short) != nil {
+			return models.ErrLinkDeleted
+		}
+		if l.LongURL == longURL {
+			return nil
+		}
+		urls := tx.Bucket(bucketURLs)
+		if other := urls.Get([]byte(longURL)); other != nil {
+			existing = string(other)
+			return models.ErrorAlreadyExist
+		}
+
+		revisions, err := getRevisions(tx, short)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(revision{LongURL: l.LongURL, ReplacedAt: time.Now().UTC()})
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketRevisions).Put(revisionKey(short, len(revisions)+1), value); err != nil {
+			return err
+		}
+		if bytes.Equal(urls.Get([]byte(l.LongURL)), short) {
+			if err := urls.Delete([]byte(l.LongURL)); err != nil {
+				return err
+			}
+		}
+		if err := urls.Put([]byte(longURL), short); err != nil {
+			return err
+		}
+		l.LongURL = longURL
+		value, err = json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketLinks).Put(short, value)
+	})
+	if err != nil {
+		return existing, err
+	}
+	return sToken, nil
+}
+
+// GetRevisions возвращает текущий и прежние исходные URL ссылки пользователя
+func (s *BoltStorage) GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error) {
+	var revisions models.LinkRevisions
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		l, err := getLink(tx, []byte(sToken))
+		if err != nil {
+			return err
+		}
+		if l.User != user {
+			return models.ErrLinkNotFound
+		}
+		revisions.ShortURL = sToken
+		revisions.LongURL = l.LongURL
+		revisions.Revisions, err = getRevisions(tx, []byte(sToken))
+		return err
+	})
+	if err != nil {
+		return models.LinkRevisions{}, err
+	}
+	return revisions, nil
+}
+
+// RestoreURL снимает метку удаления со ссылки пользователя
+func (s *BoltStorage) RestoreURL(ctx context.Context, sToken string, user string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		short := []byte(sToken)
+		l, err := getLink(tx, short)
+		if err != nil {
+			return err
+		}
+		if l.User != user {
+			return models.ErrLinkNotFound
+		}
+		deleted := tx.Bucket(bucketDeleted)
+		if deleted.Get(short) == nil {
+			return nil
+		}
+		if err := deleted.Delete(short); err != nil {
+			return err
+		}
+		return addCounter(tx.Bucket(bucketStats), counterDeleted, -1)
+	})
+}
+
+// GetDeletedURLs возвращает удаленные ссылки пользователя по индексу user_urls
+func (s *BoltStorage) GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error) {
+	links := make([]models.DeletedLink, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		deleted := tx.Bucket(bucketDeleted)
+		return forUserLinks(tx, user, "", func(short []byte, l link) bool {
+			if value := deleted.Get(short); value != nil {
+				links = append(links, models.DeletedLink{
+					ShortURL:  string(short),
+					LongURL:   l.LongURL,
+					DeletedAt: deletedTime(value),
+				})
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// PurgeDeleted удаляет ссылки, удаленные не позже before. Обходятся
+// только удаленные ссылки
+func (s *BoltStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	var count int
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		// ключи удаляются после обхода, курсор не переживает изменений бакета
+		var shorts [][]byte
+		err := tx.Bucket(bucketDeleted).ForEach(func(k, v []byte) error {
+			if !deletedTime(v).After(before) {
+				shorts = append(shorts, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, short := range shorts {
+			if err := purgeLink(tx, short); err != nil {
+				return err
+			}
+		}
+		count = len(shorts)
+		return nil
+	})
+	return count, err
+}
+
+// Close закрывает файл базы
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+// ReserveTokens резервирует n номеров последовательных токенов.
+// Возвращает последний номер перед резервом. В базах, созданных до появления
+// счетчика, выданными считаются номера по числу ссылок
+func (s *BoltStorage) ReserveTokens(ctx context.Context, n uint64) (uint64, error) {
+	var from uint64
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketStats)
+		if b.Get(counterSequence) == nil {
+			from = counter(b, counterLinks)
+		} else {
+			from = counter(b, counterSequence)
+		}
+		return b.Put(counterSequence, uint64Key(from+n))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return from, nil
+}
+
+// GetStorageLen возвращает число ссылок
+func (s *BoltStorage) GetStorageLen() int {
+	var count int
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		count = int(counter(tx.Bucket(bucketStats), counterLinks))
+		return nil
+	})
+	if err != nil {
+		s.log.Error(err.Error())
+	}
+	return count
+}
+
+// GetStats возвращает статистику по ссылкам из поддерживаемых счетчиков.
+// Число созданных ссылок считается с точностью до часа
+func (s *BoltStorage) GetStats(ctx context.Context, top int) (models.Stats, error) {
+	var stats models.Stats
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		counters := tx.Bucket(bucketStats)
+		stats.URLs = int(counter(counters, counterLinks))
+		stats.Users = int(counter(counters, counterUsers))
+		stats.Deleted = int(counter(counters, counterDeleted))
+
+		now := time.Now()
+		dayAgo := createdBucket(now.Add(-24 * time.Hour))
+		weekAgo := createdBucket(now.Add(-statsWeek))
+		c := tx.Bucket(bucketCreated).Cursor()
+		for k, v := c.Seek(uint64Key(uint64(weekAgo + 1))); k != nil; k, v = c.Next() {
+			count := int(binary.BigEndian.Uint64(v))
+			stats.Created7d += count
+			if int64(binary.BigEndian.Uint64(k)) > dayAgo {
+				stats.Created24h += count
+			}
+		}
+
+		stats.TopUsers = make([]models.UserLinks, 0, stats.Users)
+		return tx.Bucket(bucketUserCounts).ForEach(func(k, v []byte) error {
+			stats.TopUsers = append(stats.TopUsers, models.UserLinks{
+				User:  string(k),
+				Links: int(binary.BigEndian.Uint64(v)),
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	sort.Slice(stats.TopUsers, func(i, j int) bool {
+		if stats.TopUsers[i].Links != stats.TopUsers[j].Links {
+			return stats.TopUsers[i].Links > stats.TopUsers[j].Links
+		}
+		return stats.TopUsers[i].User < stats.TopUsers[j].User
+	})
+	if len(stats.TopUsers) > top {
+		stats.TopUsers = stats.TopUsers[:top]
+	}
+	return stats, nil
+}
+
+// DeleteExpired ставит метку удаления на ссылки, срок действия которых истек
+// к моменту now. Временем удаления становится срок действия. Индекс expires
+// упорядочен по сроку, поэтому обходятся только истекшие ссылки
+func (s *BoltStorage) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	var count int
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		deleted := tx.Bucket(bucketDeleted)
+		limit := uint64Key(uint64(now.UnixNano()))
+		c := tx.Bucket(bucketExpires).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], limit) <= 0; k, _ = c.Next() {
+			short := k[8:]
+			if deleted.Get(short) != nil {
+				continue
+			}
+			if err := deleted.Put(append([]byte(nil), short...), append([]byte(nil), k[:8]...)); err != nil {
+				return err
+			}
+			count++
+		}
+		return addCounter(tx.Bucket(bucketStats), counterDeleted, int64(count))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SaveAPIKey сохраняет API ключ
+func (s *BoltStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		value, err := json.Marshal(apiKey{ID: key.ID, Name: key.Name, User: key.User, CreatedAt: key.CreatedAt})
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketAPIKeys).Put([]byte(key.Hash), value); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketAPIKeyIDs).Put([]byte(key.ID), []byte(key.Hash))
+	})
+}
+
+// GetAPIKey ищет API ключ по хэшу
+func (s *BoltStorage) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	var key apiKey
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket(bucketAPIKeys).Get([]byte(hash))
+		if value == nil {
+			return models.ErrAPIKeyNotFound
+		}
+		return json.Unmarshal(value, &key)
+	})
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return models.APIKey{ID: key.ID, Name: key.Name, User: key.User, Hash: hash, CreatedAt: key.CreatedAt}, nil
+}
+
+// RevokeAPIKey удаляет API ключ по идентификатору
+func (s *BoltStorage) RevokeAPIKey(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		ids := tx.Bucket(bucketAPIKeyIDs)
+		hash := ids.Get([]byte(id))
+		if hash == nil {
+			return models.ErrAPIKeyNotFound
+		}
+		if err := tx.Bucket(bucketAPIKeys).Delete(hash); err != nil {
+			return err
+		}
+		return ids.Delete([]byte(id))
+	})
+}
+
+// newLink возвращает новую ссылку, созданную сейчас
+func newLink(longURL string, user string, expiresAt time.Time) link {
+	l := link{LongURL: longURL, User: user, CreatedAt: time.Now().UTC()}
+	if !expiresAt.IsZero() {
+		l.ExpiresAt = &expiresAt
+	}
+	return l
+}
+
+// getLink читает ссылку из бакета links
+func getLink(tx *bbolt.Tx, short []byte) (link, error) {
+	var l link
+	value := tx.Bucket(bucketLinks).Get(short)
+	if value == nil {
+		return l, models.ErrLinkNotFound
+	}
+	err := json.Unmarshal(value, &l)
+	return l, err
+}
+
+// putLink записывает новую ссылку и обновляет индексы и счетчики
+func putLink(tx *bbolt.Tx, sToken string, l link) error {
+	short := []byte(sToken)
+	value, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketLinks).Put(short, value); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketURLs).Put([]byte(l.LongURL), short); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketUserURLs).Put(userKey(l.User, short), nil); err != nil {
+		return err
+	}
+	if l.ExpiresAt != nil {
+		if err := tx.Bucket(bucketExpires).Put(expiresKey(*l.ExpiresAt, short), nil); err != nil {
+			return err
+		}
+	}
+	if err := addCounter(tx.Bucket(bucketCreated), uint64Key(uint64(createdBucket(l.CreatedAt))), 1); err != nil {
+		return err
+	}
+	return addLinkCounters(tx, l.User, 1)
+}
+
+// purgeLink удаляет ссылку вместе с записями в индексах
+func purgeLink(tx *bbolt.Tx, short []byte) error {
+	l, err := getLink(tx, short)
+	if errors.Is(err, models.ErrLinkNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketLinks).Delete(short); err != nil {
+		return err
+	}
+	urls := tx.Bucket(bucketURLs)
+	if bytes.Equal(urls.Get([]byte(l.LongURL)), short) {
+		if err := urls.Delete([]byte(l.LongURL)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(bucketUserURLs).Delete(userKey(l.User, short)); err != nil {
+		return err
+	}
+	if l.ExpiresAt != nil {
+		if err := tx.Bucket(bucketExpires).Delete(expiresKey(*l.ExpiresAt, short)); err != nil {
+			return err
+		}
+	}
+	if err := deleteRevisions(tx, short); err != nil {
+		return err
+	}
+	deleted := tx.Bucket(bucketDeleted)
+	if deleted.Get(short) != nil {
+		if err := deleted.Delete(short); err != nil {
+			return err
+		}
+		if err := addCounter(tx.Bucket(bucketStats), counterDeleted, -1); err != nil {
+			return err
+		}
+	}
+	if err := addCounter(tx.Bucket(bucketCreated), uint64Key(uint64(createdBucket(l.CreatedAt))), -1); err != nil {
+		return err
+	}
+	return addLinkCounters(tx, l.User, -1)
+}
+
+// addLinkCounters меняет на delta число ссылок всего и у пользователя user,
+// а при появлении или исчезновении ссылок у пользователя - и число пользователей
+func addLinkCounters(tx *bbolt.Tx, user string, delta int64) error {
+	counters := tx.Bucket(bucketStats)
+	if err := addCounter(counters, counterLinks, delta); err != nil {
+		return err
+	}
+	userCounts := tx.Bucket(bucketUserCounts)
+	before := counter(userCounts, []byte(user))
+	if err := addCounter(userCounts, []byte(user), delta); err != nil {
+		return err
+	}
+	switch after := counter(userCounts, []byte(user)); {
+	case before == 0 && after > 0:
+		return addCounter(counters, counterUsers, 1)
+	case before > 0 && after == 0:
+		return addCounter(counters, counterUsers, -1)
+	}
+	return nil
+}
+
+// forUserLinks вызывает fn для ссылок пользователя с токеном больше after
+// в порядке возрастания токенов, пока fn возвращает true
+func forUserLinks(tx *bbolt.Tx, user string, after string, fn func(short []byte, l link) bool) error {
+	prefix := userKey(user, nil)
+	c := tx.Bucket(bucketUserURLs).Cursor()
+	k, _ := c.Seek(userKey(user, []byte(after)))
+	if after != "" && bytes.Equal(k, userKey(user, []byte(after))) {
+		k, _ = c.Next()
+	}
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		short := k[len(prefix):]
+		l, err := getLink(tx, short)
+		if err != nil {
+			return err
+		}
+		if !fn(short, l) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// getRevisions возвращает прежние URL ссылки в порядке номеров ревизий
+func getRevisions(tx *bbolt.Tx, short []byte) ([]models.Revision, error) {
+	revisions := make([]models.Revision, 0)
+	prefix := userKey(string(short), nil)
+	c := tx.Bucket(bucketRevisions).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var r revision
+		if err := json.Unmarshal(v, &r); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, models.Revision{
+			Revision:   int(binary.BigEndian.Uint64(k[len(prefix):])),
+			LongURL:    r.LongURL,
+			ReplacedAt: r.ReplacedAt,
+		})
+	}
+	return revisions, nil
+}
+
+// deleteRevisions удаляет прежние URL ссылки
+func deleteRevisions(tx *bbolt.Tx, short []byte) error {
+	// ключи удаляются после обхода, курсор не переживает изменений бакета
+	var keys [][]byte
+	prefix := userKey(string(short), nil)
+	b := tx.Bucket(bucketRevisions)
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// counter возвращает значение счетчика key или 0
+func counter(b *bbolt.Bucket, key []byte) uint64 {
+	value := b.Get(key)
+	if value == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(value)
+}
+
+// addCounter меняет счетчик key на delta. Обнулившийся счетчик удаляется
+func addCounter(b *bbolt.Bucket, key []byte, delta int64) error {
+	value := int64(counter(b, key)) + delta
+	if value <= 0 {
+		return b.Delete(key)
+	}
+	return b.Put(key, uint64Key(uint64(value)))
+}
+
+// userKey возвращает ключ user_urls. При пустом short - префикс всех ключей
+// пользователя
+func userKey(user string, short []byte) []byte {
+	key := make([]byte, 0, len(user)+1+len(short))
+	key = append(key, user...)
+	key = append(key, userSep)
+	return append(key, short...)
+}
+
+// revisionKey возвращает ключ revisions: токен, разделитель и номер ревизии
+func revisionKey(short []byte, n int) []byte {
+	return append(userKey(string(short), nil), uint64Key(uint64(n))...)
+}
+
+// expiresKey возвращает ключ expires: срок действия, затем токен
+func expiresKey(expiresAt time.Time, short []byte) []byte {
+	return append(uint64Key(uint64(expiresAt.UnixNano())), short...)
+}
+
+// deletedTime возвращает время удаления из значения бакета deleted
+func deletedTime(value []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value))).UTC()
+}
+
+// uint64Key кодирует число в big-endian, чтобы порядок ключей совпадал
+// с порядком чисел
+func uint64Key(n uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, n)
+	return key
+}
+
+// createdBucket возвращает номер часа, в который была создана ссылка
+func createdBucket(t time.Time) int64 {
+	return t.Unix() / int64(time.Hour/time.Second)
+}
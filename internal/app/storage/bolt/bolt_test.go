@@ -0,0 +1,331 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage открывает базу во временном каталоге
+func newTestStorage(t *testing.T) (*BoltStorage, config.Config) {
+	t.Helper()
+	cfg := config.Config{BoltFile: filepath.Join(t.TempDir(), "shortener.db")}
+	s, err := New(cfg, logger.InitLog())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s, cfg
+}
+
+func TestLinks(t *testing.T) {
+	s, cfg := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, token := range []string{"c", "a", "b"} {
+		_, err := s.AddLink(ctx, token, "https://"+token+".example.com", "user1", time.Time{})
+		require.NoError(t, err)
+	}
+	_, err := s.AddLink(ctx, "d", "https://d.example.com", "user2", time.Time{})
+	require.NoError(t, err)
+
+	_, err = s.AddLink(ctx, "a", "https://other.example.com", "user1", time.Time{})
+	assert.ErrorIs(t, err, models.ErrShortURLAlreadyExist)
+	// исходный URL уже сокращен - возвращается его токен
+	short, err := s.AddLink(ctx, "e", "https://b.example.com", "user2", time.Time{})
+	assert.ErrorIs(t, err, models.ErrorAlreadyExist)
+	assert.Equal(t, "b", short)
+
+	_, err = s.GetLongURL(ctx, "missing")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+
+	// страницы идут по индексу пользователя в порядке токенов
+	page, err := s.GetUserURLsPage(ctx, "user1", "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []models.LinksData{
+		{ShortURL: "a", LongURL: "https://a.example.com"},
+		{ShortURL: "b", LongURL: "https://b.example.com"},
+	}, page)
+	page, err = s.GetUserURLsPage(ctx, "user1", "b", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []models.LinksData{{ShortURL: "c", LongURL: "https://c.example.com"}}, page)
+
+	all, err := s.GetAllURLS(ctx, "user2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"d": "https://d.example.com"}, all)
+
+	s.BatchDelete(ctx, []models.TokenUser{
+		{Token: "a", User: "user1"},
+		// чужую ссылку удалить нельзя
+		{Token: "d", User: "user1"},
+	})
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+
+	// после повторного открытия данные на месте
+	require.NoError(t, s.Close())
+	s, err = New(cfg, logger.InitLog())
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, 4, s.GetStorageLen())
+	long, err := s.GetLongURL(ctx, "d")
+	require.NoError(t, err)
+	assert.Equal(t, "https://d.example.com", long)
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+}
+
+func TestUpdateURL(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	_, err := s.AddLink(ctx, "a", "https://a.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	_, err = s.AddLink(ctx, "b", "https://b.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+
+	_, err = s.UpdateURL(ctx, "a", "user1", "https://c.example.com")
+	require.NoError(t, err)
+	// URL, сокращенный другой ссылкой, занят
+	short, err := s.UpdateURL(ctx, "a", "user1", "https://b.example.com")
+	assert.ErrorIs(t, err, models.ErrorAlreadyExist)
+	assert.Equal(t, "b", short)
+	_, err = s.UpdateURL(ctx, "a", "user2", "https://d.example.com")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	_, err = s.UpdateURL(ctx, "a", "user1", "https://a.example.com")
+	require.NoError(t, err)
+
+	link, err := s.GetRevisions(ctx, "a", "user1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example.com", link.LongURL)
+	require.Len(t, link.Revisions, 2)
+	assert.Equal(t, 1, link.Revisions[0].Revision)
+	assert.Equal(t, "https://a.example.com", link.Revisions[0].LongURL)
+	assert.Equal(t, 2, link.Revisions[1].Revision)
+	assert.Equal(t, "https://c.example.com", link.Revisions[1].LongURL)
+	_, err = s.GetRevisions(ctx, "a", "user2")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+
+	// прежний URL освободился для новых ссылок
+	_, err = s.AddLink(ctx, "c", "https://c.example.com", "user2", time.Time{})
+	assert.NoError(t, err)
+
+	s.BatchDelete(ctx, []models.TokenUser{{Token: "b", User: "user1"}})
+	_, err = s.UpdateURL(ctx, "b", "user1", "https://d.example.com")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+}
+
+func TestBatchDelete(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	for _, token := range []string{"a", "b"} {
+		_, err := s.AddLink(ctx, token, "https://"+token+".example.com", "user1", time.Time{})
+		require.NoError(t, err)
+	}
+	_, err := s.AddLink(ctx, "c", "https://c.example.com", "user2", time.Time{})
+	require.NoError(t, err)
+	_, err = s.BatchDelete(ctx, []models.TokenUser{{Token: "b", User: "user1"}})
+	require.NoError(t, err)
+
+	results, err := s.BatchDelete(ctx, []models.TokenUser{
+		{Token: "a", User: "user1"},
+		{Token: "b", User: "user1"},
+		{Token: "c", User: "user1"},
+		{Token: "missing", User: "user1"},
+		// повтор токена в запросе удаляет ссылку один раз
+		{Token: "a", User: "user1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeleteResult{
+		{ShortURL: "a", Status: models.DeleteDeleted},
+		{ShortURL: "b", Status: models.DeleteAlreadyDeleted},
+		{ShortURL: "c", Status: models.DeleteNotOwner},
+		{ShortURL: "missing", Status: models.DeleteNotFound},
+		{ShortURL: "a", Status: models.DeleteAlreadyDeleted},
+	}, results)
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+	stats, err := s.GetStats(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Deleted)
+}
+
+func TestRestoreAndPurge(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	for _, token := range []string{"a", "b", "c"} {
+		_, err := s.AddLink(ctx, token, "https://"+token+".example.com", "user1", time.Time{})
+		require.NoError(t, err)
+	}
+	before := time.Now()
+	s.BatchDelete(ctx, []models.TokenUser{{Token: "b", User: "user1"}, {Token: "a", User: "user1"}})
+
+	deleted, err := s.GetDeletedURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+	assert.Equal(t, "a", deleted[0].ShortURL)
+	assert.Equal(t, "https://a.example.com", deleted[0].LongURL)
+	assert.False(t, deleted[0].DeletedAt.Before(before.Truncate(time.Millisecond)))
+	assert.Equal(t, "b", deleted[1].ShortURL)
+
+	assert.ErrorIs(t, s.RestoreURL(ctx, "a", "user2"), models.ErrLinkNotFound)
+	assert.ErrorIs(t, s.RestoreURL(ctx, "missing", "user1"), models.ErrLinkNotFound)
+	require.NoError(t, s.RestoreURL(ctx, "a", "user1"))
+	require.NoError(t, s.RestoreURL(ctx, "c", "user1"))
+	longURL, err := s.GetLongURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example.com", longURL)
+	stats, err := s.GetStats(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Deleted)
+
+	count, err := s.PurgeDeleted(ctx, before.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	count, err = s.PurgeDeleted(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = s.GetLongURL(ctx, "b")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	deleted, err = s.GetDeletedURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+	assert.Equal(t, 2, s.GetStorageLen())
+}
+
+func TestShortenBatch(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	_, err := s.AddLink(ctx, "old", "https://old.example.com", "user", time.Time{})
+	require.NoError(t, err)
+
+	resp, err := s.ShortenBatch(ctx, []models.BatchReq{
+		{CorrID: "1", URL: "https://new.example.com", Token: "new"},
+		{CorrID: "2", URL: "https://old.example.com", Token: "x"},
+		{CorrID: "3", URL: "https://new.example.com", Token: "y"},
+	}, "user")
+	require.NoError(t, err)
+	assert.Equal(t, []models.BatchResp{
+		{CorrID: "1", ShortURL: "new"},
+		{CorrID: "2", ShortURL: "old", Error: models.ErrorAlreadyExist.Error()},
+		{CorrID: "3", Error: models.ErrDuplicateURL.Error()},
+	}, resp)
+
+	// занятый токен откатывает весь пакет
+	_, err = s.ShortenBatch(ctx, []models.BatchReq{
+		{CorrID: "1", URL: "https://first.example.com", Token: "first"},
+		{CorrID: "2", URL: "https://second.example.com", Token: "old"},
+	}, "user")
+	assert.ErrorIs(t, err, models.ErrShortURLAlreadyExist)
+	_, err = s.GetLongURL(ctx, "first")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	assert.Equal(t, 2, s.GetStorageLen())
+}
+
+func TestExpiredAndStats(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := s.AddLink(ctx, "a", "https://a.example.com", "user1", now.Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = s.AddLink(ctx, "b", "https://b.example.com", "user1", now.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = s.AddLink(ctx, "c", "https://c.example.com", "user2", time.Time{})
+	require.NoError(t, err)
+	s.BatchDelete(ctx, []models.TokenUser{{Token: "c", User: "user2"}})
+
+	_, err = s.GetLongURL(ctx, "c")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+	stats, err := s.GetStats(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, models.Stats{
+		URLs:       3,
+		Users:      2,
+		Deleted:    1,
+		Created24h: 3,
+		Created7d:  3,
+		TopUsers:   []models.UserLinks{{User: "user1", Links: 2}, {User: "user2", Links: 1}},
+	}, stats)
+
+	// истекшая ссылка помечается удаленной со временем удаления, равным сроку
+	count, err := s.DeleteExpired(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+	count, err = s.DeleteExpired(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	stats, err = s.GetStats(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.URLs)
+	assert.Equal(t, 2, stats.Deleted)
+
+	// окончательно ее удаляет PurgeDeleted, ссылка уходит из индексов и счетчиков
+	count, err = s.PurgeDeleted(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	stats, err = s.GetStats(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.URLs)
+	assert.Equal(t, 2, stats.Users)
+	assert.Equal(t, 1, stats.Deleted)
+	assert.Equal(t, 2, stats.Created24h)
+	assert.Len(t, stats.TopUsers, 1)
+	all, err := s.GetAllURLS(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"b": "https://b.example.com"}, all)
+	// URL снова можно сократить
+	_, err = s.AddLink(ctx, "a2", "https://a.example.com", "user1", time.Time{})
+	assert.NoError(t, err)
+}
+
+func TestAPIKeys(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	key := models.APIKey{ID: "id", Name: "ci", User: "user", Hash: "hash", CreatedAt: time.Now().UTC().Truncate(time.Second)}
+
+	require.NoError(t, s.SaveAPIKey(ctx, key))
+	got, err := s.GetAPIKey(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	require.NoError(t, s.RevokeAPIKey(ctx, "id"))
+	_, err = s.GetAPIKey(ctx, "hash")
+	assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+	assert.ErrorIs(t, s.RevokeAPIKey(ctx, "id"), models.ErrAPIKeyNotFound)
+}
+
+func TestReserveTokens(t *testing.T) {
+	s, cfg := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, token := range []string{"a", "b"} {
+		_, err := s.AddLink(ctx, token, "https://"+token+".example.com", "user", time.Time{})
+		require.NoError(t, err)
+	}
+	// без счетчика выданными считаются номера по числу ссылок
+	from, err := s.ReserveTokens(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), from)
+
+	// граница сохраняется в базе и не зависит от удаления ссылок
+	_, err = s.BatchDelete(ctx, []models.TokenUser{{Token: "a", User: "user"}})
+	require.NoError(t, err)
+	_, err = s.PurgeDeleted(ctx, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	s, err = New(cfg, logger.InitLog())
+	require.NoError(t, err)
+	defer s.Close()
+	from, err = s.ReserveTokens(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), from)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry - результат ResolveURL для токена: исходный URL со сроком действия
+// ссылки или ошибка models.ErrLink*, если ссылки нет, она удалена или истекла.
+// expires - когда запись устареет в кэше
+type entry struct {
+	token       string
+	longURL     string
+	linkExpires time.Time
+	err         error
+	expires     time.Time
+}
+
+// lru - кэш ограниченного размера, при переполнении вытесняется запись,
+// которую дольше всех не читали
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+	// generation растет при каждой инвалидации, см. storer.GetLongURL
+	generation uint64
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// get возвращает запись, если она есть и не устарела к моменту now
+func (c *lru) get(token string, now time.Time) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[token]
+	if !ok {
+		return entry{}, false
+	}
+	e := el.Value.(entry)
+	if !now.Before(e.expires) {
+		c.remove(el)
+		return entry{}, false
+	}
+	c.order.MoveToFront(el)
+	return e, true
+}
+
+// put сохраняет запись, если с момента generation кэш не инвалидировался.
+// Иначе значение могло быть прочитано из хранилища до изменения ссылки
+func (c *lru) put(e entry, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if el, ok := c.items[e.token]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[e.token] = c.order.PushFront(e)
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// currentGeneration возвращает номер текущей инвалидации
+func (c *lru) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// invalidate удаляет записи токенов
+func (c *lru) invalidate(tokens ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, token := range tokens {
+		if el, ok := c.items[token]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// invalidateFunc удаляет записи, для которых match возвращает true
+func (c *lru) invalidateFunc(match func(e entry) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if match(el.Value.(entry)) {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// len возвращает число записей
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove удаляет элемент, вызывается под мьютексом
+func (c *lru) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(entry).token)
+}
@@ -0,0 +1,241 @@
+// Модуль cache кэширует переходы по ссылкам: результат GetLongURL хранится
+// в LRU кэше ограниченного размера перед хранилищем. Кэшируются
+// и отказы (ссылки нет, она удалена или истекла), чтобы перебор токенов
+// не доходил до хранилища. Кэш сбрасывается только в своем процессе,
+// поэтому перед общими для нескольких экземпляров хранилищами postgres
+// и redis он включается лишь явно, см. config.Config.CacheShared
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/config"
+	"golang.org/x/sync/singleflight"
+)
+
+// loadTimeout ограничивает чтение ссылки из хранилища при промахе кэша
+const loadTimeout = 5 * time.Second
+
+// storer оборачивает хранилище и читает исходные URL через кэш.
+// Остальные методы передаются хранилищу без изменений
+type storer struct {
+	service.Storer
+	cache *lru
+	// group объединяет одновременные промахи по одному токену в один запрос
+	group       *singleflight.Group
+	ttl         time.Duration
+	negativeTTL time.Duration
+	hits        *int64
+	misses      *int64
+}
+
+// проверка на имплементацию интерфейса
+var _ service.Storer = storer{}
+
+// NewStorer возвращает хранилище storage с кэшем на cfg.CacheSize токенов.
+// Найденные ссылки хранятся в кэше cfg.CacheTTL, но не дольше своего срока
+// действия, отказы - cfg.CacheNegativeTTL. При нулевом размере кэш
+// не используется, как и перед общим хранилищем без cfg.CacheShared
+func NewStorer(storage service.Storer, cfg config.Config) service.Storer {
+	if cfg.CacheSize <= 0 {
+		return storage
+	}
+	if shared(storage.Backend()) && !cfg.CacheShared {
+		return storage
+	}
+	return storer{
+		Storer:      storage,
+		cache:       newLRU(cfg.CacheSize),
+		group:       &singleflight.Group{},
+		ttl:         cfg.CacheTTL,
+		negativeTTL: cfg.CacheNegativeTTL,
+		hits:        new(int64),
+		misses:      new(int64),
+	}
+}
+
+// Unwrap возвращает хранилище под кэшем
+func (s storer) Unwrap() service.Storer {
+	return s.Storer
+}
+
+// GetLongURL возвращает исходный URL из кэша, а при промахе читает его
+// из хранилища и сохраняет в кэш
+func (s storer) GetLongURL(ctx context.Context, sToken string) (string, error) {
+	e := s.resolve(ctx, sToken)
+	return e.longURL, e.err
+}
+
+// ResolveURL возвращает исходный URL и срок действия ссылки через кэш
+func (s storer) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	e := s.resolve(ctx, sToken)
+	return e.longURL, e.linkExpires, e.err
+}
+
+// resolve ищет ссылку в кэше, а при промахе читает ее из хранилища
+// и сохраняет в кэш
+func (s storer) resolve(ctx context.Context, sToken string) entry {
+	if e, ok := s.cache.get(sToken, time.Now()); ok {
+		atomic.AddInt64(s.hits, 1)
+		return e
+	}
+	atomic.AddInt64(s.misses, 1)
+
+	// результат запроса получат все ожидающие его вызовы, поэтому он
+	// не должен прерываться вместе с запросом первого из них
+	ch := s.group.DoChan(sToken, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(detached{ctx}, loadTimeout)
+		defer cancel()
+		// ссылку могут изменить, пока идет запрос, тогда его результат
+		// в кэш не попадет
+		generation := s.cache.currentGeneration()
+		longURL, linkExpires, err := s.Storer.ResolveURL(ctx, sToken)
+		e := entry{token: sToken, longURL: longURL, linkExpires: linkExpires, err: err}
+		ttl := s.ttl
+		if err != nil {
+			if !negative(err) {
+				return e, nil
+			}
+			ttl = s.negativeTTL
+		}
+		if ttl <= 0 {
+			return e, nil
+		}
+		now := time.Now()
+		e.expires = now.Add(ttl)
+		// истекшая ссылка должна сразу перестать открываться
+		if !linkExpires.IsZero() && linkExpires.Before(e.expires) {
+			e.expires = linkExpires
+		}
+		s.cache.put(e, generation)
+		return e, nil
+	})
+	select {
+	case res := <-ch:
+		return res.Val.(entry)
+	case <-ctx.Done():
+		return entry{token: sToken, err: ctx.Err()}
+	}
+}
+
+// detached передает значения контекста, например span трассировки,
+// но не его отмену и срок
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// AddLink сохраняет ссылку и убирает из кэша отказ по ее токену
+func (s storer) AddLink(ctx context.Context, sToken string, longURL string, user string,
+	expiresAt time.Time) (string, error) {
+	shortURL, err := s.Storer.AddLink(ctx, sToken, longURL, user, expiresAt)
+	if err == nil {
+		s.cache.invalidate(shortURL)
+	}
+	return shortURL, err
+}
+
+// ShortenBatch сохраняет пакет ссылок и убирает из кэша отказы по их токенам
+func (s storer) ShortenBatch(ctx context.Context, batchReq []models.BatchReq, cookie string) ([]models.BatchResp, error) {
+	response, err := s.Storer.ShortenBatch(ctx, batchReq, cookie)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]string, 0, len(response))
+	for _, resp := range response {
+		if resp.Error == "" {
+			tokens = append(tokens, resp.ShortURL)
+		}
+	}
+	s.cache.invalidate(tokens...)
+	return response, nil
+}
+
+// UpdateURL меняет исходный URL и убирает ссылку из кэша
+func (s storer) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error) {
+	shortURL, err := s.Storer.UpdateURL(ctx, sToken, user, longURL)
+	if err == nil {
+		s.cache.invalidate(sToken)
+	}
+	return shortURL, err
+}
+
+// BatchDelete удаляет ссылки и убирает их из кэша
+func (s storer) BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error) {
+	results, err := s.Storer.BatchDelete(ctx, sTokens)
+	tokens := make([]string, 0, len(sTokens))
+	for _, v := range sTokens {
+		tokens = append(tokens, v.Token)
+	}
+	s.cache.invalidate(tokens...)
+	return results, err
+}
+
+// DeleteExpired помечает удаленными ссылки с истекшим сроком и убирает
+// из кэша записи ссылок, истекших к моменту now
+func (s storer) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	count, err := s.Storer.DeleteExpired(ctx, now)
+	if count > 0 {
+		s.cache.invalidateFunc(func(e entry) bool {
+			return e.err == nil && !e.linkExpires.IsZero() && !e.linkExpires.After(now)
+		})
+	}
+	return count, err
+}
+
+// RestoreURL восстанавливает ссылку и убирает из кэша отказ по ее токену
+func (s storer) RestoreURL(ctx context.Context, sToken string, user string) error {
+	err := s.Storer.RestoreURL(ctx, sToken, user)
+	if err == nil {
+		s.cache.invalidate(sToken)
+	}
+	return err
+}
+
+// PurgeDeleted окончательно удаляет ссылки. Хранилище не сообщает их
+// токены, поэтому из кэша убираются все отказы по удаленным и истекшим
+// ссылкам: после очистки по ним отвечает 404, а не 410
+func (s storer) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	count, err := s.Storer.PurgeDeleted(ctx, before)
+	if count > 0 {
+		s.cache.invalidateFunc(func(e entry) bool {
+			return errors.Is(e.err, models.ErrLinkDeleted) || errors.Is(e.err, models.ErrLinkExpired)
+		})
+	}
+	return count, err
+}
+
+// GetStats добавляет к статистике хранилища попадания и промахи кэша
+func (s storer) GetStats(ctx context.Context, top int) (models.Stats, error) {
+	stats, err := s.Storer.GetStats(ctx, top)
+	if err != nil {
+		return stats, err
+	}
+	stats.Cache = &models.CacheStats{
+		Hits:   atomic.LoadInt64(s.hits),
+		Misses: atomic.LoadInt64(s.misses),
+		Size:   s.cache.len(),
+	}
+	return stats, nil
+}
+
+// shared сообщает, что хранилище могут использовать несколько экземпляров
+// сервиса, и изменения через другие экземпляры кэш не увидит
+func shared(backend string) bool {
+	return backend == models.BackendPostgres || backend == models.BackendRedis
+}
+
+// negative сообщает, что хранилище отказало в ссылке, а не сломалось
+func negative(err error) bool {
+	return errors.Is(err, models.ErrLinkNotFound) ||
+		errors.Is(err, models.ErrLinkDeleted) ||
+		errors.Is(err, models.ErrLinkExpired)
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/storage/memory"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorer считает обращения к хранилищу за исходными URL
+type countingStorer struct {
+	*memory.MemoryStorage
+	calls *int64
+	// release, если задан, задерживает ответ хранилища
+	release chan struct{}
+}
+
+func (s countingStorer) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	atomic.AddInt64(s.calls, 1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.MemoryStorage.ResolveURL(ctx, sToken)
+}
+
+func newTestStorer(t *testing.T, size int) (storer, countingStorer) {
+	t.Helper()
+	storage := countingStorer{MemoryStorage: memory.New(config.Config{}, logger.InitLog()), calls: new(int64)}
+	s := NewStorer(storage, config.Config{CacheSize: size, CacheTTL: time.Minute, CacheNegativeTTL: time.Minute})
+	return s.(storer), storage
+}
+
+func TestGetLongURL(t *testing.T) {
+	s, storage := newTestStorer(t, 10)
+	ctx := context.Background()
+	_, err := s.AddLink(ctx, "a", "https://a.example.com", "user", time.Time{})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		longURL, err := s.GetLongURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.example.com", longURL)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt64(storage.calls))
+
+	// отказ тоже кэшируется
+	for i := 0; i < 3; i++ {
+		_, err = s.GetLongURL(ctx, "b")
+		assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt64(storage.calls))
+
+	// созданная ссылка вытесняет отказ
+	_, err = s.AddLink(ctx, "b", "https://b.example.com", "user", time.Time{})
+	require.NoError(t, err)
+	longURL, err := s.GetLongURL(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "https://b.example.com", longURL)
+
+	// удаленная ссылка больше не отдается из кэша
+	s.BatchDelete(ctx, []models.TokenUser{{Token: "a", User: "user"}})
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+
+	stats, err := s.GetStats(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, &models.CacheStats{Hits: 4, Misses: 4, Size: 2}, stats.Cache)
+}
+
+func TestUpdateURL(t *testing.T) {
+	s, _ := newTestStorer(t, 10)
+	ctx := context.Background()
+	_, err := s.AddLink(ctx, "a", "https://a.example.com", "user", time.Time{})
+	require.NoError(t, err)
+	_, err = s.GetLongURL(ctx, "a")
+	require.NoError(t, err)
+
+	// после смены исходного URL переход ведет на новый адрес
+	_, err = s.UpdateURL(ctx, "a", "user", "https://b.example.com")
+	require.NoError(t, err)
+	longURL, err := s.GetLongURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://b.example.com", longURL)
+}
+
+func TestSingleflight(t *testing.T) {
+	s, storage := newTestStorer(t, 10)
+	ctx := context.Background()
+	_, err := s.AddLink(ctx, "a", "https://a.example.com", "user", time.Time{})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	storage.release = release
+	s.Storer = storage
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			longURL, err := s.GetLongURL(ctx, "a")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://a.example.com", longURL)
+		}()
+	}
+	// ждем, пока все запросы станут промахами, и отпускаем хранилище
+	require.Eventually(t, func() bool { return atomic.LoadInt64(s.misses) == 10 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, atomic.LoadInt64(storage.calls))
+}
+
+func TestSingleflightCancel(t *testing.T) {
+	s, storage := newTestStorer(t, 10)
+	_, err := s.AddLink(context.Background(), "a", "https://a.example.com", "user", time.Time{})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	storage.release = release
+	s.Storer = storage
+
+	// первый запрос отменяется, пока хранилище отвечает
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := s.GetLongURL(ctx, "a")
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt64(storage.calls) == 1 }, time.Second, time.Millisecond)
+
+	result := make(chan string)
+	go func() {
+		longURL, err := s.GetLongURL(context.Background(), "a")
+		assert.NoError(t, err)
+		result <- longURL
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt64(s.misses) == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	// второй запрос получает ответ общего чтения
+	close(release)
+	assert.Equal(t, "https://a.example.com", <-result)
+	assert.EqualValues(t, 1, atomic.LoadInt64(storage.calls))
+}
+
+func TestDeleteExpired(t *testing.T) {
+	s, storage := newTestStorer(t, 10)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	_, err := s.AddLink(ctx, "a", "https://a.example.com", "user", expiresAt)
+	require.NoError(t, err)
+	_, err = s.AddLink(ctx, "b", "https://b.example.com", "user", time.Time{})
+	require.NoError(t, err)
+	for _, token := range []string{"a", "b"} {
+		_, err = s.GetLongURL(ctx, token)
+		require.NoError(t, err)
+	}
+
+	count, err := s.DeleteExpired(ctx, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	// из кэша убрана только истекшая ссылка
+	assert.Equal(t, 1, s.cache.len())
+	_, err = s.GetLongURL(ctx, "b")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt64(storage.calls))
+}
+
+func TestEviction(t *testing.T) {
+	s, storage := newTestStorer(t, 2)
+	ctx := context.Background()
+	for _, token := range []string{"a", "b", "c"} {
+		_, err := s.AddLink(ctx, token, "https://"+token+".example.com", "user", time.Time{})
+		require.NoError(t, err)
+	}
+
+	for _, token := range []string{"a", "b", "a", "c"} {
+		_, err := s.GetLongURL(ctx, token)
+		require.NoError(t, err)
+	}
+	// c вытеснил b, который читали давнее a
+	assert.EqualValues(t, 3, atomic.LoadInt64(storage.calls))
+	_, err := s.GetLongURL(ctx, "a")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, atomic.LoadInt64(storage.calls))
+	_, err = s.GetLongURL(ctx, "b")
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, atomic.LoadInt64(storage.calls))
+}
+
+func TestLinkExpiry(t *testing.T) {
+	s, storage := newTestStorer(t, 10)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	_, err := s.AddLink(ctx, "a", "https://a.example.com", "user", expiresAt)
+	require.NoError(t, err)
+
+	longURL, linkExpires, err := s.ResolveURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example.com", longURL)
+	assert.True(t, linkExpires.Equal(expiresAt))
+
+	// запись в кэше живет не дольше ссылки, хотя CacheTTL больше
+	time.Sleep(time.Until(expiresAt))
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkExpired)
+	assert.EqualValues(t, 2, atomic.LoadInt64(storage.calls))
+}
+
+// sharedStorer выдает хранилище в памяти за общее для нескольких экземпляров
+type sharedStorer struct {
+	*memory.MemoryStorage
+}
+
+func (s sharedStorer) Backend() string {
+	return models.BackendRedis
+}
+
+func TestDisabled(t *testing.T) {
+	storage := memory.New(config.Config{}, logger.InitLog())
+	assert.Equal(t, storage, NewStorer(storage, config.Config{}))
+
+	// перед общим хранилищем кэш включается только явно
+	shared := sharedStorer{MemoryStorage: storage}
+	cfg := config.Config{CacheSize: 10, CacheTTL: time.Minute}
+	assert.Equal(t, shared, NewStorer(shared, cfg))
+	cfg.CacheShared = true
+	assert.IsType(t, storer{}, NewStorer(shared, cfg))
+}
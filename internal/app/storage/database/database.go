@@ -5,11 +5,12 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"example.com/shortener/internal/app/models"
 	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/tracing"
 	"example.com/shortener/internal/config"
-	"example.com/shortener/internal/config/utils"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
@@ -29,23 +30,52 @@ var (
 	_ service.Storer = (*dbStorage)(nil)
 )
 
+// countTimeout - таймаут подсчета строк в GetStorageLen
+const countTimeout = 5 * time.Second
+
+// primaryKey - имя ограничения первичного ключа по short_url
+const primaryKey = "urlsdbtable_pkey"
+
 // запросы в бд
 var (
-	createSQL = `CREATE TABLE IF NOT EXISTS urlsDBTable(
-					short_url TEXT,
-					long_url TEXT UNIQUE,
-					cookie TEXT, 
-					deleted BOOLEAN
-					);`
-	insertSQL      = `INSERT INTO urlsDBTable(short_url, long_url, cookie, deleted) VALUES ($1, $2, $3, false)`
+	insertSQL = `INSERT INTO urlsDBTable(short_url, long_url, cookie, deleted, expires_at)
+					VALUES ($1, $2, $3, false, $4)`
 	selectShortURL = `SELECT short_url FROM urlsDBTable WHERE long_url = $1`
 	selectByUser   = `SELECT short_url, long_url FROM urlsDBTable WHERE cookie = $1`
-	selectLongURL  = `SELECT long_url, deleted FROM urlsDBTable WHERE short_url = $1`
-	deleteSQL      = `UPDATE urlsDBTable SET deleted = 'true' WHERE short_url = $1 AND cookie = $2`
-	tokensCount    = `SELECT COUNT(*) FROM urlsDBTable`
-	differentUsers = `SELECT DISTINCT cookie FROM urlsDBTable`
-	pgOnce         sync.Once
-	storage        dbStorage
+	selectUserPage = `SELECT short_url, long_url FROM urlsDBTable
+					WHERE cookie = $1 AND short_url > $2 ORDER BY short_url LIMIT $3`
+	selectLongURL = `SELECT long_url, deleted, expires_at FROM urlsDBTable WHERE short_url = $1`
+	deleteSQL     = `UPDATE urlsDBTable SET deleted = 'true', deleted_at = now()
+					WHERE short_url = $1 AND cookie = $2 AND NOT deleted`
+	linksStats = `SELECT COUNT(*), COUNT(DISTINCT cookie),
+					COUNT(*) FILTER (WHERE deleted),
+					COUNT(*) FILTER (WHERE created_at > $1),
+					COUNT(*) FILTER (WHERE created_at > $2)
+					FROM urlsDBTable`
+	topUsers = `SELECT cookie, COUNT(*) AS links FROM urlsDBTable
+					GROUP BY cookie ORDER BY links DESC, cookie LIMIT $1`
+	tokensCount = `SELECT COUNT(*) FROM urlsDBTable`
+	markExpired = `UPDATE urlsDBTable SET deleted = true, deleted_at = expires_at
+					WHERE NOT deleted AND expires_at IS NOT NULL AND expires_at <= $1`
+	insertAPIKey    = `INSERT INTO api_keys(id, name, cookie, hash, created_at) VALUES ($1, $2, $3, $4, $5)`
+	selectAPIKey    = `SELECT id, name, cookie, created_at FROM api_keys WHERE hash = $1`
+	deleteAPIKey    = `DELETE FROM api_keys WHERE id = $1`
+	selectForUpdate = `SELECT long_url, cookie, deleted FROM urlsDBTable WHERE short_url = $1 FOR UPDATE`
+	selectForDelete = `SELECT short_url, cookie, deleted FROM urlsDBTable
+					WHERE short_url = ANY($1) ORDER BY short_url FOR UPDATE`
+	updateLongURL  = `UPDATE urlsDBTable SET long_url = $2 WHERE short_url = $1`
+	insertRevision = `INSERT INTO url_revisions(short_url, revision, long_url, replaced_at)
+					SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3 FROM url_revisions WHERE short_url = $1`
+	selectOwnedURL = `SELECT long_url FROM urlsDBTable WHERE short_url = $1 AND cookie = $2`
+	restoreSQL     = `UPDATE urlsDBTable SET deleted = false, deleted_at = NULL WHERE short_url = $1 AND cookie = $2`
+	selectDeleted  = `SELECT short_url, long_url, deleted_at FROM urlsDBTable
+					WHERE cookie = $1 AND deleted ORDER BY short_url`
+	purgeDeleted    = `DELETE FROM urlsDBTable WHERE deleted AND deleted_at <= $1`
+	selectRevisions = `SELECT revision, long_url, replaced_at FROM url_revisions
+					WHERE short_url = $1 ORDER BY revision`
+	reserveTokens = `UPDATE token_sequence SET value = value + $1 RETURNING value - $1`
+	pgOnce        sync.Once
+	storage       dbStorage
 )
 
 // New - конструктор для структуры dbStorage
@@ -72,14 +102,15 @@ func (s *dbStorage) AddLink(
 	ctx context.Context,
 	sToken string,
 	longURL string,
-	user string) (string, error) {
+	user string,
+	expiresAt time.Time) (string, error) {
 
 	s.log.WithFields(logrus.Fields{"sToken": sToken,
 		"longURL": longURL,
 		"user":    user}).Info("Записываем в бд")
 
 	// используем контекст запроса
-	shortURL, err := s.InsertLine(ctx, sToken, longURL, user)
+	shortURL, err := s.InsertLine(ctx, sToken, longURL, user, expiresAt)
 	if err != nil {
 		s.log.Error(err.Error())
 		sToken = shortURL
@@ -90,16 +121,39 @@ func (s *dbStorage) AddLink(
 // GetLongURL выбирает из бд исходный URL
 func (s *dbStorage) GetLongURL(ctx context.Context, sToken string) (string, error) {
 
-	longURL, err := s.SelectLink(ctx, sToken)
+	longURL, _, err := s.SelectLink(ctx, sToken)
 	if err != nil {
 		return "", err
 	}
 	return longURL, nil
 }
 
-// метод заглушка
+// ResolveURL выбирает из бд исходный URL и срок действия ссылки
+func (s *dbStorage) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	return s.SelectLink(ctx, sToken)
+}
+
+// ReserveTokens резервирует n номеров последовательных токенов, общих
+// для всех реплик. Возвращает последний номер перед резервом
+func (s *dbStorage) ReserveTokens(ctx context.Context, n uint64) (uint64, error) {
+	var from int64
+	if err := s.pgxPool.QueryRow(ctx, reserveTokens, int64(n)).Scan(&from); err != nil {
+		return 0, err
+	}
+	return uint64(from), nil
+}
+
+// GetStorageLen возвращает число строк в бд
 func (s dbStorage) GetStorageLen() int {
-	return 0
+	ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+	defer cancel()
+
+	var count int
+	if err := s.pgxPool.QueryRow(ctx, tokensCount).Scan(&count); err != nil {
+		s.log.Error(err.Error())
+		return 0
+	}
+	return count
 }
 
 // Ping возвращает ошибку, если не удалось установить связь с бд
@@ -107,6 +161,21 @@ func (s *dbStorage) Ping(ctx context.Context) error {
 	return s.pgxPool.Ping(ctx)
 }
 
+// Backend возвращает название хранилища
+func (s *dbStorage) Backend() string {
+	return models.BackendPostgres
+}
+
+// Stat возвращает статистику пула соединений для метрик
+func (s *dbStorage) Stat() *pgxpool.Stat {
+	return s.pgxPool.Stat()
+}
+
+// Pool возвращает пул соединений, через него пишутся и переходы по ссылкам
+func (s *dbStorage) Pool() *pgxpool.Pool {
+	return s.pgxPool
+}
+
 // GetAllURLs выбирает все сокращенные токены и исходные URL конкретного пользователя
 func (s *dbStorage) GetAllURLS(ctx context.Context, cookie string) (map[string]string, error) {
 	var link models.LinksData
@@ -131,19 +200,52 @@ func (s *dbStorage) GetAllURLS(ctx context.Context, cookie string) (map[string]s
 	return userLinks, nil
 }
 
-// InitTable инициализирует пул соединений pgxpool и создает таблицы, если их еше нет в бд
+// GetUserURLsPage выбирает не больше limit ссылок пользователя
+// с short_url больше after в порядке возрастания short_url
+func (s *dbStorage) GetUserURLsPage(ctx context.Context, cookie string, after string,
+	limit int) ([]models.LinksData, error) {
+	rows, err := s.pgxPool.Query(ctx, selectUserPage, cookie, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make([]models.LinksData, 0, limit)
+	for rows.Next() {
+		var link models.LinksData
+		if err := rows.Scan(&link.ShortURL, &link.LongURL); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// InitTable инициализирует пул соединений pgxpool и применяет миграции схемы бд
 func InitTable(ctx context.Context, connString string, log *logrus.Logger) (*pgxpool.Pool, error) {
 	log.Debug("Инициализация таблицы")
 
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	// запросы попадают в трассировку через журнал pgx
+	poolConfig.ConnConfig.Logger = tracing.PgxLogger{}
+	poolConfig.ConnConfig.LogLevel = pgx.LogLevelInfo
+
 	// открываем соединение с бд
-	pgxPool, err := pgxpool.Connect(ctx, connString)
+	pgxPool, err := pgxpool.ConnectConfig(ctx, poolConfig)
 	if err != nil {
 		log.Debug(err.Error())
 		return nil, err
 	}
 
-	if _, err = pgxPool.Exec(ctx, createSQL); err != nil {
-		log.Debug(err.Error())
+	if err = Migrate(ctx, pgxPool, log); err != nil {
+		log.Error(err.Error())
+		pgxPool.Close()
 		return nil, err
 	}
 
@@ -156,7 +258,8 @@ func (s *dbStorage) InsertLine(
 	ctx context.Context,
 	shortURL string,
 	longURL string,
-	cookie string) (string, error) {
+	cookie string,
+	expiresAt time.Time) (string, error) {
 
 	var pgxError *pgconn.PgError
 	pgxConn, err := s.pgxPool.Acquire(ctx)
@@ -165,7 +268,7 @@ func (s *dbStorage) InsertLine(
 	}
 	defer pgxConn.Release()
 
-	res, err := pgxConn.Exec(ctx, insertSQL, shortURL, longURL, cookie)
+	res, err := pgxConn.Exec(ctx, insertSQL, shortURL, longURL, cookie, nullTime(expiresAt))
 	if err == nil {
 		rows := res.RowsAffected()
 		if rows > 0 {
@@ -178,6 +281,10 @@ func (s *dbStorage) InsertLine(
 	if !errors.As(err, &pgxError) {
 		return "", err
 	}
+	// сокращенный токен (в том числе выбранный пользователем псевдоним) уже занят
+	if pgxError.Code == pgerrcode.UniqueViolation && pgxError.ConstraintName == primaryKey {
+		return "", models.ErrShortURLAlreadyExist
+	}
 	resSelect, errSelect := pgxConn.Query(ctx, selectShortURL, longURL)
 	if errSelect != nil {
 		return "", errSelect
@@ -208,46 +315,123 @@ func (s *dbStorage) InsertLine(
 	return "", err
 }
 
-// BatchDelete удаляет строки из бд с помощью Batch запроса
-func (s dbStorage) BatchDelete(ctx context.Context, sTokens []models.TokenUser) {
-
+// BatchDelete блокирует строки запроса, определяет результат по каждому токену
+// и удаляет подходящие строки с помощью Batch запроса
+func (s dbStorage) BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error) {
+	results := make([]models.DeleteResult, 0, len(sTokens))
 	if len(sTokens) == 0 {
-		return
+		return results, nil
 	}
 
-	batch := &pgx.Batch{}
+	tx, err := s.pgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tokens := make([]string, 0, len(sTokens))
 	for _, v := range sTokens {
-		batch.Queue(deleteSQL, v.Token, v.User)
+		tokens = append(tokens, v.Token)
 	}
-	br := s.pgxPool.SendBatch(ctx, batch)
-	defer br.Close()
-	comTag, err := br.Exec()
+	// строки блокируются в порядке токенов, чтобы параллельные удаления не взаимоблокировались
+	rows, err := tx.Query(ctx, selectForDelete, tokens)
 	if err != nil {
-		s.log.Error(err.Error())
+		return nil, err
+	}
+	type row struct {
+		owner   string
+		deleted bool
+	}
+	found := make(map[string]row, len(sTokens))
+	for rows.Next() {
+		var token string
+		var r row
+		if err = rows.Scan(&token, &r.owner, &r.deleted); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		found[token] = r
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	batch := &pgx.Batch{}
+	for _, v := range sTokens {
+		result := models.DeleteResult{ShortURL: v.Token, Status: models.DeleteDeleted}
+		r, ok := found[v.Token]
+		switch {
+		case !ok:
+			result.Status = models.DeleteNotFound
+		case r.owner != v.User:
+			result.Status = models.DeleteNotOwner
+		case r.deleted:
+			result.Status = models.DeleteAlreadyDeleted
+		default:
+			// повторный токен в запросе считается уже удаленным
+			r.deleted = true
+			found[v.Token] = r
+			batch.Queue(deleteSQL, v.Token, v.User)
+		}
+		results = append(results, result)
+	}
+
+	if batch.Len() > 0 {
+		br := tx.SendBatch(ctx, batch)
+		var changed int64
+		for i := 0; i < batch.Len(); i++ {
+			comTag, err := br.Exec()
+			if err != nil {
+				br.Close()
+				return nil, err
+			}
+			changed += comTag.RowsAffected()
+		}
+		if err = br.Close(); err != nil {
+			return nil, err
+		}
+		s.log.WithFields(logrus.Fields{"changed rows": changed}).Info("После удаления Изменено строк")
 	}
 
-	s.log.WithFields(logrus.Fields{"changed rows": comTag.RowsAffected()}).Info("После удаления Изменено строк")
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 // ShortenBatch записывает новые токены в бд с помощью Batch запроса
 func (s dbStorage) ShortenBatch(ctx context.Context, batchReq []models.BatchReq, cookie string) ([]models.BatchResp, error) {
-	response := make([]models.BatchResp, 0, 100)
+	response := make([]models.BatchResp, 0, len(batchReq))
 
-	batch := &pgx.Batch{}
+	tx, err := s.pgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
+	batch := &pgx.Batch{}
 	for _, batchValue := range batchReq {
 
 		// проверяем, что в базе еще нет такого url
-		_, err := s.findErrorURL(ctx, batchValue.URL)
+		shortURL, err := s.findErrorURL(ctx, batchValue.URL)
 		if err != nil {
 			s.log.Error(err.Error())
 			return nil, err
 		}
+		if shortURL != "" {
+			response = append(response, models.BatchResp{
+				CorrID:   batchValue.CorrID,
+				ShortURL: shortURL,
+				Error:    models.ErrorAlreadyExist.Error(),
+			})
+			continue
+		}
 
-		sToken := utils.GenRandToken(s.config.BaseURL)
+		sToken := batchValue.Token
 		s.log.WithFields(logrus.Fields{"sToken": sToken,
 			"URL": batchValue.URL}).Info("Записываем в бд")
-		batch.Queue(insertSQL, sToken, batchValue.URL, cookie)
+		batch.Queue(insertSQL, sToken, batchValue.URL, cookie, nullTime(batchValue.Expires))
 
 		// формируем структуру для ответа
 		response = append(response, models.BatchResp{
@@ -256,11 +440,25 @@ func (s dbStorage) ShortenBatch(ctx context.Context, batchReq []models.BatchReq,
 		})
 	}
 
-	br := s.pgxPool.SendBatch(ctx, batch)
-	defer br.Close()
-	_, err := br.Exec()
-	if err != nil {
-		s.log.Error(err.Error())
+	// все новые ссылки записываются в одной транзакции
+	br := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			s.log.Error(err.Error())
+			br.Close()
+			var pgxError *pgconn.PgError
+			if errors.As(err, &pgxError) && pgxError.Code == pgerrcode.UniqueViolation &&
+				pgxError.ConstraintName == primaryKey {
+				return nil, models.ErrShortURLAlreadyExist
+			}
+			return nil, err
+		}
+	}
+	if err := br.Close(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
 	}
 
 	s.log.WithFields(logrus.Fields{"response": response}).Info("Структура ответа")
@@ -277,6 +475,7 @@ func (s *dbStorage) findErrorURL(ctx context.Context, URL string) (string, error
 	if err != nil {
 		return "", err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		err := rows.Scan(&link.ShortURL)
 		if err != nil {
@@ -295,20 +494,27 @@ func (s *dbStorage) findErrorURL(ctx context.Context, URL string) (string, error
 }
 
 // SelectLink выбирает исходный URL из бд
-func (s *dbStorage) SelectLink(ctx context.Context, shortURL string) (string, error) {
+func (s *dbStorage) SelectLink(ctx context.Context, shortURL string) (string, time.Time, error) {
 	s.log.Info("Ищем длинный URL в бд")
 	var longURL string
 	var deleted bool
+	var expiresAt *time.Time
 
-	err := s.pgxPool.QueryRow(ctx, selectLongURL, shortURL).Scan(&longURL, &deleted)
+	err := s.pgxPool.QueryRow(ctx, selectLongURL, shortURL).Scan(&longURL, &deleted, &expiresAt)
 	if err != nil {
 		s.log.Error(err.Error())
-		return "", models.ErrLinkNotFound
+		return "", time.Time{}, models.ErrLinkNotFound
 	}
 	if deleted {
-		return "", models.ErrLinkDeleted
+		return "", time.Time{}, models.ErrLinkDeleted
 	}
-	return longURL, nil
+	if expiresAt == nil {
+		return longURL, time.Time{}, nil
+	}
+	if !expiresAt.After(time.Now()) {
+		return "", time.Time{}, models.ErrLinkExpired
+	}
+	return longURL, *expiresAt, nil
 }
 
 // Close закрывает пул соединений с бд
@@ -317,27 +523,204 @@ func (s *dbStorage) Close() error {
 	return nil
 }
 
-// GetStats возвращает данные по общему числу
-// пользователей и сокращенных URL из бд
-func (s *dbStorage) GetStats(ctx context.Context) models.Stats {
+// GetStats возвращает статистику по ссылкам и top пользователей
+// с наибольшим числом ссылок, считая ее агрегатными запросами в бд
+func (s *dbStorage) GetStats(ctx context.Context, top int) (models.Stats, error) {
 	var stats models.Stats
-	row := s.pgxPool.QueryRow(ctx, tokensCount)
+	now := time.Now()
+	err := s.pgxPool.QueryRow(ctx, linksStats, now.Add(-24*time.Hour), now.Add(-7*24*time.Hour)).
+		Scan(&stats.URLs, &stats.Users, &stats.Deleted, &stats.Created24h, &stats.Created7d)
+	if err != nil {
+		return models.Stats{}, err
+	}
 
-	if err := row.Scan(&stats.URLs); err != nil {
-		s.log.Error(err.Error())
-		return models.Stats{}
+	rows, err := s.pgxPool.Query(ctx, topUsers, top)
+	if err != nil {
+		return models.Stats{}, err
 	}
+	defer rows.Close()
 
-	rowsUsers, err := s.pgxPool.Query(ctx, differentUsers)
+	stats.TopUsers = make([]models.UserLinks, 0, top)
+	for rows.Next() {
+		var user models.UserLinks
+		if err := rows.Scan(&user.User, &user.Links); err != nil {
+			return models.Stats{}, err
+		}
+		stats.TopUsers = append(stats.TopUsers, user)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Stats{}, err
+	}
+	return stats, nil
+}
+
+// DeleteExpired ставит метку удаления на строки, срок действия которых истек
+// к моменту now. Временем удаления становится срок действия
+func (s *dbStorage) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	comTag, err := s.pgxPool.Exec(ctx, markExpired, now)
 	if err != nil {
-		s.log.Error(err.Error())
-		return models.Stats{}
+		return 0, err
+	}
+	return int(comTag.RowsAffected()), nil
+}
+
+// nullTime возвращает nil для нулевого времени, чтобы в бд записался NULL
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// SaveAPIKey записывает API ключ в бд
+func (s *dbStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	_, err := s.pgxPool.Exec(ctx, insertAPIKey, key.ID, key.Name, key.User, key.Hash, key.CreatedAt)
+	return err
+}
+
+// GetAPIKey выбирает из бд API ключ по хэшу
+func (s *dbStorage) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	key := models.APIKey{Hash: hash}
+	err := s.pgxPool.QueryRow(ctx, selectAPIKey, hash).Scan(&key.ID, &key.Name, &key.User, &key.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.APIKey{}, models.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey удаляет API ключ из бд
+func (s *dbStorage) RevokeAPIKey(ctx context.Context, id string) error {
+	comTag, err := s.pgxPool.Exec(ctx, deleteAPIKey, id)
+	if err != nil {
+		return err
+	}
+	if comTag.RowsAffected() == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// UpdateURL меняет исходный URL ссылки в транзакции и записывает прежний URL
+// в таблицу ревизий
+func (s *dbStorage) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error) {
+	tx, err := s.pgxPool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var oldURL, owner string
+	var deleted bool
+	err = tx.QueryRow(ctx, selectForUpdate, sToken).Scan(&oldURL, &owner, &deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", models.ErrLinkNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if owner != user {
+		return "", models.ErrLinkNotFound
+	}
+	if deleted {
+		return "", models.ErrLinkDeleted
+	}
+	if oldURL == longURL {
+		return sToken, nil
 	}
 
-	var users int
-	for rowsUsers.Next() {
-		users += 1
+	if _, err = tx.Exec(ctx, updateLongURL, sToken, longURL); err != nil {
+		var pgxError *pgconn.PgError
+		if errors.As(err, &pgxError) && pgxError.Code == pgerrcode.UniqueViolation {
+			// исходный URL уже сокращен другой ссылкой
+			shortURL, errSelect := s.findErrorURL(ctx, longURL)
+			if errSelect != nil {
+				return "", errSelect
+			}
+			return shortURL, models.ErrorAlreadyExist
+		}
+		return "", err
+	}
+	if _, err = tx.Exec(ctx, insertRevision, sToken, oldURL, time.Now().UTC()); err != nil {
+		return "", err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	s.log.WithFields(logrus.Fields{"sToken": sToken, "longURL": longURL}).Info("Исходный URL изменен")
+	return sToken, nil
+}
+
+// GetRevisions выбирает из бд текущий и прежние исходные URL ссылки пользователя
+func (s *dbStorage) GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error) {
+	link := models.LinkRevisions{ShortURL: sToken, Revisions: []models.Revision{}}
+	err := s.pgxPool.QueryRow(ctx, selectOwnedURL, sToken, user).Scan(&link.LongURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.LinkRevisions{}, models.ErrLinkNotFound
+	}
+	if err != nil {
+		return models.LinkRevisions{}, err
+	}
+
+	rows, err := s.pgxPool.Query(ctx, selectRevisions, sToken)
+	if err != nil {
+		return models.LinkRevisions{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var revision models.Revision
+		if err := rows.Scan(&revision.Revision, &revision.LongURL, &revision.ReplacedAt); err != nil {
+			return models.LinkRevisions{}, err
+		}
+		link.Revisions = append(link.Revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return models.LinkRevisions{}, err
+	}
+	return link, nil
+}
+
+// RestoreURL снимает метку удаления со строки пользователя
+func (s *dbStorage) RestoreURL(ctx context.Context, sToken string, user string) error {
+	comTag, err := s.pgxPool.Exec(ctx, restoreSQL, sToken, user)
+	if err != nil {
+		return err
+	}
+	if comTag.RowsAffected() == 0 {
+		return models.ErrLinkNotFound
+	}
+	return nil
+}
+
+// GetDeletedURLs выбирает из бд удаленные ссылки пользователя
+func (s *dbStorage) GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error) {
+	rows, err := s.pgxPool.Query(ctx, selectDeleted, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make([]models.DeletedLink, 0)
+	for rows.Next() {
+		var link models.DeletedLink
+		if err := rows.Scan(&link.ShortURL, &link.LongURL, &link.DeletedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// PurgeDeleted удаляет из бд строки, удаленные не позже before
+func (s *dbStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	comTag, err := s.pgxPool.Exec(ctx, purgeDeleted, before)
+	if err != nil {
+		return 0, err
 	}
-	stats.Users = users
-	return stats
+	return int(comTag.RowsAffected()), nil
 }
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"testing"
+	"time"
 
 	"example.com/shortener/internal/config"
 	"example.com/shortener/internal/config/utils"
@@ -31,7 +32,7 @@ func BenchmarkStorage(b *testing.B) {
 	b.Run("Add link", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			storage.AddLink(context.Background(), utils.RandStringBytes(10),
-				utils.RandStringBytes(30), utils.RandStringBytes(20))
+				utils.RandStringBytes(30), utils.RandStringBytes(20), time.Time{})
 		}
 	})
 
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// migrationsFS содержит файлы миграций вида 0001_name.up.sql и 0001_name.down.sql
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID - ключ advisory lock, под которым выполняются миграции,
+// чтобы несколько запущенных экземпляров сервиса не применяли их одновременно
+const migrationLockID = 7_366_261_015
+
+// запросы для работы с таблицей примененных миграций
+var (
+	createMigrationsSQL = `CREATE TABLE IF NOT EXISTS schema_migrations(
+					version BIGINT PRIMARY KEY,
+					name TEXT NOT NULL,
+					applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+					);`
+	selectMigrations = `SELECT version FROM schema_migrations ORDER BY version`
+	insertMigration  = `INSERT INTO schema_migrations(version, name) VALUES ($1, $2)`
+	deleteMigration  = `DELETE FROM schema_migrations WHERE version = $1`
+	lockMigrations   = `SELECT pg_advisory_lock($1)`
+	unlockMigrations = `SELECT pg_advisory_unlock($1)`
+)
+
+// migration - одна версия схемы бд
+type migration struct {
+	version int64
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations читает встроенные файлы миграций, упорядоченные по версии
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*migration)
+	for _, file := range files {
+		base := strings.TrimPrefix(file, "migrations/")
+		name, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.(up|down).sql", base)
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", base, err)
+		}
+
+		body, err := migrationsFS.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %s: missing up file", m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// Migrate применяет все миграции, которые еще не были применены
+func Migrate(ctx context.Context, pgxPool *pgxpool.Pool, log *logrus.Logger) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, pgxPool, func(conn *pgxpool.Conn, applied map[int64]bool) error {
+		for _, m := range migrations {
+			if applied[m.version] {
+				continue
+			}
+			log.WithFields(logrus.Fields{"migration": m.name}).Info("Применяем миграцию")
+			err := applyMigration(ctx, conn, m.up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, insertMigration, m.version, m.name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Rollback откатывает steps последних примененных миграций
+func Rollback(ctx context.Context, pgxPool *pgxpool.Pool, steps int, log *logrus.Logger) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, pgxPool, func(conn *pgxpool.Conn, applied map[int64]bool) error {
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if !applied[m.version] {
+				continue
+			}
+			if m.down == "" {
+				return fmt.Errorf("migration %s: missing down file", m.name)
+			}
+			log.WithFields(logrus.Fields{"migration": m.name}).Info("Откатываем миграцию")
+			err := applyMigration(ctx, conn, m.down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, deleteMigration, m.version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m.name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// withMigrationLock захватывает advisory lock на отдельном соединении
+// и вызывает fn со списком уже примененных версий
+func withMigrationLock(ctx context.Context, pgxPool *pgxpool.Pool,
+	fn func(conn *pgxpool.Conn, applied map[int64]bool) error) error {
+	conn, err := pgxPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, lockMigrations, migrationLockID); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), unlockMigrations, migrationLockID)
+
+	if _, err := conn.Exec(ctx, createMigrationsSQL); err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(ctx, selectMigrations)
+	if err != nil {
+		return err
+	}
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	return fn(conn, applied)
+}
+
+// applyMigration выполняет sql миграции и обновление schema_migrations в одной транзакции
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, sql string, record func(tx pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"example.com/shortener/internal/logger"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		// версии идут по возрастанию без повторов
+		if i > 0 {
+			assert.Greater(t, m.version, migrations[i-1].version)
+		}
+		// каждую миграцию можно откатить
+		assert.NotEmpty(t, m.up, m.name)
+		assert.NotEmpty(t, m.down, m.name)
+	}
+
+	first := migrations[0]
+	assert.Equal(t, int64(1), first.version)
+	assert.Contains(t, first.up, "PRIMARY KEY (short_url)")
+	assert.Contains(t, first.up, "created_at")
+	assert.Contains(t, first.up, "ON urlsDBTable(cookie)")
+}
+
+// appliedVersions возвращает версии из таблицы schema_migrations
+func appliedVersions(t *testing.T, pool *pgxpool.Pool) []int64 {
+	rows, err := pool.Query(context.Background(), selectMigrations)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	versions := []int64{}
+	for rows.Next() {
+		var version int64
+		require.NoError(t, rows.Scan(&version))
+		versions = append(versions, version)
+	}
+	require.NoError(t, rows.Err())
+	return versions
+}
+
+// TestMigrateRollback применяет, откатывает и снова применяет миграции.
+// Нужна тестовая бд в DATABASE_DSN: все таблицы сервиса в ней удаляются
+func TestMigrateRollback(t *testing.T) {
+	dsn := os.Getenv("DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("DATABASE_DSN не задан")
+	}
+
+	ctx := context.Background()
+	log := logger.InitLog()
+	pool, err := pgxpool.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	migrations, err := loadMigrations()
+	require.NoError(t, err)
+	all := make([]int64, 0, len(migrations))
+	for _, m := range migrations {
+		all = append(all, m.version)
+	}
+
+	require.NoError(t, Migrate(ctx, pool, log))
+	assert.Equal(t, all, appliedVersions(t, pool))
+
+	// повторный запуск ничего не меняет
+	require.NoError(t, Migrate(ctx, pool, log))
+	assert.Equal(t, all, appliedVersions(t, pool))
+
+	require.NoError(t, Rollback(ctx, pool, 1, log))
+	assert.Equal(t, all[:len(all)-1], appliedVersions(t, pool))
+
+	require.NoError(t, Rollback(ctx, pool, len(all), log))
+	assert.Empty(t, appliedVersions(t, pool))
+
+	require.NoError(t, Migrate(ctx, pool, log))
+	assert.Equal(t, all, appliedVersions(t, pool))
+}
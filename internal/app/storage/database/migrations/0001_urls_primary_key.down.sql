@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS urlsdbtable_cookie_idx;
+
+ALTER TABLE urlsDBTable
+    DROP CONSTRAINT IF EXISTS urlsdbtable_pkey,
+    DROP COLUMN IF EXISTS created_at,
+    ALTER COLUMN long_url DROP NOT NULL,
+    ALTER COLUMN cookie DROP NOT NULL,
+    ALTER COLUMN cookie DROP DEFAULT,
+    ALTER COLUMN deleted DROP NOT NULL,
+    ALTER COLUMN deleted DROP DEFAULT;
@@ -0,0 +1,26 @@
+-- исходная таблица, которую создавали версии сервиса без миграций
+CREATE TABLE IF NOT EXISTS urlsDBTable(
+    short_url TEXT,
+    long_url TEXT UNIQUE,
+    cookie TEXT,
+    deleted BOOLEAN
+);
+ALTER TABLE urlsDBTable ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+-- строки без токена нельзя открыть, а дубликаты токена не дают создать первичный ключ
+DELETE FROM urlsDBTable WHERE short_url IS NULL OR long_url IS NULL;
+DELETE FROM urlsDBTable a USING urlsDBTable b
+    WHERE a.short_url = b.short_url AND a.ctid > b.ctid;
+UPDATE urlsDBTable SET cookie = '' WHERE cookie IS NULL;
+UPDATE urlsDBTable SET deleted = false WHERE deleted IS NULL;
+
+ALTER TABLE urlsDBTable
+    ALTER COLUMN long_url SET NOT NULL,
+    ALTER COLUMN cookie SET NOT NULL,
+    ALTER COLUMN cookie SET DEFAULT '',
+    ALTER COLUMN deleted SET NOT NULL,
+    ALTER COLUMN deleted SET DEFAULT false,
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD CONSTRAINT urlsdbtable_pkey PRIMARY KEY (short_url);
+
+CREATE INDEX urlsdbtable_cookie_idx ON urlsDBTable(cookie);
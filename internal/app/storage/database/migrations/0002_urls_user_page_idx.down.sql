@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS urlsdbtable_cookie_idx ON urlsDBTable(cookie);
+
+DROP INDEX IF EXISTS urlsdbtable_cookie_short_url_idx;
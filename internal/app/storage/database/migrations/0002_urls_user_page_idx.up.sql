@@ -0,0 +1,5 @@
+-- постраничная выборка ссылок пользователя идет по (cookie, short_url),
+-- составной индекс заменяет индекс только по cookie
+CREATE INDEX IF NOT EXISTS urlsdbtable_cookie_short_url_idx ON urlsDBTable(cookie, short_url);
+
+DROP INDEX IF EXISTS urlsdbtable_cookie_idx;
@@ -0,0 +1,9 @@
+-- API ключи для серверных клиентов. Вместо ключа хранится его хэш,
+-- cookie - пользователь, от имени которого работает ключ
+CREATE TABLE IF NOT EXISTS api_keys(
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    cookie TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+-- прежние исходные URL ссылок, revision нумеруется с единицы для каждой ссылки
+CREATE TABLE IF NOT EXISTS url_revisions(
+    short_url TEXT NOT NULL REFERENCES urlsDBTable(short_url) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    long_url TEXT NOT NULL,
+    replaced_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (short_url, revision)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS urlsdbtable_deleted_at_idx;
+ALTER TABLE urlsDBTable DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+-- время удаления, по нему удаленные ссылки окончательно удаляются после срока хранения.
+-- Для ссылок, удаленных до миграции, срок отсчитывается с момента миграции
+ALTER TABLE urlsDBTable ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+UPDATE urlsDBTable SET deleted_at = now() WHERE deleted AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS urlsdbtable_deleted_at_idx ON urlsDBTable(deleted_at) WHERE deleted;
@@ -0,0 +1,10 @@
+-- последний зарезервированный номер последовательных токенов, одна строка.
+-- Номера до появления счетчика не резервировались, поэтому выданными
+-- считаются номера по числу ссылок
+CREATE TABLE IF NOT EXISTS token_sequence(
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    value BIGINT NOT NULL
+);
+INSERT INTO token_sequence(id, value)
+    SELECT true, COUNT(*) FROM urlsDBTable
+    ON CONFLICT (id) DO NOTHING;
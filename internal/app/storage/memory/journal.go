@@ -0,0 +1,252 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+)
+
+// Операции, которые записываются в журнал
+const (
+	OpAdd     = "add"
+	OpDelete  = "delete"
+	OpRestore = "restore"
+	OpPurge   = "purge"
+	// OpUpdate - замена исходного URL ссылки на LongURL
+	OpUpdate = "update"
+	// OpKeyAdd и OpKeyRevoke - выпуск и отзыв API ключа, ключ передается в Key
+	OpKeyAdd    = "key_add"
+	OpKeyRevoke = "key_revoke"
+	// OpSequence - новая верхняя граница счетчика последовательных токенов
+	OpSequence = "sequence"
+)
+
+// Режимы сброса журнала на диск
+const (
+	// SyncAlways - fsync после каждой записи в журнал
+	SyncAlways = "always"
+	// SyncInterval - fsync раз в syncInterval в фоновой горутине
+	SyncInterval = "interval"
+	// SyncNever - сброс на диск остается на усмотрение операционной системы
+	SyncNever = "never"
+)
+
+// syncInterval - период fsync журнала в режиме SyncInterval
+var syncInterval = time.Second
+
+// snapshotSuffix - суффикс имени файла снимка относительно имени журнала
+const snapshotSuffix = ".snapshot"
+
+// Record - запись журнала об одной операции.
+// Записи без Op остались от старого формата файла, где хранились только ссылки,
+// они читаются как OpAdd. Поля Deleted, DeletedAt и Revisions используются только в снимке
+type Record struct {
+	Op        string     `json:"op,omitempty"`
+	ShortURL  string     `json:"short"`
+	LongURL   string     `json:"long,omitempty"`
+	User      string     `json:"user,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Time      time.Time  `json:"time,omitempty"`
+	Key       *APIKey    `json:"key,omitempty"`
+	// Revisions - прежние исходные URL ссылки
+	Revisions []models.Revision `json:"revisions,omitempty"`
+	// Revision - номер ревизии, которую создает OpUpdate. По нему замена,
+	// уже вошедшая в снимок, не применяется повторно
+	Revision int `json:"revision,omitempty"`
+	// Sequence - верхняя граница зарезервированных номеров OpSequence
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// APIKey - API ключ в журнале. В отличие от models.APIKey, хэш ключа
+// сохраняется в файл
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// journal - файл, в который дописываются записи об операциях (write-ahead log)
+type journal struct {
+	mu       sync.Mutex
+	file     *os.File
+	filename string
+	sync     string
+	dirty    bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// openJournal открывает журнал на дозапись
+func openJournal(filename string, syncMode string) (*journal, error) {
+	switch syncMode {
+	case "":
+		syncMode = SyncAlways
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("unknown file sync mode: %s", syncMode)
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{
+		file:     file,
+		filename: filename,
+		sync:     syncMode,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if syncMode == SyncInterval {
+		go j.syncLoop()
+	} else {
+		close(j.done)
+	}
+	return j, nil
+}
+
+// Append дописывает записи в журнал одной операцией записи
+func (j *journal) Append(records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	buf := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	for _, r := range records {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if j.sync == SyncAlways {
+		return j.file.Sync()
+	}
+	j.dirty = true
+	return nil
+}
+
+// Compact записывает снимок состояния snapshot в файл снимка и очищает журнал.
+// Снимок сначала пишется во временный файл и атомарно его заменяет
+func (j *journal) Compact(snapshot []Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	tmpName := j.filename + snapshotSuffix + ".tmp"
+	tmp, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	for _, r := range snapshot {
+		if err := encoder.Encode(r); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, j.filename+snapshotSuffix); err != nil {
+		return err
+	}
+
+	// все записи журнала уже вошли в снимок. Если сбой произойдет до очистки,
+	// записи журнала будут повторно применены к снимку, это безопасно
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	j.dirty = false
+	return j.file.Sync()
+}
+
+// Close сбрасывает журнал на диск и закрывает файл
+func (j *journal) Close() error {
+	close(j.stop)
+	<-j.done
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.file.Sync(); err != nil {
+		j.file.Close()
+		return err
+	}
+	return j.file.Close()
+}
+
+// syncLoop периодически сбрасывает журнал на диск в режиме SyncInterval
+func (j *journal) syncLoop() {
+	defer close(j.done)
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			if j.dirty {
+				j.file.Sync()
+				j.dirty = false
+			}
+			j.mu.Unlock()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// readRecords читает записи из файла и передает их в apply.
+// Отсутствующий файл считается пустым, а недописанная последняя строка
+// (например, после сбоя во время записи) пропускается
+func readRecords(filename string, apply func(r Record)) error {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var r Record
+		err := decoder.Decode(&r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		apply(r)
+	}
+}
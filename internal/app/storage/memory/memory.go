@@ -1,109 +1,305 @@
-// модуль memory реализует хранение данных в файле
+// модуль memory реализует хранение данных в памяти
+// с журналом операций в файле
 package memory
 
 import (
 	"context"
-	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"example.com/shortener/internal/app/models"
 	"example.com/shortener/internal/config"
 	"github.com/sirupsen/logrus"
 )
 
-// LinksData структура записи в файл
-type LinksData struct {
-	ShortURL string `json:"short"`
-	LongURL  string `json:"long"`
-	User     string `json:"user"`
-	deleted  bool
-}
-
 var mutex sync.Mutex
 
 // MemoryStorage реализует методы для взаимодействия с хранилищем из файла
 type MemoryStorage struct {
-	linksMap   map[string]string
+	linksMap map[string]string
+	// urlsMap - токен по исходному URL, как уникальный индекс в других хранилищах
+	urlsMap    map[string]string
 	cookiesMap map[string]string
-	deletedMap map[string]bool
-	config     config.Config
-	mu         *sync.Mutex
-	log        *logrus.Logger
+	// deletedMap - время удаления ссылок с меткой удаления
+	deletedMap map[string]time.Time
+	expiresMap map[string]time.Time
+	createdMap map[string]time.Time
+	// revisions - прежние исходные URL ссылок
+	revisions map[string][]models.Revision
+	// счетчики для статистики, поддерживаются при применении операций
+	userLinks      map[string]int
+	createdBuckets map[int64]int
+	// apiKeys - API ключи по хэшу
+	apiKeys map[string]models.APIKey
+	// sequence - последний зарезервированный номер последовательных токенов
+	sequence *uint64
+	config   config.Config
+	mu       *sync.Mutex
+	journal  *journal
+	stop     chan struct{}
+	log      *logrus.Logger
 }
 
-// New - конструктор для MemoryStorage
+// New - конструктор для MemoryStorage.
+// Если задан файл, состояние восстанавливается из снимка и журнала операций,
+// а новые операции дописываются в журнал
 func New(config config.Config, log *logrus.Logger) *MemoryStorage {
 	memStore := &MemoryStorage{
 		linksMap:   make(map[string]string),
+		urlsMap:    make(map[string]string),
 		cookiesMap: map[string]string{},
-		deletedMap: make(map[string]bool),
-		config:     config,
-		mu:         &mutex,
-		log:        log,
+		deletedMap: make(map[string]time.Time),
+		expiresMap: make(map[string]time.Time),
+		createdMap: make(map[string]time.Time),
+		revisions:  make(map[string][]models.Revision),
+
+		userLinks:      make(map[string]int),
+		createdBuckets: make(map[int64]int),
+		apiKeys:        make(map[string]models.APIKey),
+		sequence:       new(uint64),
+		config:         config,
+		mu:             &mutex,
+		stop:           make(chan struct{}),
+		log:            log,
 	}
 	if config.File != "" {
 		memStore.ReadFromFile()
+
+		var err error
+		memStore.journal, err = openJournal(config.File, config.FileSync)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		if config.CompactInterval > 0 {
+			go memStore.compactLoop(config.CompactInterval)
+		}
 	}
 	return memStore
 }
 
-// AddLink записывает связку исходный URL- сокращенный токен в файл
-func (s MemoryStorage) AddLink(ctx context.Context, sToken string, longURL string, user string) (string, error) {
-	var err error
+// AddLink записывает связку исходный URL- сокращенный токен в файл.
+// Если исходный URL уже сокращен, возвращает его токен и models.ErrorAlreadyExist
+func (s MemoryStorage) AddLink(ctx context.Context, sToken string, longURL string, user string,
+	expiresAt time.Time) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	_, ok := s.linksMap[sToken]
 	if ok {
 		s.log.Info("link already exists")
-		return "", errors.New("link already exists")
+		return "", models.ErrShortURLAlreadyExist
+	}
+	if short, ok := s.urlsMap[longURL]; ok {
+		return short, models.ErrorAlreadyExist
 	}
 
-	s.linksMap[sToken] = longURL
-	s.cookiesMap[sToken] = user
-
-	s.log.WithFields(logrus.Fields{"Мапа со ссылками": s.linksMap})
-
-	s.WriteInFile()
-	return sToken, err
+	record := Record{
+		Op:       OpAdd,
+		ShortURL: sToken,
+		LongURL:  longURL,
+		User:     user,
+		Time:     time.Now().UTC(),
+	}
+	if !expiresAt.IsZero() {
+		record.ExpiresAt = &expiresAt
+	}
+	if err := s.writeRecords(record); err != nil {
+		return "", err
+	}
+	s.apply(record)
+	return sToken, nil
 }
 
 // GetLongURL возвращает исходный URL из файла
 func (s MemoryStorage) GetLongURL(ctx context.Context, sToken string) (string, error) {
-	var err error
+	longURL, _, err := s.ResolveURL(ctx, sToken)
+	return longURL, err
+}
+
+// ResolveURL возвращает исходный URL и срок действия ссылки
+func (s MemoryStorage) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	longURL, ok := s.linksMap[sToken]
 	if !ok {
-		return "", errors.New("link is not found")
+		return "", time.Time{}, models.ErrLinkNotFound
 	}
-	if deleted := s.deletedMap[sToken]; deleted {
-		return "", models.ErrLinkDeleted
+	if _, deleted := s.deletedMap[sToken]; deleted {
+		return "", time.Time{}, models.ErrLinkDeleted
 	}
-	return longURL, err
+	expires, ok := s.expiresMap[sToken]
+	if ok && !expires.After(time.Now()) {
+		return "", time.Time{}, models.ErrLinkExpired
+	}
+	return longURL, expires, nil
+}
+
+// ReserveTokens резервирует n номеров последовательных токенов и записывает
+// новую верхнюю границу в журнал. Возвращает последний номер перед резервом
+func (s MemoryStorage) ReserveTokens(ctx context.Context, n uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := *s.sequence
+	record := Record{Op: OpSequence, Sequence: from + n, Time: time.Now().UTC()}
+	if err := s.writeRecords(record); err != nil {
+		return 0, err
+	}
+	s.apply(record)
+	return from, nil
 }
 
 // метод заглушка
 func (s MemoryStorage) GetStorageLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return len(s.linksMap)
 }
 
-// метод заглушка
+// Ping всегда успешен: хранилище в памяти процесса доступно, пока он работает.
+// Доступность файла журнала проверяется отдельно
 func (s MemoryStorage) Ping(ctx context.Context) error {
-	return errors.New("база данных не активна")
+	return nil
 }
 
-// метод заглушка
+// Backend возвращает название хранилища: file, если состояние сохраняется
+// в файл, иначе memory
+func (s MemoryStorage) Backend() string {
+	if s.journal != nil {
+		return models.BackendFile
+	}
+	return models.BackendMemory
+}
+
+// ShortenBatch сокращает пакет URL атомарно: ссылки попадают в журнал
+// одной записью и применяются к мапам, только если запись удалась.
+// Для URL, которые уже сокращены или повторяются в пакете, ссылка не создается,
+// а в ответе заполняется поле Error. Если занят хотя бы один из токенов,
+// пакет не сохраняется
 func (s MemoryStorage) ShortenBatch(ctx context.Context, batchReq []models.BatchReq, cookie string) ([]models.BatchResp, error) {
-	return nil, errors.New("база данных не активна")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := make([]models.BatchResp, 0, len(batchReq))
+	records := make([]Record, 0, len(batchReq))
+	tokens := make(map[string]struct{}, len(batchReq))
+	seen := make(map[string]struct{}, len(batchReq))
+	now := time.Now().UTC()
+	for _, item := range batchReq {
+		if short, ok := s.urlsMap[item.URL]; ok {
+			response = append(response, models.BatchResp{
+				CorrID:   item.CorrID,
+				ShortURL: short,
+				Error:    models.ErrorAlreadyExist.Error(),
+			})
+			continue
+		}
+		if _, ok := seen[item.URL]; ok {
+			response = append(response, models.BatchResp{
+				CorrID: item.CorrID,
+				Error:  models.ErrDuplicateURL.Error(),
+			})
+			continue
+		}
+		seen[item.URL] = struct{}{}
+
+		// токен не должен совпадать ни с сохраненными, ни с другими токенами пакета
+		sToken := item.Token
+		_, stored := s.linksMap[sToken]
+		_, issued := tokens[sToken]
+		if stored || issued {
+			return nil, models.ErrShortURLAlreadyExist
+		}
+		tokens[sToken] = struct{}{}
+
+		record := Record{
+			Op:       OpAdd,
+			ShortURL: sToken,
+			LongURL:  item.URL,
+			User:     cookie,
+			Time:     now,
+		}
+		if !item.Expires.IsZero() {
+			expires := item.Expires
+			record.ExpiresAt = &expires
+		}
+		records = append(records, record)
+		response = append(response, models.BatchResp{CorrID: item.CorrID, ShortURL: sToken})
+	}
+
+	if err := s.writeRecords(records...); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		s.apply(r)
+	}
+	return response, nil
 }
 
-// метод заглушка
+// UpdateURL меняет исходный URL ссылки и записывает замену в журнал.
+// Если longURL уже сокращен другой ссылкой, возвращает ее токен
+// и models.ErrorAlreadyExist
+func (s MemoryStorage) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if owner, ok := s.cookiesMap[sToken]; !ok || owner != user {
+		return "", models.ErrLinkNotFound
+	}
+	if _, deleted := s.deletedMap[sToken]; deleted {
+		return "", models.ErrLinkDeleted
+	}
+	if s.linksMap[sToken] == longURL {
+		return sToken, nil
+	}
+	if short, ok := s.urlsMap[longURL]; ok {
+		return short, models.ErrorAlreadyExist
+	}
+
+	record := Record{
+		Op:       OpUpdate,
+		ShortURL: sToken,
+		LongURL:  longURL,
+		Time:     time.Now().UTC(),
+		Revision: len(s.revisions[sToken]) + 1,
+	}
+	if err := s.writeRecords(record); err != nil {
+		return "", err
+	}
+	s.apply(record)
+	return sToken, nil
+}
+
+// GetRevisions возвращает текущий и прежние исходные URL ссылки пользователя
+func (s MemoryStorage) GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if owner, ok := s.cookiesMap[sToken]; !ok || owner != user {
+		return models.LinkRevisions{}, models.ErrLinkNotFound
+	}
+	revisions := make([]models.Revision, len(s.revisions[sToken]))
+	copy(revisions, s.revisions[sToken])
+	return models.LinkRevisions{ShortURL: sToken, LongURL: s.linksMap[sToken], Revisions: revisions}, nil
+}
+
+// Close сжимает журнал в снимок и закрывает файл
 func (s MemoryStorage) Close() error {
-	return errors.New("база данных не активна")
+	if s.journal == nil {
+		return nil
+	}
+	close(s.stop)
+	s.Compact()
+	return s.journal.Close()
 }
 
 // GetAllURLs возвращает все URL сокращенные пользователем из файла
 func (s MemoryStorage) GetAllURLS(ctx context.Context, cookie string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	userLinks := make(map[string]string)
 	for short, user := range s.cookiesMap {
 		if user != cookie {
@@ -114,83 +310,453 @@ func (s MemoryStorage) GetAllURLS(ctx context.Context, cookie string) (map[strin
 	return userLinks, nil
 }
 
-// ReadFromFile читает данные из файла в мапу
+// GetUserURLsPage возвращает не больше limit ссылок пользователя
+// с сокращенным URL больше after в порядке возрастания
+func (s MemoryStorage) GetUserURLsPage(ctx context.Context, cookie string, after string,
+	limit int) ([]models.LinksData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shorts := make([]string, 0)
+	for short, user := range s.cookiesMap {
+		if user == cookie && short > after {
+			shorts = append(shorts, short)
+		}
+	}
+	sort.Strings(shorts)
+	if len(shorts) > limit {
+		shorts = shorts[:limit]
+	}
+
+	links := make([]models.LinksData, 0, len(shorts))
+	for _, short := range shorts {
+		links = append(links, models.LinksData{ShortURL: short, LongURL: s.linksMap[short]})
+	}
+	return links, nil
+}
+
+// ReadFromFile восстанавливает состояние из снимка и повторяет поверх него
+// операции из журнала
 func (s MemoryStorage) ReadFromFile() {
 	s.log.Debug("Читаем из файла")
 	s.log.WithFields(logrus.Fields{"Имя файла": s.config.File})
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	consumer, err := NewConsumer(s.config.File)
-	if err != nil {
-		s.log.Fatal(err.Error())
+
+	for _, filename := range []string{s.config.File + snapshotSuffix, s.config.File} {
+		if err := readRecords(filename, s.apply); err != nil {
+			s.log.Error(err.Error())
+		}
+	}
+	// в файлах, записанных до появления счетчика, номера не резервировались:
+	// считаем выданными столько номеров, сколько осталось ссылок
+	if *s.sequence == 0 {
+		*s.sequence = uint64(len(s.linksMap))
+	}
+}
+
+// Compact записывает текущее состояние в снимок и очищает журнал
+func (s MemoryStorage) Compact() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make([]Record, 0, len(s.linksMap))
+	now := time.Now().UTC()
+	for short, long := range s.linksMap {
+		// в снимке сохраняется время создания ссылки, если оно известно
+		created, ok := s.createdMap[short]
+		if !ok {
+			created = now
+		}
+		record := Record{
+			Op:        OpAdd,
+			ShortURL:  short,
+			LongURL:   long,
+			User:      s.cookiesMap[short],
+			Time:      created,
+			Revisions: s.revisions[short],
+		}
+		if deletedAt, ok := s.deletedMap[short]; ok {
+			record.Deleted = true
+			record.DeletedAt = &deletedAt
+		}
+		if expires, ok := s.expiresMap[short]; ok {
+			record.ExpiresAt = &expires
+		}
+		snapshot = append(snapshot, record)
 	}
-	defer consumer.Close()
+	for _, key := range s.apiKeys {
+		snapshot = append(snapshot, Record{Op: OpKeyAdd, Key: journalKey(key), Time: now})
+	}
+	if *s.sequence > 0 {
+		snapshot = append(snapshot, Record{Op: OpSequence, Sequence: *s.sequence, Time: now})
+	}
+	if err := s.journal.Compact(snapshot); err != nil {
+		s.log.Error(err.Error())
+		return
+	}
+	s.log.WithFields(logrus.Fields{"links": len(snapshot)}).Debug("Журнал сжат в снимок")
+}
 
+// compactLoop периодически сжимает журнал, пока хранилище не будет закрыто
+func (s MemoryStorage) compactLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
-		readlinks, err := consumer.ReadLinks()
-		if err != nil {
-			s.log.Debug(err.Error())
-			break
+		select {
+		case <-ticker.C:
+			s.Compact()
+		case <-s.stop:
+			return
 		}
-		_, ok := s.linksMap[readlinks.ShortURL]
-		if ok {
-			continue
+	}
+}
+
+// apply применяет запись журнала к мапам. Все операции идемпотентны,
+// поэтому повторное применение записей, уже вошедших в снимок, безопасно.
+// Вызывается под мьютексом хранилища
+func (s MemoryStorage) apply(r Record) {
+	switch r.Op {
+	case OpAdd, "":
+		if _, ok := s.linksMap[r.ShortURL]; ok {
+			return
+		}
+		s.linksMap[r.ShortURL] = r.LongURL
+		s.indexURL(r.LongURL, r.ShortURL)
+		s.cookiesMap[r.ShortURL] = r.User
+		s.userLinks[r.User]++
+		if !r.Time.IsZero() {
+			s.createdMap[r.ShortURL] = r.Time
+			s.createdBuckets[createdBucket(r.Time)]++
+		}
+		if r.Deleted {
+			// в снимках старого формата нет времени удаления,
+			// срок хранения таких ссылок отсчитывается с запуска
+			deletedAt := time.Now().UTC()
+			if r.DeletedAt != nil {
+				deletedAt = *r.DeletedAt
+			}
+			s.deletedMap[r.ShortURL] = deletedAt
+		}
+		if r.ExpiresAt != nil {
+			s.expiresMap[r.ShortURL] = *r.ExpiresAt
+		}
+		if len(r.Revisions) > 0 {
+			s.revisions[r.ShortURL] = r.Revisions
+		}
+	case OpUpdate:
+		longURL, ok := s.linksMap[r.ShortURL]
+		if !ok || longURL == r.LongURL {
+			return
+		}
+		revisions := s.revisions[r.ShortURL]
+		// замена уже есть в снимке: журнал не очистился после сжатия
+		if r.Revision > 0 && len(revisions) >= r.Revision {
+			return
+		}
+		s.revisions[r.ShortURL] = append(revisions, models.Revision{
+			Revision:   len(revisions) + 1,
+			LongURL:    longURL,
+			ReplacedAt: r.Time,
+		})
+		s.unindexURL(longURL, r.ShortURL)
+		s.linksMap[r.ShortURL] = r.LongURL
+		s.indexURL(r.LongURL, r.ShortURL)
+	case OpDelete:
+		if _, ok := s.linksMap[r.ShortURL]; !ok {
+			return
+		}
+		if _, deleted := s.deletedMap[r.ShortURL]; !deleted {
+			s.deletedMap[r.ShortURL] = r.Time
+		}
+	case OpRestore:
+		delete(s.deletedMap, r.ShortURL)
+	case OpPurge:
+		user, ok := s.cookiesMap[r.ShortURL]
+		if !ok {
+			return
+		}
+		if s.userLinks[user]--; s.userLinks[user] <= 0 {
+			delete(s.userLinks, user)
+		}
+		if created, ok := s.createdMap[r.ShortURL]; ok {
+			bucket := createdBucket(created)
+			if s.createdBuckets[bucket]--; s.createdBuckets[bucket] <= 0 {
+				delete(s.createdBuckets, bucket)
+			}
+		}
+		delete(s.createdMap, r.ShortURL)
+		s.unindexURL(s.linksMap[r.ShortURL], r.ShortURL)
+		delete(s.linksMap, r.ShortURL)
+		delete(s.cookiesMap, r.ShortURL)
+		delete(s.deletedMap, r.ShortURL)
+		delete(s.expiresMap, r.ShortURL)
+		delete(s.revisions, r.ShortURL)
+	case OpKeyAdd:
+		if r.Key != nil {
+			s.apiKeys[r.Key.Hash] = models.APIKey(*r.Key)
+		}
+	case OpKeyRevoke:
+		if r.Key == nil {
+			return
+		}
+		for hash, key := range s.apiKeys {
+			if key.ID == r.Key.ID {
+				delete(s.apiKeys, hash)
+			}
+		}
+	case OpSequence:
+		if r.Sequence > *s.sequence {
+			*s.sequence = r.Sequence
 		}
-		s.linksMap[readlinks.ShortURL] = readlinks.LongURL
-		s.cookiesMap[readlinks.ShortURL] = readlinks.User
-		s.deletedMap[readlinks.ShortURL] = readlinks.deleted
 	}
+}
 
+// indexURL добавляет токен в индекс по исходному URL. В файлах, записанных
+// до появления проверки, один URL может быть сокращен несколько раз,
+// тогда в индексе остается первый токен
+func (s MemoryStorage) indexURL(longURL string, short string) {
+	if _, ok := s.urlsMap[longURL]; !ok {
+		s.urlsMap[longURL] = short
+	}
 }
 
-// WriteInFile реализует запись в файл
-func (s MemoryStorage) WriteInFile() {
-	if s.config.File == "" {
-		return
+// unindexURL убирает токен из индекса по исходному URL
+func (s MemoryStorage) unindexURL(longURL string, short string) {
+	if s.urlsMap[longURL] == short {
+		delete(s.urlsMap, longURL)
 	}
-	producer, err := NewProducer(s.config.File)
-	if err != nil {
-		s.log.Fatal(err.Error())
+}
+
+// writeRecords дописывает записи в журнал, если хранилище работает с файлом
+func (s MemoryStorage) writeRecords(records ...Record) error {
+	if s.journal == nil {
+		return nil
 	}
-	defer producer.Close()
-	s.log.Info("Записываем в файл")
-	s.log.WithFields(logrus.Fields{"Имя файла %s": s.config.File})
+	return s.journal.Append(records...)
+}
 
-	for short, long := range s.linksMap {
-		var links = LinksData{
-			ShortURL: short,
-			LongURL:  long,
-			User:     s.cookiesMap[short],
-			deleted:  s.deletedMap[short],
+// BatchDelete ставит метки удаления на строки из мапы и записывает их в журнал
+func (s MemoryStorage) BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]models.DeleteResult, 0, len(sTokens))
+	records := make([]Record, 0, len(sTokens))
+	// токены, удаляемые этим же запросом
+	pending := make(map[string]struct{}, len(sTokens))
+	now := time.Now().UTC()
+	for _, v := range sTokens {
+		result := models.DeleteResult{ShortURL: v.Token, Status: models.DeleteDeleted}
+		user, ok := s.cookiesMap[v.Token]
+		_, deleted := s.deletedMap[v.Token]
+		_, queued := pending[v.Token]
+		switch {
+		case !ok:
+			result.Status = models.DeleteNotFound
+		case user != v.User:
+			result.Status = models.DeleteNotOwner
+		case deleted || queued:
+			result.Status = models.DeleteAlreadyDeleted
+		default:
+			pending[v.Token] = struct{}{}
+			records = append(records, Record{Op: OpDelete, ShortURL: v.Token, Time: now})
+		}
+		results = append(results, result)
+	}
+	if err := s.writeRecords(records...); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		s.apply(r)
+	}
+	return results, nil
+}
+
+// createdBucket возвращает номер часа, в который была создана ссылка.
+// Число созданных ссылок считается по часам, чтобы статистика за последние
+// сутки и неделю не требовала обхода всех ссылок
+func createdBucket(t time.Time) int64 {
+	return t.Unix() / int64(time.Hour/time.Second)
+}
+
+// statsWeek - самый длинный период, за который считается число созданных ссылок
+const statsWeek = 7 * 24 * time.Hour
+
+// GetStats возвращает статистику по ссылкам из поддерживаемых счетчиков.
+// Число созданных ссылок считается с точностью до часа
+func (s MemoryStorage) GetStats(ctx context.Context, top int) (models.Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := models.Stats{
+		URLs:     len(s.linksMap),
+		Users:    len(s.userLinks),
+		Deleted:  len(s.deletedMap),
+		TopUsers: make([]models.UserLinks, 0, len(s.userLinks)),
+	}
+
+	now := time.Now()
+	dayAgo := createdBucket(now.Add(-24 * time.Hour))
+	weekAgo := createdBucket(now.Add(-statsWeek))
+	for bucket, count := range s.createdBuckets {
+		// более старые часы больше не понадобятся
+		if bucket <= weekAgo {
+			delete(s.createdBuckets, bucket)
+			continue
 		}
-		if err := producer.WriteLinks(&links); err != nil {
-			s.log.Fatal(err.Error())
+		stats.Created7d += count
+		if bucket > dayAgo {
+			stats.Created24h += count
 		}
 	}
+
+	for user, links := range s.userLinks {
+		stats.TopUsers = append(stats.TopUsers, models.UserLinks{User: user, Links: links})
+	}
+	sort.Slice(stats.TopUsers, func(i, j int) bool {
+		if stats.TopUsers[i].Links != stats.TopUsers[j].Links {
+			return stats.TopUsers[i].Links > stats.TopUsers[j].Links
+		}
+		return stats.TopUsers[i].User < stats.TopUsers[j].User
+	})
+	if len(stats.TopUsers) > top {
+		stats.TopUsers = stats.TopUsers[:top]
+	}
+	return stats, nil
 }
 
-// BatchDelete ставит метки удаления на строки из мапы и записывает в файл
-func (s MemoryStorage) BatchDelete(ctx context.Context, sTokens []models.TokenUser) {
-	s.ReadFromFile()
-	var flagDeleted bool
-	for _, v := range sTokens {
-		user, ok := s.cookiesMap[v.Token]
-		if user != v.User || !ok {
+// DeleteExpired ставит метку удаления на ссылки, срок действия которых истек
+// к моменту now, и записывает удаление в журнал. Временем удаления
+// становится срок действия
+func (s MemoryStorage) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []Record
+	for short, expires := range s.expiresMap {
+		if _, deleted := s.deletedMap[short]; deleted || expires.After(now) {
+			continue
+		}
+		records = append(records, Record{Op: OpDelete, ShortURL: short, Time: expires.UTC()})
+	}
+	if err := s.writeRecords(records...); err != nil {
+		return 0, err
+	}
+	for _, r := range records {
+		s.apply(r)
+	}
+	return len(records), nil
+}
+
+// RestoreURL снимает метку удаления со ссылки и записывает восстановление в журнал
+func (s MemoryStorage) RestoreURL(ctx context.Context, sToken string, user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if owner, ok := s.cookiesMap[sToken]; !ok || owner != user {
+		return models.ErrLinkNotFound
+	}
+	if _, deleted := s.deletedMap[sToken]; !deleted {
+		return nil
+	}
+	record := Record{Op: OpRestore, ShortURL: sToken, Time: time.Now().UTC()}
+	if err := s.writeRecords(record); err != nil {
+		return err
+	}
+	s.apply(record)
+	return nil
+}
+
+// GetDeletedURLs возвращает удаленные ссылки пользователя
+func (s MemoryStorage) GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links := make([]models.DeletedLink, 0)
+	for short, deletedAt := range s.deletedMap {
+		if s.cookiesMap[short] != user {
 			continue
 		}
-		flag, ok := s.deletedMap[v.Token]
-		if flag || !ok {
+		links = append(links, models.DeletedLink{ShortURL: short, LongURL: s.linksMap[short], DeletedAt: deletedAt})
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].ShortURL < links[j].ShortURL })
+	return links, nil
+}
+
+// PurgeDeleted удаляет из мапы ссылки, удаленные не позже before
+func (s MemoryStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []Record
+	now := time.Now().UTC()
+	for short, deletedAt := range s.deletedMap {
+		if deletedAt.After(before) {
 			continue
 		}
-		s.deletedMap[v.Token] = true
-		flagDeleted = true
+		records = append(records, Record{Op: OpPurge, ShortURL: short, Time: now})
+	}
+	if err := s.writeRecords(records...); err != nil {
+		return 0, err
+	}
+	for _, r := range records {
+		s.apply(r)
+	}
+	return len(records), nil
+}
+
+// SaveAPIKey сохраняет API ключ и записывает его в журнал
+func (s MemoryStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := Record{Op: OpKeyAdd, Key: journalKey(key), Time: time.Now().UTC()}
+	if err := s.writeRecords(record); err != nil {
+		return err
+	}
+	s.apply(record)
+	return nil
+}
+
+// GetAPIKey ищет API ключ по хэшу
+func (s MemoryStorage) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[hash]
+	if !ok {
+		return models.APIKey{}, models.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// RevokeAPIKey удаляет API ключ и записывает отзыв в журнал
+func (s MemoryStorage) RevokeAPIKey(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+	for _, key := range s.apiKeys {
+		if key.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return models.ErrAPIKeyNotFound
 	}
-	if flagDeleted {
-		s.WriteInFile()
+
+	record := Record{Op: OpKeyRevoke, Key: &APIKey{ID: id}, Time: time.Now().UTC()}
+	if err := s.writeRecords(record); err != nil {
+		return err
 	}
+	s.apply(record)
+	return nil
 }
 
-// заглушка
-func (s MemoryStorage) GetStats(ctx context.Context) models.Stats {
-	return models.Stats{}
+// journalKey переводит API ключ в запись журнала
+func journalKey(key models.APIKey) *APIKey {
+	k := APIKey(key)
+	return &k
 }
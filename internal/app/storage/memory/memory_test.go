@@ -0,0 +1,429 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalReplay(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncAlways}
+
+	storer := New(cfg, log)
+	_, err := storer.AddLink(ctx, "a", "https://a.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	_, err = storer.AddLink(ctx, "b", "https://b.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	_, err = storer.AddLink(ctx, "c", "https://c.example.com", "user2", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	storer.BatchDelete(ctx, []models.TokenUser{
+		{Token: "a", User: "user1"},
+		// чужую ссылку удалить нельзя
+		{Token: "b", User: "user2"},
+	})
+	count, err := storer.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// в журнале по одной записи на операцию, а не копия всей мапы
+	data, err := os.ReadFile(cfg.File)
+	require.NoError(t, err)
+	assert.Equal(t, 5, countLines(data))
+
+	// недописанная строка в конце журнала не мешает восстановлению
+	f, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_APPEND, 0664)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"add","short":"d"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restored := New(cfg, log)
+	assert.Equal(t, 3, restored.GetStorageLen())
+	_, err = restored.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+	got, err := restored.GetLongURL(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://b.example.com", got)
+	// истекшая ссылка помечена удаленной со временем удаления, равным сроку
+	_, err = restored.GetLongURL(ctx, "c")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+	deleted, err := restored.GetDeletedURLs(ctx, "user2")
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, restored.expiresMap["c"], deleted[0].DeletedAt)
+}
+
+func TestJournalCompact(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncInterval}
+
+	// файл в старом формате без операций читается как набор добавленных ссылок
+	legacy := `{"short":"a","long":"https://a.example.com","user":"user1"}` + "\n" +
+		`{"short":"a","long":"https://a.example.com","user":"user1"}` + "\n"
+	require.NoError(t, os.WriteFile(cfg.File, []byte(legacy), 0664))
+
+	storer := New(cfg, log)
+	assert.Equal(t, 1, storer.GetStorageLen())
+	_, err := storer.AddLink(ctx, "b", "https://b.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	storer.BatchDelete(ctx, []models.TokenUser{{Token: "b", User: "user1"}})
+
+	// при закрытии журнал сжимается в снимок
+	require.NoError(t, storer.Close())
+	data, err := os.ReadFile(cfg.File)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+	snapshot, err := os.ReadFile(cfg.File + snapshotSuffix)
+	require.NoError(t, err)
+	// две ссылки и граница счетчика последовательных токенов
+	assert.Equal(t, 3, countLines(snapshot))
+
+	restored := New(cfg, log)
+	defer restored.Close()
+	assert.Equal(t, 2, restored.GetStorageLen())
+	got, err := restored.GetLongURL(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://a.example.com", got)
+	// метка удаления сохраняется в снимке
+	_, err = restored.GetLongURL(ctx, "b")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+}
+
+func TestJournalReplayUpdates(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncAlways}
+
+	storer := New(cfg, log)
+	_, err := storer.AddLink(ctx, "a", "https://a.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	_, err = storer.UpdateURL(ctx, "a", "user1", "https://b.example.com")
+	require.NoError(t, err)
+	_, err = storer.UpdateURL(ctx, "a", "user1", "https://a.example.com")
+	require.NoError(t, err)
+	journal, err := os.ReadFile(cfg.File)
+	require.NoError(t, err)
+	require.NoError(t, storer.Close())
+
+	// сбой между записью снимка и очисткой журнала: замены из журнала
+	// повторно применяются к снимку, в котором они уже есть
+	require.NoError(t, os.WriteFile(cfg.File, journal, 0664))
+	restored := New(cfg, log)
+	defer restored.Close()
+	link, err := restored.GetRevisions(ctx, "a", "user1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example.com", link.LongURL)
+	require.Len(t, link.Revisions, 2)
+	assert.Equal(t, "https://a.example.com", link.Revisions[0].LongURL)
+	assert.Equal(t, "https://b.example.com", link.Revisions[1].LongURL)
+}
+
+func TestExistingURL(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncAlways}
+
+	storer := New(cfg, log)
+	_, err := storer.AddLink(ctx, "a", "https://a.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	_, err = storer.AddLink(ctx, "b", "https://b.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+
+	// исходный URL уже сокращен - возвращается его токен
+	short, err := storer.AddLink(ctx, "c", "https://a.example.com", "user2", time.Time{})
+	assert.ErrorIs(t, err, models.ErrorAlreadyExist)
+	assert.Equal(t, "a", short)
+	short, err = storer.UpdateURL(ctx, "b", "user1", "https://a.example.com")
+	assert.ErrorIs(t, err, models.ErrorAlreadyExist)
+	assert.Equal(t, "a", short)
+
+	// после замены URL освобождается, индекс восстанавливается из журнала
+	_, err = storer.UpdateURL(ctx, "a", "user1", "https://c.example.com")
+	require.NoError(t, err)
+	require.NoError(t, storer.Close())
+	restored := New(cfg, log)
+	defer restored.Close()
+	_, err = restored.AddLink(ctx, "c", "https://a.example.com", "user2", time.Time{})
+	assert.NoError(t, err)
+	short, err = restored.AddLink(ctx, "d", "https://c.example.com", "user2", time.Time{})
+	assert.ErrorIs(t, err, models.ErrorAlreadyExist)
+	assert.Equal(t, "a", short)
+}
+
+func TestUpdateURL(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncAlways}
+
+	storer := New(cfg, log)
+	_, err := storer.AddLink(ctx, "a", "https://a.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	for _, longURL := range []string{"https://b.example.com", "https://b.example.com", "https://c.example.com"} {
+		_, err = storer.UpdateURL(ctx, "a", "user1", longURL)
+		require.NoError(t, err)
+	}
+
+	// чужую и удаленную ссылку изменить нельзя
+	_, err = storer.UpdateURL(ctx, "a", "user2", "https://d.example.com")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	_, err = storer.GetRevisions(ctx, "a", "user2")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	_, err = storer.AddLink(ctx, "b", "https://b.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	storer.BatchDelete(ctx, []models.TokenUser{{Token: "b", User: "user1"}})
+	_, err = storer.UpdateURL(ctx, "b", "user1", "https://d.example.com")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+
+	check := func(s *MemoryStorage) {
+		t.Helper()
+		link, err := s.GetRevisions(ctx, "a", "user1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://c.example.com", link.LongURL)
+		// повторная замена тем же URL ревизию не создает
+		require.Len(t, link.Revisions, 2)
+		assert.Equal(t, 1, link.Revisions[0].Revision)
+		assert.Equal(t, "https://a.example.com", link.Revisions[0].LongURL)
+		assert.Equal(t, 2, link.Revisions[1].Revision)
+		assert.Equal(t, "https://b.example.com", link.Revisions[1].LongURL)
+		longURL, err := s.GetLongURL(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "https://c.example.com", longURL)
+	}
+
+	// история восстанавливается из журнала и из снимка
+	check(New(cfg, log))
+	require.NoError(t, storer.Close())
+	check(New(cfg, log))
+}
+
+func TestBatchDelete(t *testing.T) {
+	ctx := context.Background()
+	s := New(config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncAlways}, logger.InitLog())
+	for _, token := range []string{"a", "b"} {
+		_, err := s.AddLink(ctx, token, "https://"+token+".example.com", "user1", time.Time{})
+		require.NoError(t, err)
+	}
+	_, err := s.AddLink(ctx, "c", "https://c.example.com", "user2", time.Time{})
+	require.NoError(t, err)
+	_, err = s.BatchDelete(ctx, []models.TokenUser{{Token: "b", User: "user1"}})
+	require.NoError(t, err)
+
+	results, err := s.BatchDelete(ctx, []models.TokenUser{
+		{Token: "a", User: "user1"},
+		{Token: "b", User: "user1"},
+		{Token: "c", User: "user1"},
+		{Token: "missing", User: "user1"},
+		// повтор токена в запросе удаляет ссылку один раз
+		{Token: "a", User: "user1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeleteResult{
+		{ShortURL: "a", Status: models.DeleteDeleted},
+		{ShortURL: "b", Status: models.DeleteAlreadyDeleted},
+		{ShortURL: "c", Status: models.DeleteNotOwner},
+		{ShortURL: "missing", Status: models.DeleteNotFound},
+		{ShortURL: "a", Status: models.DeleteAlreadyDeleted},
+	}, results)
+	_, err = s.GetLongURL(ctx, "a")
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+	stats, err := s.GetStats(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Deleted)
+}
+
+func TestRestoreAndPurge(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncAlways}
+
+	storer := New(cfg, log)
+	for _, token := range []string{"a", "b", "c"} {
+		_, err := storer.AddLink(ctx, token, "https://"+token+".example.com", "user1", time.Time{})
+		require.NoError(t, err)
+	}
+	before := time.Now()
+	storer.BatchDelete(ctx, []models.TokenUser{{Token: "b", User: "user1"}, {Token: "a", User: "user1"}})
+
+	deleted, err := storer.GetDeletedURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+	assert.Equal(t, "a", deleted[0].ShortURL)
+	assert.Equal(t, "https://a.example.com", deleted[0].LongURL)
+	assert.False(t, deleted[0].DeletedAt.Before(before))
+	assert.Equal(t, "b", deleted[1].ShortURL)
+
+	// восстановить можно только свою ссылку
+	assert.ErrorIs(t, storer.RestoreURL(ctx, "a", "user2"), models.ErrLinkNotFound)
+	require.NoError(t, storer.RestoreURL(ctx, "a", "user1"))
+	require.NoError(t, storer.RestoreURL(ctx, "c", "user1"))
+	longURL, err := storer.GetLongURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example.com", longURL)
+
+	// время удаления переживает сжатие журнала
+	require.NoError(t, storer.Close())
+	restored := New(cfg, log)
+	deleted, err = restored.GetDeletedURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, "b", deleted[0].ShortURL)
+
+	count, err := restored.PurgeDeleted(ctx, before)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	count, err = restored.PurgeDeleted(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = restored.GetLongURL(ctx, "b")
+	assert.ErrorIs(t, err, models.ErrLinkNotFound)
+	assert.Equal(t, 2, restored.GetStorageLen())
+}
+
+func TestShortenBatch(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{
+		BaseURL:  "http://localhost:8080/",
+		File:     filepath.Join(t.TempDir(), "link.log"),
+		FileSync: SyncAlways,
+	}
+
+	storer := New(cfg, log)
+	existing, err := storer.AddLink(ctx, "http://localhost:8080/a", "https://a.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour)
+	batch := []models.BatchReq{
+		{CorrID: "1", URL: "https://b.example.com", Token: "http://localhost:8080/b"},
+		{CorrID: "2", URL: "https://a.example.com", Token: "http://localhost:8080/a2"},
+		{CorrID: "3", URL: "https://b.example.com", Token: "http://localhost:8080/b2"},
+		{CorrID: "4", URL: "https://c.example.com", Token: "http://localhost:8080/c", Expires: expires},
+	}
+
+	// если токен занят, пакет не сохраняется целиком
+	batch[3].Token = existing
+	_, err = storer.ShortenBatch(ctx, batch, "user2")
+	assert.ErrorIs(t, err, models.ErrShortURLAlreadyExist)
+	assert.Equal(t, 1, storer.GetStorageLen())
+
+	batch[3].Token = "http://localhost:8080/c"
+	resp, err := storer.ShortenBatch(ctx, batch, "user2")
+	require.NoError(t, err)
+	require.Len(t, resp, 4)
+
+	assert.Empty(t, resp[0].Error)
+	// уже сокращенный URL возвращается с существующей ссылкой
+	assert.Equal(t, models.BatchResp{CorrID: "2", ShortURL: existing, Error: models.ErrorAlreadyExist.Error()}, resp[1])
+	assert.Equal(t, models.BatchResp{CorrID: "3", Error: models.ErrDuplicateURL.Error()}, resp[2])
+	assert.Equal(t, models.BatchResp{CorrID: "4", ShortURL: "http://localhost:8080/c"}, resp[3])
+	assert.Equal(t, 3, storer.GetStorageLen())
+
+	// новые ссылки пакета попадают в журнал
+	data, err := os.ReadFile(cfg.File)
+	require.NoError(t, err)
+	assert.Equal(t, 3, countLines(data))
+
+	restored := New(cfg, log)
+	links, err := restored.GetAllURLS(ctx, "user2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		resp[0].ShortURL: "https://b.example.com",
+		resp[3].ShortURL: "https://c.example.com",
+	}, links)
+}
+
+func TestGetStats(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncNever}
+
+	// ссылки, созданные раньше, чем неделю назад, не попадают в счетчики по времени
+	j, err := openJournal(cfg.File, SyncNever)
+	require.NoError(t, err)
+	require.NoError(t, j.Append(Record{
+		Op: OpAdd, ShortURL: "old", LongURL: "https://old.example.com", User: "user2",
+		Time: time.Now().Add(-8 * 24 * time.Hour),
+	}))
+	require.NoError(t, j.Close())
+
+	storer := New(cfg, log)
+	_, err = storer.AddLink(ctx, "a", "https://a.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	_, err = storer.AddLink(ctx, "b", "https://b.example.com", "user1", time.Time{})
+	require.NoError(t, err)
+	_, err = storer.AddLink(ctx, "c", "https://c.example.com", "user3", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	storer.BatchDelete(ctx, []models.TokenUser{{Token: "a", User: "user1"}})
+	_, err = storer.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+
+	// истекшая ссылка помечена удаленной и считается до окончательного удаления
+	want := models.Stats{
+		URLs:       4,
+		Users:      3,
+		Deleted:    2,
+		Created24h: 3,
+		Created7d:  3,
+		TopUsers:   []models.UserLinks{{User: "user1", Links: 2}},
+	}
+	stats, err := storer.GetStats(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, want, stats)
+
+	// время создания ссылок сохраняется в снимке
+	require.NoError(t, storer.Close())
+	restored := New(cfg, log)
+	defer restored.Close()
+	stats, err = restored.GetStats(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, want, stats)
+}
+
+func TestAPIKeys(t *testing.T) {
+	log := logger.InitLog()
+	ctx := context.Background()
+	cfg := config.Config{File: filepath.Join(t.TempDir(), "link.log"), FileSync: SyncAlways}
+
+	storer := New(cfg, log)
+	first := models.APIKey{ID: "1", Name: "billing", User: "user1", Hash: "hash1", CreatedAt: time.Now().UTC()}
+	second := models.APIKey{ID: "2", User: "user2", Hash: "hash2", CreatedAt: time.Now().UTC()}
+	require.NoError(t, storer.SaveAPIKey(ctx, first))
+	require.NoError(t, storer.SaveAPIKey(ctx, second))
+	require.NoError(t, storer.RevokeAPIKey(ctx, "2"))
+	assert.ErrorIs(t, storer.RevokeAPIKey(ctx, "2"), models.ErrAPIKeyNotFound)
+
+	// ключи восстанавливаются из журнала, а после сжатия - из снимка
+	restored := New(cfg, log)
+	require.NoError(t, restored.Close())
+	restored = New(cfg, log)
+	defer restored.Close()
+	for _, storer := range []*MemoryStorage{storer, restored} {
+		key, err := storer.GetAPIKey(ctx, "hash1")
+		require.NoError(t, err)
+		assert.Equal(t, first.User, key.User)
+		assert.Equal(t, first.Name, key.Name)
+		_, err = storer.GetAPIKey(ctx, "hash2")
+		assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+	}
+}
+
+func countLines(data []byte) int {
+	var lines int
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	return lines
+}
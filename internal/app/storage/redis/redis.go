@@ -0,0 +1,869 @@
+// модуль redis реализует хранение данных на сервере, совместимом с протоколом
+// Redis (RESP). Ссылка хранится в хэше с меткой удаления, ссылки пользователя -
+// в упорядоченном множестве, что позволяет листать их по токену. Изменения
+// выполняются в MULTI/EXEC под WATCH, без скриптов Lua, поэтому хранилище
+// работает с любым RESP-совместимым сервером, в тестах - с miniredis
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/config"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// prefix - префикс всех ключей сервиса
+const prefix = "shortener:"
+
+// Ключи индексов
+const (
+	// created: токен со временем создания (мс), по нему считаются ссылки
+	keyCreated = prefix + "created"
+	// expires: токен со сроком действия (мс)
+	keyExpires = prefix + "expires"
+	// users: пользователь с числом его ссылок
+	keyUsers = prefix + "users"
+	// deleted: удаленные токены со временем удаления (мс)
+	keyDeleted = prefix + "deleted"
+	// api_key_ids: хэш с полями идентификатор API ключа -> хэш ключа
+	keyAPIKeyIDs = prefix + "api_key_ids"
+	// sequence: последний зарезервированный номер последовательных токенов
+	keySequence = prefix + "sequence"
+)
+
+// Поля хэша ссылки
+const (
+	fieldLongURL   = "long_url"
+	fieldUser      = "user"
+	fieldCreatedAt = "created_at"
+	fieldExpiresAt = "expires_at"
+	// fieldDeleted - время удаления, у неудаленной ссылки поля нет
+	fieldDeleted = "deleted"
+)
+
+// maxTxAttempts - сколько раз повторять транзакцию, если отслеживаемые
+// ключи изменил другой клиент
+const maxTxAttempts = 10
+
+// statsWeek - самый длинный период, за который считается число созданных ссылок
+const statsWeek = 7 * 24 * time.Hour
+
+// link - ссылка из хэша
+type link struct {
+	LongURL   string
+	User      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Deleted   bool
+	DeletedAt time.Time
+}
+
+// revision - прежний исходный URL в списке ревизий ссылки. Номер ревизии -
+// позиция в списке, начиная с единицы
+type revision struct {
+	LongURL    string    `json:"long_url"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// RedisStorage реализует методы для взаимодействия с хранилищем Redis
+type RedisStorage struct {
+	client *goredis.Client
+	log    *logrus.Logger
+}
+
+// New - конструктор для RedisStorage. Подключается к серверу
+// по адресу config.RedisURL (redis://[user:password@]host:port/db)
+func New(ctx context.Context, config config.Config, log *logrus.Logger) (*RedisStorage, error) {
+	options, err := goredis.ParseURL(config.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	client := goredis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &RedisStorage{client: client, log: log}, nil
+}
+
+// AddLink записывает ссылку. Если исходный URL уже сокращен, возвращает
+// его токен и models.ErrorAlreadyExist
+func (s *RedisStorage) AddLink(ctx context.Context, sToken string, longURL string, user string,
+	expiresAt time.Time) (string, error) {
+	var existing string
+	err := s.watch(ctx, func(tx *goredis.Tx) error {
+		existing = ""
+		if err := checkFree(ctx, tx, sToken); err != nil {
+			return err
+		}
+		short, err := tx.Get(ctx, urlKey(longURL)).Result()
+		if err == nil {
+			existing = short
+			return models.ErrorAlreadyExist
+		}
+		if !errors.Is(err, goredis.Nil) {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			putLink(ctx, pipe, sToken, newLink(longURL, user, expiresAt))
+			return nil
+		})
+		return err
+	}, linkKey(sToken), urlKey(longURL))
+	if err != nil {
+		return existing, err
+	}
+	return sToken, nil
+}
+
+// GetLongURL возвращает исходный URL по токену
+func (s *RedisStorage) GetLongURL(ctx context.Context, sToken string) (string, error) {
+	longURL, _, err := s.ResolveURL(ctx, sToken)
+	return longURL, err
+}
+
+// ResolveURL возвращает исходный URL и срок действия ссылки
+func (s *RedisStorage) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	l, err := getLink(ctx, s.client, sToken)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if l.Deleted {
+		return "", time.Time{}, models.ErrLinkDeleted
+	}
+	if !l.ExpiresAt.IsZero() && !l.ExpiresAt.After(time.Now()) {
+		return "", time.Time{}, models.ErrLinkExpired
+	}
+	return l.LongURL, l.ExpiresAt, nil
+}
+
+// Ping возвращает ошибку, если сервер недоступен
+func (s *RedisStorage) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Backend возвращает название хранилища
+func (s *RedisStorage) Backend() string {
+	return models.BackendRedis
+}
+
+// GetAllURLS возвращает все ссылки пользователя
+func (s *RedisStorage) GetAllURLS(ctx context.Context, cookie string) (map[string]string, error) {
+	shorts, err := s.client.ZRange(ctx, userKey(cookie), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.longURLs(ctx, shorts)
+	if err != nil {
+		return nil, err
+	}
+	userLinks := make(map[string]string, len(links))
+	for _, l := range links {
+		userLinks[l.ShortURL] = l.LongURL
+	}
+	return userLinks, nil
+}
+
+// GetUserURLsPage возвращает не больше limit ссылок пользователя
+// с сокращенным URL больше after в порядке возрастания. У всех токенов
+// в множестве пользователя одинаковый вес, поэтому они упорядочены по токену
+func (s *RedisStorage) GetUserURLsPage(ctx context.Context, cookie string, after string,
+	limit int) ([]models.LinksData, error) {
+	min := "-"
+	if after != "" {
+		min = "(" + after
+	}
+	shorts, err := s.client.ZRangeByLex(ctx, userKey(cookie), &goredis.ZRangeBy{
+		Min:   min,
+		Max:   "+",
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.longURLs(ctx, shorts)
+}
+
+// ShortenBatch сохраняет пакет ссылок в одной транзакции. Для URL, которые
+// уже сокращены или повторяются в пакете, ссылка не создается, а в ответе
+// заполняется поле Error. Если занят хотя бы один из токенов,
+// не сохраняется ничего
+func (s *RedisStorage) ShortenBatch(ctx context.Context, batchReq []models.BatchReq, cookie string) ([]models.BatchResp, error) {
+	keys := make([]string, 0, 2*len(batchReq))
+	for _, item := range batchReq {
+		keys = append(keys, linkKey(item.Token), urlKey(item.URL))
+	}
+
+	var response []models.BatchResp
+	err := s.watch(ctx, func(tx *goredis.Tx) error {
+		response = make([]models.BatchResp, 0, len(batchReq))
+		// уже сокращенные URL читаются одним запросом
+		urls := make([]string, 0, len(batchReq))
+		for _, item := range batchReq {
+			urls = append(urls, urlKey(item.URL))
+		}
+		existing, err := tx.MGet(ctx, urls...).Result()
+		if err != nil {
+			return err
+		}
+
+		var links []models.BatchReq
+		tokens := make(map[string]struct{}, len(batchReq))
+		seen := make(map[string]struct{}, len(batchReq))
+		for i, item := range batchReq {
+			if short, ok := existing[i].(string); ok {
+				response = append(response, models.BatchResp{
+					CorrID:   item.CorrID,
+					ShortURL: short,
+					Error:    models.ErrorAlreadyExist.Error(),
+				})
+				continue
+			}
+			if _, ok := seen[item.URL]; ok {
+				response = append(response, models.BatchResp{
+					CorrID: item.CorrID,
+					Error:  models.ErrDuplicateURL.Error(),
+				})
+				continue
+			}
+			seen[item.URL] = struct{}{}
+
+			// токен не должен совпадать ни с сохраненными, ни с другими токенами пакета
+			if _, ok := tokens[item.Token]; ok {
+				return models.ErrShortURLAlreadyExist
+			}
+			if err := checkFree(ctx, tx, item.Token); err != nil {
+				return err
+			}
+			tokens[item.Token] = struct{}{}
+			links = append(links, item)
+			response = append(response, models.BatchResp{CorrID: item.CorrID, ShortURL: item.Token})
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			for _, item := range links {
+				putLink(ctx, pipe, item.Token, newLink(item.URL, cookie, item.Expires))
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// BatchDelete ставит метки удаления на ссылки, принадлежащие пользователям из запроса.
+// Ссылки читаются под WATCH, чтобы результат по каждому токену совпадал с записанным
+func (s *RedisStorage) BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error) {
+	if len(sTokens) == 0 {
+		return []models.DeleteResult{}, nil
+	}
+	keys := make([]string, 0, len(sTokens))
+	for _, v := range sTokens {
+		keys = append(keys, linkKey(v.Token))
+	}
+
+	var results []models.DeleteResult
+	err := s.watch(ctx, func(tx *goredis.Tx) error {
+		// владельцы и метки удаления читаются одним запросом
+		cmds := make([]*goredis.SliceCmd, 0, len(sTokens))
+		_, err := tx.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+			for _, key := range keys {
+				cmds = append(cmds, pipe.HMGet(ctx, key, fieldUser, fieldDeleted))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		results = make([]models.DeleteResult, 0, len(sTokens))
+		// токены, удаляемые этим же запросом
+		pending := make(map[string]struct{}, len(sTokens))
+		now := time.Now().UTC()
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			for i, v := range sTokens {
+				result := models.DeleteResult{ShortURL: v.Token, Status: models.DeleteDeleted}
+				values := cmds[i].Val()
+				user, ok := values[0].(string)
+				_, queued := pending[v.Token]
+				switch {
+				case !ok:
+					result.Status = models.DeleteNotFound
+				case user != v.User:
+					result.Status = models.DeleteNotOwner
+				case values[1] != nil || queued:
+					result.Status = models.DeleteAlreadyDeleted
+				default:
+					pending[v.Token] = struct{}{}
+					pipe.HSet(ctx, linkKey(v.Token), fieldDeleted, now.Format(time.RFC3339Nano))
+					pipe.ZAdd(ctx, keyDeleted, goredis.Z{Score: scoreValue(now), Member: v.Token})
+				}
+				results = append(results, result)
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// UpdateURL меняет исходный URL ссылки, прежний URL дописывается в список
+// ревизий. Если новый URL уже сокращен другой ссылкой, возвращает ее токен
+// и models.ErrorAlreadyExist
+func (s *RedisStorage) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error) {
+	var existing string
+	err := s.watch(ctx, func(tx *goredis.Tx) error {
+		existing = ""
+		l, err := getLink(ctx, tx, sToken)
+		if err != nil {
+			return err
+		}
+		if l.User != user {
+			return models.ErrLinkNotFound
+		}
+		if l.Deleted {
+			return models.ErrLinkDeleted
+		}
+		if l.LongURL == longURL {
+			return nil
+		}
+		short, err := tx.Get(ctx, urlKey(longURL)).Result()
+		if err == nil {
+			existing = short
+			return models.ErrorAlreadyExist
+		}
+		if !errors.Is(err, goredis.Nil) {
+			return err
+		}
+		// прежний URL известен только после чтения ссылки. Ссылка уже под WATCH,
+		// поэтому до EXEC ее URL не сменится, а индекс прежнего URL добавляется
+		// к наблюдаемым ключам до чтения
+		if err := tx.Watch(ctx, urlKey(l.LongURL)).Err(); err != nil {
+			return err
+		}
+		current, err := tx.Get(ctx, urlKey(l.LongURL)).Result()
+		if err != nil && !errors.Is(err, goredis.Nil) {
+			return err
+		}
+		value, err := json.Marshal(revision{LongURL: l.LongURL, ReplacedAt: time.Now().UTC()})
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.RPush(ctx, revisionsKey(sToken), value)
+			pipe.HSet(ctx, linkKey(sToken), fieldLongURL, longURL)
+			if current == sToken {
+				pipe.Del(ctx, urlKey(l.LongURL))
+			}
+			pipe.Set(ctx, urlKey(longURL), sToken, 0)
+			return nil
+		})
+		return err
+	}, linkKey(sToken), urlKey(longURL))
+	if err != nil {
+		return existing, err
+	}
+	return sToken, nil
+}
+
+// GetRevisions возвращает текущий и прежние исходные URL ссылки пользователя
+func (s *RedisStorage) GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error) {
+	l, err := getLink(ctx, s.client, sToken)
+	if err != nil {
+		return models.LinkRevisions{}, err
+	}
+	if l.User != user {
+		return models.LinkRevisions{}, models.ErrLinkNotFound
+	}
+	values, err := s.client.LRange(ctx, revisionsKey(sToken), 0, -1).Result()
+	if err != nil {
+		return models.LinkRevisions{}, err
+	}
+	revisions := make([]models.Revision, 0, len(values))
+	for i, value := range values {
+		var r revision
+		if err := json.Unmarshal([]byte(value), &r); err != nil {
+			return models.LinkRevisions{}, err
+		}
+		revisions = append(revisions, models.Revision{Revision: i + 1, LongURL: r.LongURL, ReplacedAt: r.ReplacedAt})
+	}
+	return models.LinkRevisions{ShortURL: sToken, LongURL: l.LongURL, Revisions: revisions}, nil
+}
+
+// RestoreURL снимает метку удаления со ссылки пользователя
+func (s *RedisStorage) RestoreURL(ctx context.Context, sToken string, user string) error {
+	return s.watch(ctx, func(tx *goredis.Tx) error {
+		l, err := getLink(ctx, tx, sToken)
+		if err != nil {
+			return err
+		}
+		if l.User != user {
+			return models.ErrLinkNotFound
+		}
+		if !l.Deleted {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.HDel(ctx, linkKey(sToken), fieldDeleted)
+			pipe.ZRem(ctx, keyDeleted, sToken)
+			return nil
+		})
+		return err
+	}, linkKey(sToken))
+}
+
+// GetDeletedURLs возвращает удаленные ссылки пользователя. Ссылки пользователя
+// читаются одним запросом, удаленные отбираются по метке удаления
+func (s *RedisStorage) GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error) {
+	shorts, err := s.client.ZRange(ctx, userKey(user), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]*goredis.SliceCmd, 0, len(shorts))
+	_, err = s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, short := range shorts {
+			cmds = append(cmds, pipe.HMGet(ctx, linkKey(short), fieldLongURL, fieldDeleted))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]models.DeletedLink, 0)
+	for i, short := range shorts {
+		values := cmds[i].Val()
+		longURL, _ := values[0].(string)
+		deleted, ok := values[1].(string)
+		if !ok {
+			continue
+		}
+		deletedAt, err := time.Parse(time.RFC3339Nano, deleted)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, models.DeletedLink{ShortURL: short, LongURL: longURL, DeletedAt: deletedAt})
+	}
+	return links, nil
+}
+
+// PurgeDeleted удаляет ссылки, удаленные не позже before.
+// Токены выбираются из индекса удаленных ссылок
+func (s *RedisStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	shorts, err := s.client.ZRangeByScore(ctx, keyDeleted, &goredis.ZRangeBy{
+		Min: "-inf",
+		Max: score(before),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, short := range shorts {
+		purged, err := s.purge(ctx, short, func(l link) bool {
+			// ссылку могли восстановить после чтения индекса
+			return l.Deleted && !l.DeletedAt.After(before)
+		})
+		if err != nil {
+			return count, err
+		}
+		if purged {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Close закрывает соединения с сервером
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
+
+// ReserveTokens резервирует n номеров последовательных токенов, общих для всех
+// реплик. Возвращает последний номер перед резервом. Если счетчика еще нет,
+// выданными считаются номера по числу ссылок
+func (s *RedisStorage) ReserveTokens(ctx context.Context, n uint64) (uint64, error) {
+	count, err := s.client.ZCard(ctx, keyCreated).Result()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.client.SetNX(ctx, keySequence, count, 0).Err(); err != nil {
+		return 0, err
+	}
+	to, err := s.client.IncrBy(ctx, keySequence, int64(n)).Result()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(to) - n, nil
+}
+
+// GetStorageLen возвращает число ссылок
+func (s *RedisStorage) GetStorageLen() int {
+	count, err := s.client.ZCard(context.Background(), keyCreated).Result()
+	if err != nil {
+		s.log.Error(err.Error())
+		return 0
+	}
+	return int(count)
+}
+
+// GetStats возвращает статистику по ссылкам по индексам, не обходя ссылки
+func (s *RedisStorage) GetStats(ctx context.Context, top int) (models.Stats, error) {
+	now := time.Now()
+	var (
+		urls, users, deleted  *goredis.IntCmd
+		created24h, created7d *goredis.IntCmd
+		topUsers              *goredis.ZSliceCmd
+	)
+	_, err := s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		urls = pipe.ZCard(ctx, keyCreated)
+		users = pipe.ZCard(ctx, keyUsers)
+		deleted = pipe.ZCard(ctx, keyDeleted)
+		created24h = pipe.ZCount(ctx, keyCreated, "("+score(now.Add(-24*time.Hour)), "+inf")
+		created7d = pipe.ZCount(ctx, keyCreated, "("+score(now.Add(-statsWeek)), "+inf")
+		topUsers = pipe.ZRevRangeWithScores(ctx, keyUsers, 0, int64(top)-1)
+		return nil
+	})
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	stats := models.Stats{
+		URLs:       int(urls.Val()),
+		Users:      int(users.Val()),
+		Deleted:    int(deleted.Val()),
+		Created24h: int(created24h.Val()),
+		Created7d:  int(created7d.Val()),
+		TopUsers:   make([]models.UserLinks, 0, len(topUsers.Val())),
+	}
+	for _, z := range topUsers.Val() {
+		user, _ := z.Member.(string)
+		stats.TopUsers = append(stats.TopUsers, models.UserLinks{User: user, Links: int(z.Score)})
+	}
+	// при равном числе ссылок Redis упорядочивает пользователей по убыванию
+	sort.SliceStable(stats.TopUsers, func(i, j int) bool {
+		if stats.TopUsers[i].Links != stats.TopUsers[j].Links {
+			return stats.TopUsers[i].Links > stats.TopUsers[j].Links
+		}
+		return stats.TopUsers[i].User < stats.TopUsers[j].User
+	})
+	return stats, nil
+}
+
+// DeleteExpired ставит метку удаления на ссылки, срок действия которых истек
+// к моменту now. Временем удаления становится срок действия. Истекшие токены
+// выбираются из индекса сроков, уже удаленные отсеиваются по индексу удалений
+func (s *RedisStorage) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	shorts, err := s.client.ZRangeByScore(ctx, keyExpires, &goredis.ZRangeBy{
+		Min: "-inf",
+		Max: score(now),
+	}).Result()
+	if err != nil || len(shorts) == 0 {
+		return 0, err
+	}
+	deleted := make([]*goredis.FloatCmd, 0, len(shorts))
+	_, err = s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, short := range shorts {
+			deleted = append(deleted, pipe.ZScore(ctx, keyDeleted, short))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return 0, err
+	}
+	count := 0
+	for i, short := range shorts {
+		if deleted[i].Err() == nil {
+			continue
+		}
+		marked, err := s.markExpired(ctx, short, now)
+		if err != nil {
+			return count, err
+		}
+		if marked {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// markExpired ставит метку удаления на ссылку с истекшим сроком. Возвращает
+// false, если ссылки нет, она уже удалена или ее срок еще не истек
+func (s *RedisStorage) markExpired(ctx context.Context, short string, now time.Time) (bool, error) {
+	marked := false
+	err := s.watch(ctx, func(tx *goredis.Tx) error {
+		l, err := getLink(ctx, tx, short)
+		if errors.Is(err, models.ErrLinkNotFound) {
+			marked = false
+			return tx.ZRem(ctx, keyExpires, short).Err()
+		}
+		if err != nil {
+			return err
+		}
+		// срок могли продлить после чтения индекса
+		if l.Deleted || l.ExpiresAt.IsZero() || l.ExpiresAt.After(now) {
+			marked = false
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.HSet(ctx, linkKey(short), fieldDeleted, l.ExpiresAt.UTC().Format(time.RFC3339Nano))
+			pipe.ZAdd(ctx, keyDeleted, goredis.Z{Score: scoreValue(l.ExpiresAt), Member: short})
+			return nil
+		})
+		marked = err == nil
+		return err
+	}, linkKey(short))
+	return marked, err
+}
+
+// SaveAPIKey сохраняет API ключ
+func (s *RedisStorage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, apiKeyKey(key.Hash),
+			"id", key.ID,
+			"name", key.Name,
+			"user", key.User,
+			"created_at", key.CreatedAt.Format(time.RFC3339Nano))
+		pipe.HSet(ctx, keyAPIKeyIDs, key.ID, key.Hash)
+		return nil
+	})
+	return err
+}
+
+// GetAPIKey ищет API ключ по хэшу
+func (s *RedisStorage) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	values, err := s.client.HGetAll(ctx, apiKeyKey(hash)).Result()
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if len(values) == 0 {
+		return models.APIKey{}, models.ErrAPIKeyNotFound
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, values["created_at"])
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return models.APIKey{
+		ID:        values["id"],
+		Name:      values["name"],
+		User:      values["user"],
+		Hash:      hash,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// RevokeAPIKey удаляет API ключ по идентификатору
+func (s *RedisStorage) RevokeAPIKey(ctx context.Context, id string) error {
+	return s.watch(ctx, func(tx *goredis.Tx) error {
+		hash, err := tx.HGet(ctx, keyAPIKeyIDs, id).Result()
+		if errors.Is(err, goredis.Nil) {
+			return models.ErrAPIKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Del(ctx, apiKeyKey(hash))
+			pipe.HDel(ctx, keyAPIKeyIDs, id)
+			return nil
+		})
+		return err
+	}, keyAPIKeyIDs)
+}
+
+// watch выполняет fn под WATCH keys и повторяет ее, если ключи изменились
+// до EXEC
+func (s *RedisStorage) watch(ctx context.Context, fn func(tx *goredis.Tx) error, keys ...string) error {
+	var err error
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		err = s.client.Watch(ctx, fn, keys...)
+		if !errors.Is(err, goredis.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+// purge удаляет ссылку вместе с записями в индексах, если due подтверждает,
+// что ее пора удалять. Возвращает false, если ссылки уже нет или она не удалена
+func (s *RedisStorage) purge(ctx context.Context, short string, due func(l link) bool) (bool, error) {
+	purged := false
+	err := s.watch(ctx, func(tx *goredis.Tx) error {
+		l, err := getLink(ctx, tx, short)
+		if errors.Is(err, models.ErrLinkNotFound) {
+			// ссылки нет, остались только записи в индексах
+			purged = false
+			_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+				pipe.ZRem(ctx, keyExpires, short)
+				pipe.ZRem(ctx, keyDeleted, short)
+				return nil
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !due(l) {
+			purged = false
+			return nil
+		}
+		// индекс URL наблюдается так же, как в UpdateURL
+		if err := tx.Watch(ctx, urlKey(l.LongURL)).Err(); err != nil {
+			return err
+		}
+		current, err := tx.Get(ctx, urlKey(l.LongURL)).Result()
+		if err != nil && !errors.Is(err, goredis.Nil) {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Del(ctx, linkKey(short), revisionsKey(short))
+			if current == short {
+				pipe.Del(ctx, urlKey(l.LongURL))
+			}
+			pipe.ZRem(ctx, userKey(l.User), short)
+			pipe.ZRem(ctx, keyCreated, short)
+			pipe.ZRem(ctx, keyExpires, short)
+			pipe.ZRem(ctx, keyDeleted, short)
+			pipe.ZIncrBy(ctx, keyUsers, -1, l.User)
+			// пользователи без ссылок не считаются
+			pipe.ZRemRangeByScore(ctx, keyUsers, "-inf", "0")
+			return nil
+		})
+		purged = err == nil
+		return err
+	}, linkKey(short))
+	return purged, err
+}
+
+// longURLs читает исходные URL для токенов одним запросом
+func (s *RedisStorage) longURLs(ctx context.Context, shorts []string) ([]models.LinksData, error) {
+	links := make([]models.LinksData, 0, len(shorts))
+	if len(shorts) == 0 {
+		return links, nil
+	}
+	cmds := make([]*goredis.StringCmd, 0, len(shorts))
+	_, err := s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, short := range shorts {
+			cmds = append(cmds, pipe.HGet(ctx, linkKey(short), fieldLongURL))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, err
+	}
+	for i, short := range shorts {
+		// ссылку могли удалить после чтения индекса
+		if cmds[i].Err() != nil {
+			continue
+		}
+		links = append(links, models.LinksData{ShortURL: short, LongURL: cmds[i].Val()})
+	}
+	return links, nil
+}
+
+// checkFree возвращает models.ErrShortURLAlreadyExist, если токен занят
+func checkFree(ctx context.Context, tx *goredis.Tx, sToken string) error {
+	n, err := tx.Exists(ctx, linkKey(sToken)).Result()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return models.ErrShortURLAlreadyExist
+	}
+	return nil
+}
+
+// newLink возвращает новую ссылку, созданную сейчас
+func newLink(longURL string, user string, expiresAt time.Time) link {
+	return link{LongURL: longURL, User: user, CreatedAt: time.Now().UTC(), ExpiresAt: expiresAt}
+}
+
+// putLink добавляет в транзакцию запись новой ссылки и ее индексов
+func putLink(ctx context.Context, pipe goredis.Pipeliner, sToken string, l link) {
+	fields := []interface{}{
+		fieldLongURL, l.LongURL,
+		fieldUser, l.User,
+		fieldCreatedAt, l.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if !l.ExpiresAt.IsZero() {
+		fields = append(fields, fieldExpiresAt, l.ExpiresAt.Format(time.RFC3339Nano))
+		pipe.ZAdd(ctx, keyExpires, goredis.Z{Score: scoreValue(l.ExpiresAt), Member: sToken})
+	}
+	pipe.HSet(ctx, linkKey(sToken), fields...)
+	pipe.Set(ctx, urlKey(l.LongURL), sToken, 0)
+	pipe.ZAdd(ctx, userKey(l.User), goredis.Z{Score: 0, Member: sToken})
+	pipe.ZAdd(ctx, keyCreated, goredis.Z{Score: scoreValue(l.CreatedAt), Member: sToken})
+	pipe.ZIncrBy(ctx, keyUsers, 1, l.User)
+}
+
+// getLink читает ссылку из хэша
+func getLink(ctx context.Context, client goredis.Cmdable, sToken string) (link, error) {
+	values, err := client.HGetAll(ctx, linkKey(sToken)).Result()
+	if err != nil {
+		return link{}, err
+	}
+	if len(values) == 0 {
+		return link{}, models.ErrLinkNotFound
+	}
+	l := link{
+		LongURL: values[fieldLongURL],
+		User:    values[fieldUser],
+	}
+	if deleted := values[fieldDeleted]; deleted != "" {
+		l.Deleted = true
+		if l.DeletedAt, err = time.Parse(time.RFC3339Nano, deleted); err != nil {
+			return link{}, err
+		}
+	}
+	if l.CreatedAt, err = time.Parse(time.RFC3339Nano, values[fieldCreatedAt]); err != nil {
+		return link{}, err
+	}
+	if expires := values[fieldExpiresAt]; expires != "" {
+		if l.ExpiresAt, err = time.Parse(time.RFC3339Nano, expires); err != nil {
+			return link{}, err
+		}
+	}
+	return l, nil
+}
+
+// scoreValue переводит время в вес упорядоченного множества (мс)
+func scoreValue(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
+
+// score возвращает время как границу диапазона весов
+func score(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func linkKey(sToken string) string {
+	return prefix + "link:" + sToken
+}
+
+func urlKey(longURL string) string {
+	return prefix + "url:" + longURL
+}
+
+func userKey(user string) string {
+	return prefix + "user:" + user
+}
+
+func revisionsKey(sToken string) string {
+	return prefix + "revisions:" + sToken
+}
+
+func apiKeyKey(hash string) string {
+	return prefix + "api_key:" + hash
+}
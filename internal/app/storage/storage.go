@@ -2,33 +2,152 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"example.com/shortener/internal/app/models"
 	"example.com/shortener/internal/app/service"
+	"example.com/shortener/internal/app/storage/bolt"
 	"example.com/shortener/internal/app/storage/database"
 	"example.com/shortener/internal/app/storage/memory"
+	"example.com/shortener/internal/app/storage/redis"
 	"example.com/shortener/internal/config"
 	"github.com/sirupsen/logrus"
 )
 
-// New возвращает объект хранилища
-func New(cfg config.Config, log *logrus.Logger) service.Storer {
+// Ошибки выбора хранилища
+var (
+	ErrUnknownBackend = errors.New("unknown storage backend")
+	ErrNoDatabaseDSN  = errors.New("storage backend postgres requires DATABASE_DSN")
+	ErrNoFile         = errors.New("storage backend file requires FILE_STORAGE_PATH")
+	ErrNoBoltFile     = errors.New("storage backend bolt requires BOLT_STORAGE_PATH")
+	ErrNoRedisURL     = errors.New("storage backend redis requires REDIS_URL")
+)
+
+// Ожидание между попытками подключения к серверу хранилища растет от retryDelay
+// вдвое до maxRetryDelay, каждая попытка ограничена connectTimeout
+var (
+	connectTimeout = 5 * time.Second
+	retryDelay     = 500 * time.Millisecond
+	maxRetryDelay  = 5 * time.Second
+)
+
+// Backend возвращает хранилище, выбранное в конфигурации. Если STORAGE_BACKEND
+// не задан, выбирается postgres при заданном DATABASE_DSN, file при заданном
+// FILE_STORAGE_PATH, иначе memory
+func Backend(cfg config.Config) (string, error) {
+	switch cfg.StorageBackend {
+	case "":
+		switch {
+		case cfg.Database != "":
+			return models.BackendPostgres, nil
+		case cfg.File != "":
+			return models.BackendFile, nil
+		}
+		return models.BackendMemory, nil
+	case models.BackendPostgres:
+		if cfg.Database == "" {
+			return "", ErrNoDatabaseDSN
+		}
+	case models.BackendFile:
+		if cfg.File == "" {
+			return "", ErrNoFile
+		}
+	case models.BackendBolt:
+		if cfg.BoltFile == "" {
+			return "", ErrNoBoltFile
+		}
+	case models.BackendRedis:
+		if cfg.RedisURL == "" {
+			return "", ErrNoRedisURL
+		}
+	case models.BackendMemory:
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownBackend, cfg.StorageBackend)
+	}
+	return cfg.StorageBackend, nil
+}
+
+// New возвращает объект выбранного хранилища. Подключение к бд или серверу
+// redis при запуске
+// повторяется в течение StorageConnectTimeout. Если хранилище так и не стало
+// доступно, при StorageFailFast возвращается ошибка, иначе сервис
+// переходит на file или memory с ошибкой в журнале
+func New(cfg config.Config, log *logrus.Logger) (service.Storer, error) {
+	backend, err := Backend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	var storer service.Storer
-	var err error
-
-	// конструируем контекст с 5-секундным тайм-аутом
-	// после 5 секунд затянувшаяся операция с БД будет прервана
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	// не забываем освободить ресурс
-	defer cancel()
-	// создаем объект хранилища
-	if cfg.Database != "" {
-		storer, err = database.New(ctx, cfg, log) // бд хранилище
-		if err != nil {
-			storer = memory.New(cfg, log)
-		}
-	} else {
-		storer = memory.New(cfg, log) // in-memory хранилище
+	switch backend {
+	case models.BackendPostgres:
+		storer, err = connect(log, cfg.StorageConnectTimeout, func(ctx context.Context) (service.Storer, error) {
+			return database.New(ctx, cfg, log)
+		})
+	case models.BackendRedis:
+		storer, err = connect(log, cfg.StorageConnectTimeout, func(ctx context.Context) (service.Storer, error) {
+			return redis.New(ctx, cfg, log)
+		})
+	case models.BackendFile:
+		storer = memory.New(cfg, log)
+	case models.BackendBolt:
+		storer, err = openBolt(cfg, log)
+	default:
+		// хранилище в памяти не пишет в файл, даже если он задан
+		cfg.File = ""
+		storer = memory.New(cfg, log)
+	}
+	if err != nil {
+		if cfg.StorageFailFast {
+			return nil, fmt.Errorf("storage backend %s: %w", backend, err)
+		}
+		cfg.Database = ""
+		cfg.StorageBackend = ""
+		storer = memory.New(cfg, log)
+		log.WithFields(logrus.Fields{
+			"backend":  backend,
+			"fallback": storer.Backend(),
+			"error":    err,
+		}).Error("Хранилище недоступно, данные сохраняются в резервное")
+	}
+	return storer, nil
+}
+
+// openBolt открывает файл базы bolt
+func openBolt(cfg config.Config, log *logrus.Logger) (service.Storer, error) {
+	storer, err := bolt.New(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	return storer, nil
+}
+
+// connect подключается к серверу хранилища функцией open, повторяя попытки
+// с растущей паузой в течение timeout
+func connect(log *logrus.Logger, timeout time.Duration,
+	open func(ctx context.Context) (service.Storer, error)) (service.Storer, error) {
+	deadline := time.Now().Add(timeout)
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		storer, err := open(ctx)
+		cancel()
+		if err == nil {
+			return storer, nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return nil, err
+		}
+		log.WithFields(logrus.Fields{
+			"attempt": attempt,
+			"delay":   delay,
+			"error":   err,
+		}).Warn("Хранилище недоступно, повторяем подключение")
+		time.Sleep(delay)
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
 	}
-	return storer
 }
@@ -2,15 +2,19 @@ package storage
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"example.com/shortener/internal/app/models"
 	service "example.com/shortener/internal/app/service"
 	memory "example.com/shortener/internal/app/storage/memory"
 	"example.com/shortener/internal/config"
 	"example.com/shortener/internal/config/utils"
 	"example.com/shortener/internal/logger"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStorage(t *testing.T) {
@@ -43,14 +47,17 @@ func TestStorage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			//s := NewStorage(config.Config{File: tt.file})
+			file := tt.file
+			if file != "" {
+				file = filepath.Join(t.TempDir(), file)
+			}
 			log := logger.InitLog()
-			storer := memory.New(config.Config{File: tt.file}, log)
-			s := service.New(config.Config{File: tt.file}, storer, log)
+			storer := memory.New(config.Config{File: file}, log)
+			s := service.New(config.Config{File: file}, storer, log)
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
-			// Добавляем ссылку в хранилище
-			sToken := utils.GenRandToken("http://localhost:8080/")
-			gToken, err := s.AddLink(ctx, sToken, tt.longURL, "")
+			// Добавляем ссылку в хранилище со случайным токеном
+			gToken, err := s.AddLink(ctx, "", tt.longURL, "", time.Time{})
 			if err != nil {
 				t.Errorf("StorageLinks.GetLongURL() error = %v", err)
 				return
@@ -72,3 +79,185 @@ func TestStorage(t *testing.T) {
 		})
 	}
 }
+
+func TestAlias(t *testing.T) {
+	log := logger.InitLog()
+	cfg := config.Config{BaseURL: "http://localhost:8080/"}
+	s := service.New(cfg, memory.New(cfg, log), log)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	alias := "launch-" + utils.RandStringBytes(6)
+	gToken, err := s.AddLink(ctx, alias, "https://www.youtube.com/", "", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, cfg.BaseURL+alias, gToken)
+
+	got, err := s.GetLongURL(ctx, gToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.youtube.com/", got)
+
+	tests := []struct {
+		name  string
+		alias string
+		err   error
+	}{
+		{name: "Alias already taken", alias: alias, err: models.ErrShortURLAlreadyExist},
+		{name: "Reserved word", alias: "API", err: models.ErrReservedAlias},
+		{name: "Too short", alias: "ab", err: models.ErrInvalidAlias},
+		{name: "Forbidden characters", alias: "launch/2026", err: models.ErrInvalidAlias},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AddLink(ctx, tt.alias, "https://www.pinterest.com/", "", time.Time{})
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	log := logger.InitLog()
+	cfg := config.Config{BaseURL: "http://localhost:8080/"}
+	storer := memory.New(cfg, log)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	expired := cfg.BaseURL + utils.RandStringBytes(10)
+	_, err := storer.AddLink(ctx, expired, "https://www.youtube.com/", "", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	alive := cfg.BaseURL + utils.RandStringBytes(10)
+	_, err = storer.AddLink(ctx, alive, "https://www.pinterest.com/", "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// ссылка с истекшим сроком действия больше не открывается
+	_, err = storer.GetLongURL(ctx, expired)
+	assert.ErrorIs(t, err, models.ErrLinkExpired)
+	got, err := storer.GetLongURL(ctx, alive)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.pinterest.com/", got)
+
+	// очистка помечает удаленными только ссылки с истекшим сроком действия,
+	// они по-прежнему отвечают 410, пока их не удалит PurgeDeleted
+	count, err := storer.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = storer.GetLongURL(ctx, expired)
+	assert.ErrorIs(t, err, models.ErrLinkDeleted)
+	count, err = storer.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 2, storer.GetStorageLen())
+	count, err = storer.PurgeDeleted(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, storer.GetStorageLen())
+
+	// срок действия можно задать через TTL или абсолютную дату, но не одновременно
+	_, err = service.ParseExpiry("2000-01-01T00:00:00Z", 0)
+	assert.ErrorIs(t, err, models.ErrInvalidExpiry)
+	_, err = service.ParseExpiry(time.Now().Add(time.Hour).Format(time.RFC3339), 60)
+	assert.ErrorIs(t, err, models.ErrInvalidExpiry)
+	expires, err := service.ParseExpiry("", 60)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expires, time.Second)
+}
+
+func TestBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		backend string
+		err     error
+	}{
+		{name: "default memory", cfg: config.Config{}, backend: models.BackendMemory},
+		{name: "default file", cfg: config.Config{File: "links.log"}, backend: models.BackendFile},
+		{name: "default postgres", cfg: config.Config{File: "links.log", Database: "host=localhost"}, backend: models.BackendPostgres},
+		{name: "memory ignores dsn", cfg: config.Config{StorageBackend: "memory", Database: "host=localhost"}, backend: models.BackendMemory},
+		{name: "postgres without dsn", cfg: config.Config{StorageBackend: "postgres"}, err: ErrNoDatabaseDSN},
+		{name: "file without path", cfg: config.Config{StorageBackend: "file"}, err: ErrNoFile},
+		{name: "bolt", cfg: config.Config{StorageBackend: "bolt", BoltFile: "shortener.db"}, backend: models.BackendBolt},
+		{name: "bolt without path", cfg: config.Config{StorageBackend: "bolt"}, err: ErrNoBoltFile},
+		{name: "redis", cfg: config.Config{StorageBackend: "redis", RedisURL: "redis://localhost:6379"}, backend: models.BackendRedis},
+		{name: "redis without url", cfg: config.Config{StorageBackend: "redis"}, err: ErrNoRedisURL},
+		{name: "unknown", cfg: config.Config{StorageBackend: "mongo"}, err: ErrUnknownBackend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := Backend(tt.cfg)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.backend, backend)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	log := logger.InitLog()
+	// по этому адресу бд не отвечает
+	unreachable := "host=127.0.0.1 port=1 user=shortener dbname=shortener sslmode=disable connect_timeout=1"
+
+	t.Run("memory does not write the file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "links.log")
+		storer, err := New(config.Config{StorageBackend: models.BackendMemory, File: file}, log)
+		require.NoError(t, err)
+		defer storer.Close()
+		assert.Equal(t, models.BackendMemory, storer.Backend())
+		assert.NoFileExists(t, file)
+	})
+
+	t.Run("bolt", func(t *testing.T) {
+		storer, err := New(config.Config{
+			StorageBackend: models.BackendBolt,
+			BoltFile:       filepath.Join(t.TempDir(), "shortener.db"),
+		}, log)
+		require.NoError(t, err)
+		defer storer.Close()
+		assert.Equal(t, models.BackendBolt, storer.Backend())
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		storer, err := New(config.Config{
+			StorageBackend: models.BackendRedis,
+			RedisURL:       "redis://" + server.Addr(),
+		}, log)
+		require.NoError(t, err)
+		defer storer.Close()
+		assert.Equal(t, models.BackendRedis, storer.Backend())
+	})
+
+	t.Run("redis unavailable falls back", func(t *testing.T) {
+		storer, err := New(config.Config{StorageBackend: models.BackendRedis, RedisURL: "redis://127.0.0.1:1"}, log)
+		require.NoError(t, err)
+		defer storer.Close()
+		assert.Equal(t, models.BackendMemory, storer.Backend())
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		_, err := New(config.Config{StorageBackend: "mongo"}, log)
+		assert.ErrorIs(t, err, ErrUnknownBackend)
+	})
+
+	t.Run("postgres unavailable with fail fast", func(t *testing.T) {
+		_, err := New(config.Config{Database: unreachable, StorageFailFast: true}, log)
+		assert.Error(t, err)
+	})
+
+	t.Run("postgres retried until timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := New(config.Config{
+			Database:              unreachable,
+			StorageFailFast:       true,
+			StorageConnectTimeout: 2 * retryDelay,
+		}, log)
+		assert.Error(t, err)
+		// вторая попытка делается после паузы
+		assert.GreaterOrEqual(t, time.Since(start), retryDelay)
+	})
+
+	t.Run("postgres unavailable falls back", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "links.log")
+		storer, err := New(config.Config{Database: unreachable, File: file}, log)
+		require.NoError(t, err)
+		defer storer.Close()
+		assert.Equal(t, models.BackendFile, storer.Backend())
+	})
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor открывает спан на каждый вызов gRPC. Контекст
+// трассировки берется из метаданных traceparent вызова и возвращается
+// в заголовке ответа. Интерсептор создается после Init
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	interceptor := otelgrpc.UnaryServerInterceptor()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			grpc.SetHeader(ctx, traceHeader(ctx))
+			return handler(ctx, req)
+		})
+	}
+}
+
+// StreamServerInterceptor - то же для потоковых вызовов
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	interceptor := otelgrpc.StreamServerInterceptor()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		return interceptor(srv, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+			ss.SetHeader(traceHeader(ss.Context()))
+			return handler(srv, ss)
+		})
+	}
+}
+
+// traceHeader возвращает контекст трассировки вызова в виде метаданных
+func traceHeader(ctx context.Context) metadata.MD {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return metadata.New(carrier)
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"example.com/shortener/internal/app/middleware"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/semconv/v1.17.0/httpconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTPMiddleware открывает спан на каждый запрос к роутеру chi. Контекст
+// трассировки берется из заголовков traceparent и tracestate запроса
+// и возвращается в тех же заголовках ответа. Спан называется по шаблону
+// маршрута chi (например, GET /{id}), который известен после обработки
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		propagator := otel.GetTextMapPropagator()
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(httpconv.ServerRequest("", r)...))
+		defer span.End()
+		propagator.Inject(ctx, propagation.HeaderCarrier(w.Header()))
+
+		// контекст маршрута создается заранее, тогда роутер заполнит его
+		rctx := chi.RouteContext(ctx)
+		if rctx == nil {
+			rctx = chi.NewRouteContext()
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		}
+		sw := middleware.NewStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		if route := rctx.RoutePattern(); len(rctx.RoutePatterns) > 0 && !strings.HasSuffix(route, "/*") {
+			// chi отрезает завершающий слеш, и от корня остается пустая строка
+			if route == "" {
+				route = "/"
+			}
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPStatusCode(sw.Status()))
+		span.SetStatus(httpconv.ServerStatus(sw.Status()))
+	})
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PgxLogger превращает записи журнала pgx о выполненных запросах в спаны.
+// В pgx v4 нет отдельного механизма трассировки, но журнал вызывается
+// с контекстом запроса и временем его выполнения, поэтому спан
+// восстанавливается задним числом. Для записи запросов в соединении нужен
+// уровень журнала pgx.LogLevelInfo
+type PgxLogger struct{}
+
+// Log реализует pgx.Logger
+func (PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	elapsed, ok := data["time"].(time.Duration)
+	if !ok {
+		return
+	}
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		// запросы вне трассируемого вызова (миграции, фоновые задачи
+		// без контекста) спанов не порождают
+		return
+	}
+
+	sql, _ := data["sql"].(string)
+	name := msg
+	attrs := []attribute.KeyValue{semconv.DBSystemPostgreSQL}
+	if sql != "" {
+		name = operation(sql)
+		attrs = append(attrs, semconv.DBStatement(sql), semconv.DBOperation(name))
+	}
+	if n, ok := data["batchLen"].(int); ok {
+		attrs = append(attrs, attribute.Int("db.batch.size", n))
+	}
+
+	endTime := time.Now()
+	_, span := tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(endTime.Add(-elapsed)),
+		trace.WithAttributes(attrs...))
+	if err, ok := data["err"].(error); ok {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(endTime))
+}
+
+// operation возвращает первое слово запроса: SELECT, INSERT и т.д.
+func operation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,198 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"example.com/shortener/internal/app/models"
+	"example.com/shortener/internal/app/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// storer оборачивает хранилище и открывает дочерний спан на каждый вызов
+type storer struct {
+	storage service.Storer
+}
+
+// проверка на имплементацию интерфейса
+var _ service.Storer = storer{}
+
+// NewStorer возвращает хранилище storage со спанами на вызовы
+func NewStorer(storage service.Storer) service.Storer {
+	return storer{storage: storage}
+}
+
+// Unwrap возвращает хранилище под оберткой
+func (s storer) Unwrap() service.Storer {
+	return s.storage
+}
+
+// start открывает спан операции хранилища
+func start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...))
+}
+
+// end закрывает спан. Ошибки, которыми хранилище отвечает на обычные
+// ситуации, записываются в спан, но не помечают его как ошибочный
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !models.IsExpected(err) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func (s storer) AddLink(ctx context.Context, sToken string, longURL string, user string,
+	expiresAt time.Time) (string, error) {
+	ctx, span := start(ctx, "AddLink")
+	shortURL, err := s.storage.AddLink(ctx, sToken, longURL, user, expiresAt)
+	end(span, err)
+	return shortURL, err
+}
+
+func (s storer) GetLongURL(ctx context.Context, sToken string) (string, error) {
+	ctx, span := start(ctx, "GetLongURL")
+	longURL, err := s.storage.GetLongURL(ctx, sToken)
+	end(span, err)
+	return longURL, err
+}
+
+func (s storer) ResolveURL(ctx context.Context, sToken string) (string, time.Time, error) {
+	ctx, span := start(ctx, "ResolveURL")
+	longURL, expiresAt, err := s.storage.ResolveURL(ctx, sToken)
+	end(span, err)
+	return longURL, expiresAt, err
+}
+
+func (s storer) Ping(ctx context.Context) error {
+	ctx, span := start(ctx, "Ping")
+	err := s.storage.Ping(ctx)
+	end(span, err)
+	return err
+}
+
+func (s storer) GetAllURLS(ctx context.Context, cookie string) (map[string]string, error) {
+	ctx, span := start(ctx, "GetAllURLS")
+	urls, err := s.storage.GetAllURLS(ctx, cookie)
+	end(span, err)
+	return urls, err
+}
+
+func (s storer) GetUserURLsPage(ctx context.Context, cookie string, after string,
+	limit int) ([]models.LinksData, error) {
+	ctx, span := start(ctx, "GetUserURLsPage", attribute.Int("limit", limit))
+	links, err := s.storage.GetUserURLsPage(ctx, cookie, after, limit)
+	end(span, err)
+	return links, err
+}
+
+func (s storer) ShortenBatch(ctx context.Context, batchReq []models.BatchReq,
+	cookie string) ([]models.BatchResp, error) {
+	ctx, span := start(ctx, "ShortenBatch", attribute.Int("batch.size", len(batchReq)))
+	resp, err := s.storage.ShortenBatch(ctx, batchReq, cookie)
+	end(span, err)
+	return resp, err
+}
+
+func (s storer) BatchDelete(ctx context.Context, sTokens []models.TokenUser) ([]models.DeleteResult, error) {
+	ctx, span := start(ctx, "BatchDelete", attribute.Int("batch.size", len(sTokens)))
+	results, err := s.storage.BatchDelete(ctx, sTokens)
+	end(span, err)
+	return results, err
+}
+
+func (s storer) UpdateURL(ctx context.Context, sToken string, user string, longURL string) (string, error) {
+	ctx, span := start(ctx, "UpdateURL")
+	shortURL, err := s.storage.UpdateURL(ctx, sToken, user, longURL)
+	end(span, err)
+	return shortURL, err
+}
+
+func (s storer) GetRevisions(ctx context.Context, sToken string, user string) (models.LinkRevisions, error) {
+	ctx, span := start(ctx, "GetRevisions")
+	link, err := s.storage.GetRevisions(ctx, sToken, user)
+	end(span, err)
+	return link, err
+}
+
+func (s storer) RestoreURL(ctx context.Context, sToken string, user string) error {
+	ctx, span := start(ctx, "RestoreURL")
+	err := s.storage.RestoreURL(ctx, sToken, user)
+	end(span, err)
+	return err
+}
+
+func (s storer) GetDeletedURLs(ctx context.Context, user string) ([]models.DeletedLink, error) {
+	ctx, span := start(ctx, "GetDeletedURLs")
+	links, err := s.storage.GetDeletedURLs(ctx, user)
+	end(span, err)
+	return links, err
+}
+
+func (s storer) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := start(ctx, "PurgeDeleted")
+	n, err := s.storage.PurgeDeleted(ctx, before)
+	end(span, err)
+	return n, err
+}
+
+func (s storer) Close() error {
+	return s.storage.Close()
+}
+
+func (s storer) Backend() string {
+	return s.storage.Backend()
+}
+
+func (s storer) ReserveTokens(ctx context.Context, n uint64) (uint64, error) {
+	ctx, span := start(ctx, "ReserveTokens")
+	from, err := s.storage.ReserveTokens(ctx, n)
+	end(span, err)
+	return from, err
+}
+
+// GetStorageLen вызывается без контекста запроса, спан для него не нужен
+func (s storer) GetStorageLen() int {
+	return s.storage.GetStorageLen()
+}
+
+func (s storer) GetStats(ctx context.Context, top int) (models.Stats, error) {
+	ctx, span := start(ctx, "GetStats")
+	stats, err := s.storage.GetStats(ctx, top)
+	end(span, err)
+	return stats, err
+}
+
+func (s storer) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := start(ctx, "DeleteExpired")
+	n, err := s.storage.DeleteExpired(ctx, now)
+	end(span, err)
+	return n, err
+}
+
+func (s storer) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	ctx, span := start(ctx, "SaveAPIKey")
+	err := s.storage.SaveAPIKey(ctx, key)
+	end(span, err)
+	return err
+}
+
+func (s storer) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	ctx, span := start(ctx, "GetAPIKey")
+	key, err := s.storage.GetAPIKey(ctx, hash)
+	end(span, err)
+	return key, err
+}
+
+func (s storer) RevokeAPIKey(ctx context.Context, id string) error {
+	ctx, span := start(ctx, "RevokeAPIKey")
+	err := s.storage.RevokeAPIKey(ctx, id)
+	end(span, err)
+	return err
+}
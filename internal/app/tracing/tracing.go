@@ -0,0 +1,63 @@
+// Модуль tracing настраивает трассировку OpenTelemetry: спаны на маршруты
+// chi и методы gRPC, дочерние спаны на вызовы хранилища и запросы к бд,
+// передачу контекста трассировки в заголовках W3C traceparent
+package tracing
+
+import (
+	"context"
+
+	"example.com/shortener/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName - имя, под которым сервис создает спаны
+const instrumentationName = "example.com/shortener"
+
+// serviceName - имя сервиса в трассировках
+const serviceName = "shortener"
+
+// Init настраивает передачу контекста трассировки и, если задан
+// cfg.OTLPEndpoint, экспорт спанов в коллектор. Возвращает функцию,
+// которая отправляет оставшиеся спаны при остановке сервиса
+func Init(ctx context.Context, cfg config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint),
+		otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	provider := NewProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TraceSampleRatio))),
+	)
+	return provider.Shutdown, nil
+}
+
+// NewProvider создает провайдер спанов с опциями opts и делает его
+// глобальным. В тестах ему передается tracetest.InMemoryExporter
+func NewProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName))),
+	}, opts...)
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider
+}
+
+// tracer возвращает трассировщик глобального провайдера
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,155 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/shortener/internal/app/storage/memory"
+	"example.com/shortener/internal/config"
+	"example.com/shortener/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// контекст трассировки, пришедший от клиента
+	testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+)
+
+// newExporter настраивает трассировку с записью спанов в память
+func newExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	_, err := Init(context.Background(), config.Config{})
+	require.NoError(t, err)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+// spanByName ищет спан по имени среди записанных
+func spanByName(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span %q in %v", name, exporter.GetSpans())
+	return tracetest.SpanStub{}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	exporter := newExporter(t)
+	r := chi.NewRouter()
+	r.Route("/", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			// обработчик работает в контексте спана запроса
+			assert.True(t, trace.SpanFromContext(r.Context()).SpanContext().IsValid())
+			w.WriteHeader(http.StatusTemporaryRedirect)
+		})
+	})
+	handler := HTTPMiddleware(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/b", nil))
+
+	span := spanByName(t, exporter, "GET /{id}")
+	assert.Equal(t, testTraceID, span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Contains(t, span.Attributes, semconv.HTTPRoute("/{id}"))
+	assert.Contains(t, span.Attributes, semconv.HTTPStatusCode(http.StatusTemporaryRedirect))
+	// контекст трассировки возвращается клиенту
+	assert.Contains(t, rec.Header().Get("traceparent"), testTraceID)
+
+	// запрос мимо маршрутов называется только методом
+	span = spanByName(t, exporter, "GET")
+	assert.Equal(t, codes.Unset, span.Status.Code)
+	assert.False(t, span.Parent.IsValid())
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	exporter := newExporter(t)
+	interceptor := UnaryServerInterceptor()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", testTraceparent))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.Handlers/GetFullURL"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	require.NoError(t, err)
+
+	span := spanByName(t, exporter, "grpc.Handlers/GetFullURL")
+	assert.Equal(t, testTraceID, span.SpanContext.TraceID().String())
+}
+
+func TestStorer(t *testing.T) {
+	exporter := newExporter(t)
+	s := NewStorer(memory.New(config.Config{}, logger.InitLog()))
+
+	ctx, parent := tracer().Start(context.Background(), "request")
+	_, err := s.AddLink(ctx, "abc", "https://practicum.yandex.ru", "user", time.Time{})
+	require.NoError(t, err)
+	_, err = s.GetLongURL(ctx, "missing")
+	require.Error(t, err)
+	parent.End()
+
+	span := spanByName(t, exporter, "storage.AddLink")
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	assert.Equal(t, codes.Unset, span.Status.Code)
+
+	// отсутствие ссылки - не сбой хранилища
+	span = spanByName(t, exporter, "storage.GetLongURL")
+	assert.Equal(t, codes.Unset, span.Status.Code)
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, "exception", span.Events[0].Name)
+}
+
+func TestPgxLogger(t *testing.T) {
+	exporter := newExporter(t)
+	logger := PgxLogger{}
+
+	// запросы вне трассировки пропускаются
+	logger.Log(context.Background(), pgx.LogLevelInfo, "Query",
+		map[string]interface{}{"sql": "SELECT 1", "time": time.Millisecond})
+	assert.Empty(t, exporter.GetSpans())
+
+	ctx, parent := tracer().Start(context.Background(), "storage.GetLongURL")
+	logger.Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql":  "select long_url FROM urlsDBTable WHERE short_url = $1",
+		"time": 50 * time.Millisecond,
+	})
+	logger.Log(ctx, pgx.LogLevelError, "Exec", map[string]interface{}{
+		"sql":  "INSERT INTO urlsDBTable VALUES ($1)",
+		"time": time.Millisecond,
+		"err":  pgx.ErrNoRows,
+	})
+	// записи без времени выполнения - не запросы
+	logger.Log(ctx, pgx.LogLevelInfo, "Dialing PostgreSQL server", nil)
+	parent.End()
+	require.Len(t, exporter.GetSpans(), 3)
+
+	span := spanByName(t, exporter, "SELECT")
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	assert.Equal(t, 50*time.Millisecond, span.EndTime.Sub(span.StartTime))
+	assert.Contains(t, span.Attributes, semconv.DBStatement("select long_url FROM urlsDBTable WHERE short_url = $1"))
+
+	span = spanByName(t, exporter, "INSERT")
+	assert.Equal(t, codes.Error, span.Status.Code)
+}
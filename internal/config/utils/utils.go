@@ -1,23 +1,16 @@
 package utils
 
 import (
-	"crypto/hmac"
 	crypto "crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/base64"
-	"encoding/hex"
 	"encoding/pem"
-	"errors"
-	"log"
 	"math/big"
 	"math/rand"
 	"net"
-	"net/http"
-	urlNet "net/url"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -27,93 +20,28 @@ var (
 	localAddr = "localhost:8080"
 	filename  = "link.log"
 	baseURL   = "http://localhost:8080/"
-	secretkey = []byte("secret key")
 )
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
-// RandStringBytes генерирует короткий токен
+// RandStringBytes генерирует случайную строку из латинских букв.
+// Для сокращенных токенов используется service.TokenGenerator
 func RandStringBytes(n int) string {
-	rand.Seed(time.Now().UnixNano())
+	randomMu.Lock()
+	defer randomMu.Unlock()
 
 	link := make([]byte, n)
 	for i := range link {
-		link[i] = letterBytes[rand.Intn(len(letterBytes))]
+		link[i] = letterBytes[random.Intn(len(letterBytes))]
 	}
 	return string(link)
 }
 
-// GenRandToken возваращает сокращенный URL
-func GenRandToken(baseURL string) string {
-	gToken := RandStringBytes(10)
-	log.Println(gToken)
-	sToken := baseURL + gToken
-	_, urlParseErr := urlNet.Parse(sToken)
-	if urlParseErr != nil {
-		sToken = baseURL + "/" + gToken
-		log.Printf("Short URL %s", sToken)
-	}
-	return sToken
-}
-
-// generateUserToken генерирует криптостойкий слайс случайных байт
-func generateUserToken(len int) (string, error) {
-	b := make([]byte, len)
-	_, err := crypto.Read(b)
-	if err != nil {
-		return "", err
-	}
-	// кодируем массив, чтобы использовать его для куки
-	UserToken := hex.EncodeToString(b)
-
-	log.Printf("UserToken %s\n", UserToken)
-	return UserToken, nil
-}
-
-// WriteCookies формирует cookie для пользователя
-func WriteCookies() (http.Cookie, error) {
-	var err error
-	cookie := http.Cookie{
-		Name: "User",
-	}
-	cookie.Value, err = generateUserToken(16)
-	if err != nil {
-		return cookie, err
-	}
-	// подписываем алгоритмом HMAC, используя SHA256
-	h := hmac.New(sha256.New, secretkey)
-	h.Write([]byte(cookie.Value))
-	log.Printf("cookie.Value %s\n", cookie.Value)
-	sign := h.Sum(nil)
-
-	log.Printf("sign %s\n", sign)
-	cookie.Value = string(sign) + cookie.Value
-	cookie.Value = base64.URLEncoding.EncodeToString([]byte(cookie.Value))
-	return cookie, nil
-}
-
-// ReadCookies проверяет hmac подпись в cookie
-func ReadCookies(cookie http.Cookie) error {
-	signedValue, err := base64.URLEncoding.DecodeString(cookie.Value)
-	if err != nil {
-		log.Printf("utils|readCookies|%v\n", err)
-		return err
-	}
-
-	signature := signedValue[:sha256.Size]
-	value := signedValue[sha256.Size:]
-
-	mac := hmac.New(sha256.New, secretkey)
-	mac.Write([]byte(value))
-	expectedSignature := mac.Sum(nil)
-
-	if !hmac.Equal([]byte(signature), expectedSignature) {
-		log.Printf("handlers_base|userAuth|%s\n", errors.New("подпись не совпадает"))
-		return err
-	}
-	return nil
-
-}
+// random - генератор для RandStringBytes, инициализируется один раз
+var (
+	random   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	randomMu sync.Mutex
+)
 
 // GenerateTSL генерирует сертификат x.509 и RSA приватный ключ
 func GenerateCertTSL(log *logrus.Logger) error {